@@ -0,0 +1,173 @@
+package codeowners
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxBraceExpansions limits the number of patterns a single pattern can expand
+// into with WithBraceExpansion, so that a few braces can't make a huge number
+// of rules.
+const maxBraceExpansions = 1024
+
+// braceGroup is shared by the rules expanded from a single pattern with
+// WithBraceExpansion, so that they can be collapsed back into one line when
+// they're written out.
+type braceGroup struct {
+	// pattern is the pattern as it was written, with its braces.
+	pattern string
+	// size is the number of rules it expanded into.
+	size int
+}
+
+// WithBraceExpansion makes ParseFile expand braces in patterns, as shells do,
+// so that *.{js,ts} gives a rule for *.js and another for *.ts, with the same
+// line number, owners and comment. GitHub doesn't support braces, so without
+// it they're matched literally and commas aren't allowed in patterns.
+//
+// Alternatives are separated by commas, and may contain braces of their own,
+// so a{b,c{d,e}} expands to ab, acd and ace. Braces that are escaped, inside a
+// character class, unclosed, or without a comma between them, such as {a},
+// are left alone. If the rules a pattern expanded into are written out with
+// WriteTo, and are still next to each other with the same owners and
+// comment, they're collapsed back into the pattern with braces.
+func WithBraceExpansion() ParseOption {
+	return func(opts *parseOptions) {
+		opts.braceExpansion = true
+	}
+}
+
+// expandRule expands the braces in a rule's pattern, returning a rule for each
+// pattern it expands into, or just the rule if there's nothing to expand.
+func expandRule(r Rule, opts parseOptions) ([]Rule, error) {
+	patterns, err := expandBraces(r.pattern.pattern)
+	if err != nil {
+		return nil, &ParseError{Column: patternColumn(r), Message: err.Error()}
+	}
+	if len(patterns) == 1 {
+		return []Rule{r}, nil
+	}
+
+	group := &braceGroup{pattern: r.pattern.pattern, size: len(patterns)}
+	rules := make([]Rule, len(patterns))
+	for i, p := range patterns {
+		pattern, err := parsePattern(p, 0, opts)
+		if err != nil {
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) {
+				parseErr.Column = patternColumn(r)
+				parseErr.Message = fmt.Sprintf("%s in expanded pattern %s", parseErr.Message, p)
+			}
+			return nil, err
+		}
+		rules[i] = r
+		rules[i].pattern = pattern
+		rules[i].brace = group
+		// Owners are copied so that changing one rule's doesn't change the
+		// others'
+		rules[i].Owners = append([]Owner(nil), r.Owners...)
+	}
+	return rules, nil
+}
+
+// expandBraces returns the patterns a pattern expands into, in the order a
+// shell would give them, so {a,b}{c,d} gives ac, ad, bc and bd.
+func expandBraces(pattern string) ([]string, error) {
+	open, close, commas := findBraces(pattern)
+	if open < 0 {
+		return []string{pattern}, nil
+	}
+
+	var patterns []string
+	start := open + 1
+	for _, end := range append(commas, close) {
+		expanded, err := expandBraces(pattern[:open] + pattern[start:end] + pattern[close+1:])
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, expanded...)
+		if len(patterns) > maxBraceExpansions {
+			return nil, fmt.Errorf("braces expand into more than %d patterns", maxBraceExpansions)
+		}
+		start = end + 1
+	}
+	return patterns, nil
+}
+
+// findBraces finds the first pair of braces in a pattern that can be expanded,
+// returning the indexes of the braces and of the commas between them, which
+// aren't inside another pair. open is -1 if there aren't any.
+func findBraces(pattern string) (open, close int, commas []int) {
+	for open = 0; open < len(pattern); open++ {
+		switch pattern[open] {
+		case '\\':
+			open++
+		case '[':
+			if _, end, err := parseBracketExpr(pattern, open); end >= 0 && err == nil {
+				open = end
+			}
+		case '{':
+			if close, commas = matchBrace(pattern, open); close >= 0 && len(commas) > 0 {
+				return open, close, commas
+			}
+		}
+	}
+	return -1, -1, nil
+}
+
+// matchBrace returns the index of the } closing the { at open, or -1 if it
+// isn't closed, along with the indexes of the commas directly between them.
+func matchBrace(pattern string, open int) (int, []int) {
+	var commas []int
+	depth := 0
+	for i := open + 1; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '[':
+			if _, end, err := parseBracketExpr(pattern, i); end >= 0 && err == nil {
+				i = end
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i, commas
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				commas = append(commas, i)
+			}
+		}
+	}
+	return -1, nil
+}
+
+// collapsedBraces returns the number of rules from the start of a ruleset that
+// were expanded from a single pattern with braces and can still be written
+// out as that pattern, or 1 if there aren't several.
+func (r Ruleset) collapsedBraces() int {
+	group := r[0].brace
+	if group == nil || len(r) < group.size {
+		return 1
+	}
+	text := r[0].textWithPattern(group.pattern)
+	for i := 1; i < group.size; i++ {
+		rule := r[i]
+		if rule.brace != group || len(rule.Leading) > 0 || rule.Section != r[0].Section || rule.textWithPattern(group.pattern) != text {
+			return 1
+		}
+	}
+	// The rules must still be the ones the pattern expands into, in order
+	patterns, err := expandBraces(group.pattern)
+	if err != nil || len(patterns) != group.size {
+		return 1
+	}
+	for i, p := range patterns {
+		if r[i].pattern.pattern != p {
+			return 1
+		}
+	}
+	return group.size
+}
@@ -0,0 +1,116 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandBraces(t *testing.T) {
+	examples := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.{js,ts}", []string{"*.js", "*.ts"}},
+		{"{a,b}{c,d}", []string{"ac", "ad", "bc", "bd"}},
+		{"a{b,c{d,e}}", []string{"ab", "acd", "ace"}},
+		{"a{,b}", []string{"a", "ab"}},
+		{"{src,lib}/**/*.go", []string{"src/**/*.go", "lib/**/*.go"}},
+		// Braces without a comma, unclosed, escaped or in a character class
+		// aren't expanded
+		{"{a}", []string{"{a}"}},
+		{"a{b,c", []string{"a{b,c"}},
+		{`\{a,b\}`, []string{`\{a,b\}`}},
+		{`{a\,b}`, []string{`{a\,b}`}},
+		{"[{,}]", []string{"[{,}]"}},
+		{"{[,],x}", []string{"[,]", "x"}},
+		{"{a}{b,c}", []string{"{a}b", "{a}c"}},
+	}
+	for _, e := range examples {
+		t.Run(e.pattern, func(t *testing.T) {
+			got, err := expandBraces(e.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, e.want, got)
+		})
+	}
+
+	_, err := expandBraces(strings.Repeat("{a,b}", 11))
+	assert.EqualError(t, err, "braces expand into more than 1024 patterns")
+}
+
+func TestParseFileBraceExpansion(t *testing.T) {
+	file := "* @org/team\n*.{js,ts} @org/web @alice # frontend\n"
+
+	// Without the option, commas aren't allowed in patterns
+	_, err := ParseFile(strings.NewReader(file))
+	assert.EqualError(t, err, "line 2: unexpected character ',' at position 6")
+
+	rules, err := ParseFile(strings.NewReader(file), WithBraceExpansion())
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 3) {
+		for i, pattern := range []string{"*.js", "*.ts"} {
+			rule := rules[i+1]
+			assert.Equal(t, pattern, rule.RawPattern())
+			assert.Equal(t, 2, rule.LineNumber)
+			assert.Equal(t, "frontend", rule.Comment)
+			assert.Equal(t, []Owner{{Value: "org/web", Type: TeamOwner}, {Value: "alice", Type: UsernameOwner}}, rule.Owners)
+		}
+		rules[1].Owners[0].Value = "org/other"
+		assert.Equal(t, "org/web", rules[2].Owners[0].Value)
+
+		owner, err := rules.Match("src/app.ts")
+		assert.NoError(t, err)
+		assert.Equal(t, rules[2], *owner)
+	}
+
+	// Negated rules stay negated
+	rules, err = ParseFile(strings.NewReader("* @org/team\n!*.{md,txt}\n"), WithBraceExpansion(), WithNegation())
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 3) {
+		assert.Equal(t, "!*.md", rules[1].RawPattern())
+		assert.Equal(t, "!*.txt", rules[2].RawPattern())
+	}
+
+	// Errors in the expanded patterns are reported at the pattern
+	_, err = ParseFile(strings.NewReader("* @org/team\na{*,}** @alice\n"), WithBraceExpansion())
+	assert.EqualError(t, err, "line 2: pattern cannot contain three consecutive asterisks in expanded pattern a*** at position 1")
+	_, err = ParseFile(strings.NewReader(strings.Repeat("{a,b}", 11)+" @alice\n"), WithBraceExpansion())
+	assert.EqualError(t, err, "line 1: braces expand into more than 1024 patterns at position 1")
+}
+
+func TestRulesetWriteToBraceExpansion(t *testing.T) {
+	file := "# Frontend\n*.{js,ts}   @org/web # frontend\n\n{docs,README}* @org/docs\n"
+	for _, opts := range [][]ParseOption{
+		{WithBraceExpansion()},
+		{WithBraceExpansion(), WithComments()},
+	} {
+		rules, err := ParseFile(strings.NewReader(file), opts...)
+		assert.NoError(t, err)
+		assert.Len(t, rules, 4)
+
+		want := "*.{js,ts} @org/web # frontend\n{docs,README}* @org/docs\n"
+		if len(opts) > 1 {
+			want = file
+		}
+		assert.Equal(t, want, rules.String())
+
+		// Changing all of the expanded rules the same way still collapses
+		// them, but changing one writes them separately
+		for i := range rules[:2] {
+			rules[i].Owners = append(rules[i].Owners, Owner{Value: "alice", Type: UsernameOwner})
+		}
+		rules[3].Comment = "readme"
+		want = "*.{js,ts} @org/web @alice # frontend\ndocs* @org/docs\nREADME* @org/docs # readme\n"
+		if len(opts) > 1 {
+			want = "# Frontend\n*.{js,ts}   @org/web @alice # frontend\n\ndocs* @org/docs\nREADME* @org/docs # readme\n"
+		}
+		assert.Equal(t, want, rules.String())
+
+		// As does removing one
+		rules = append(rules[:1], rules[2:]...)
+		if len(opts) > 1 {
+			assert.Equal(t, "# Frontend\n*.js   @org/web @alice # frontend\n\ndocs* @org/docs\nREADME* @org/docs # readme\n", rules.String())
+		}
+	}
+}
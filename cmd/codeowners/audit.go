@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+)
+
+// runAudit runs the audit command, which walks the tree looking for problems
+// with the rules in the CODEOWNERS file, exiting with a non-zero status if
+// any are found.
+func runAudit(args []string) int {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	var (
+		rulesetFlags  rulesetFlags
+		walkFlags     walkFlags
+		unusedRules   bool
+		shadowedRules bool
+		unusedOwners  bool
+		strict        bool
+	)
+	rulesetFlags.register(fs)
+	walkFlags.register(fs)
+	fs.BoolVar(&unusedRules, "unused-rules", false, "report rules that don't match any files")
+	fs.BoolVar(&shadowedRules, "shadowed-rules", false, "report rules that can never match a file, as a later rule always takes precedence")
+	fs.BoolVar(&unusedOwners, "unused-owners", false, "report owners that don't own any files")
+	fs.BoolVar(&strict, "strict", false, "exit with an error if there are unused owners too")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners audit [flags] [<path>...]\n\n")
+		fmt.Fprintf(os.Stderr, "Runs every check unless specific checks are requested.\n\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := walkFlags.checkConflicts(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+	if walkFlags.submodules == "recurse" {
+		fmt.Fprintln(os.Stderr, "error: --submodules=recurse can't be used with audit, as a submodule's rules are audited in its own repository")
+		return 1
+	}
+	// With no particular checks requested, run all of them
+	all := !unusedRules && !shadowedRules && !unusedOwners
+	unusedRules = unusedRules || all
+	shadowedRules = shadowedRules || all
+	unusedOwners = unusedOwners || all
+
+	walkOpts, err := walkFlags.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	ruleset, err := rulesetFlags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var problems []ruleProblem
+	// Shadowed rules can be found without walking the tree, and are left out
+	// of the unused rules so that they aren't reported twice
+	shadowed := make(map[*codeowners.Rule]bool)
+	if shadowedRules {
+		for _, s := range ruleset.ShadowedRules() {
+			shadowed[s.Rule] = true
+			problems = append(problems, ruleProblem{
+				rule:   s.Rule,
+				reason: fmt.Sprintf("shadowed by line %d: %s", s.ShadowedBy.LineNumber, describeRule(*s.ShadowedBy)),
+			})
+		}
+	}
+
+	var owners []declaredOwner
+	if unusedRules || unusedOwners {
+		usage := newRuleUsage(ruleset)
+		err = matchPaths(
+			func(send func(string) error) error {
+				return walkPaths(paths, walkOpts, send)
+			},
+			func(path string) (*result, error) {
+				return nil, usage.record(walkOpts.repo.relativePath(path))
+			},
+			func(res result) error { return nil },
+		)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		if unusedRules {
+			for _, unused := range usage.unused() {
+				if !shadowed[unused.rule] {
+					problems = append(problems, unused)
+				}
+			}
+		}
+		if unusedOwners {
+			owners = usage.unusedOwners()
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].rule.LineNumber < problems[j].rule.LineNumber
+	})
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for _, p := range problems {
+		fmt.Fprintf(out, "line %d: %s (%s)\n", p.rule.LineNumber, describeRule(*p.rule), p.reason)
+	}
+	for _, o := range owners {
+		lines := make([]string, 0, len(o.rules))
+		for _, r := range o.rules {
+			lines = append(lines, strconv.Itoa(r.LineNumber))
+		}
+		fmt.Fprintf(out, "owner %s: owns no files (referenced on line %s)\n", o.owner, strings.Join(lines, ", "))
+	}
+	// Owners stick around when the files they owned are deleted, which isn't
+	// as pressing as broken rules, so only fail for them when asked to
+	if len(problems) > 0 || (strict && len(owners) > 0) {
+		return 1
+	}
+	return 0
+}
+
+// describeRule returns a rule's pattern followed by its owners, as they'd
+// appear in a CODEOWNERS file.
+func describeRule(rule codeowners.Rule) string {
+	parts := []string{rule.RawPattern()}
+	for _, o := range rule.Owners {
+		parts = append(parts, o.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// ruleUsage records how often each rule in a ruleset matches the paths it's
+// shown. It's safe for concurrent use.
+type ruleUsage struct {
+	ruleset codeowners.Ruleset
+
+	// index holds the position of each rule in the ruleset.
+	index map[*codeowners.Rule]int
+
+	mu sync.Mutex
+	// wins counts the paths for which each rule was the matching rule in
+	// its section.
+	wins []int
+	// matches counts the paths each rule's pattern matched, whether or not
+	// a later rule took precedence.
+	matches []int
+}
+
+func newRuleUsage(ruleset codeowners.Ruleset) *ruleUsage {
+	index := make(map[*codeowners.Rule]int, len(ruleset))
+	for i := range ruleset {
+		index[&ruleset[i]] = i
+	}
+	return &ruleUsage{
+		ruleset: ruleset,
+		index:   index,
+		wins:    make([]int, len(ruleset)),
+		matches: make([]int, len(ruleset)),
+	}
+}
+
+// record matches a path against every rule in the ruleset.
+func (u *ruleUsage) record(path string) error {
+	var matched []int
+	for i := len(u.ruleset) - 1; i >= 0; i-- {
+		ok, err := u.ruleset[i].Match(path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			matched = append(matched, i)
+		}
+	}
+	// The last matching rule in each section takes precedence, as every
+	// section's matching rule adds its owners
+	winners, err := u.ruleset.MatchSections(path)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, i := range matched {
+		u.matches[i]++
+	}
+	for _, rule := range winners {
+		u.wins[u.index[rule]]++
+	}
+	return nil
+}
+
+// unusedOwners returns the owners referenced by rules in the ruleset that
+// aren't owners of any path, as none of their rules were the matching rule
+// for one.
+func (u *ruleUsage) unusedOwners() []declaredOwner {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var unused []declaredOwner
+	for _, o := range declaredOwners(u.ruleset, false) {
+		owns := false
+		for _, r := range o.rules {
+			owns = owns || u.wins[u.index[r]] > 0
+		}
+		if !owns {
+			unused = append(unused, o)
+		}
+	}
+	return unused
+}
+
+// ruleProblem is a problem with a rule found by the audit command.
+type ruleProblem struct {
+	rule   *codeowners.Rule
+	reason string
+}
+
+// unused returns the rules that weren't the matching rule in their section for
+// any path, in the order they appear in the ruleset.
+func (u *ruleUsage) unused() []ruleProblem {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var unused []ruleProblem
+	for i := range u.ruleset {
+		if u.wins[i] > 0 {
+			continue
+		}
+		// Rules that match files but are always overridden need to be
+		// removed or moved, rather than having their patterns fixed
+		reason := "matches no files"
+		if u.matches[i] > 0 {
+			reason = "shadowed by later rules"
+		}
+		unused = append(unused, ruleProblem{rule: &u.ruleset[i], reason: reason})
+	}
+	return unused
+}
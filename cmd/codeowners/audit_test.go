@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleUsageUnused(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader(strings.Join([]string{
+		"*.go @go",
+		"/old/ @old",
+		"/src/main.go @main",
+		"*.go @other",
+		"/docs/ @docs",
+	}, "\n")))
+	require.NoError(t, err)
+
+	usage := newRuleUsage(ruleset)
+	for _, path := range []string{"src/main.go", "src/util.go", "docs/index.md", "README.md"} {
+		require.NoError(t, usage.record(path))
+	}
+
+	assert.Equal(t, []ruleProblem{
+		{rule: &ruleset[0], reason: "shadowed by later rules"},
+		{rule: &ruleset[1], reason: "matches no files"},
+		{rule: &ruleset[2], reason: "shadowed by later rules"},
+	}, usage.unused())
+}
+
+func TestRuleUsageUnusedOwners(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader(strings.Join([]string{
+		"* @everyone",
+		"/old/ @old @docs",
+		"/docs/ @docs",
+		"/src/ @old-team @src",
+		"/src/ @src",
+	}, "\n")))
+	require.NoError(t, err)
+
+	usage := newRuleUsage(ruleset)
+	for _, path := range []string{"src/main.go", "docs/index.md", "README.md"} {
+		require.NoError(t, usage.record(path))
+	}
+
+	assert.Equal(t, []declaredOwner{
+		{owner: "@old", rules: []*codeowners.Rule{&ruleset[1]}},
+		{owner: "@old-team", rules: []*codeowners.Rule{&ruleset[3]}},
+	}, usage.unusedOwners())
+}
+
+func TestRuleUsageSections(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("[Docs]\n/docs/ @org/docs\n[Backend]\n* @org/backend\n"))
+	require.NoError(t, err)
+	assert.Empty(t, ruleset.ShadowedRules())
+
+	// Each section's matching rule owns the file, not just the last
+	usage := newRuleUsage(ruleset)
+	require.NoError(t, usage.record("docs/a.md"))
+	assert.Empty(t, usage.unused())
+	assert.Empty(t, usage.unusedOwners())
+}
+
+func TestRuleUsageGitea(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader(".* @org/everyone\n.*\\.go @org/go\ndocs/.* @org/docs\n"), codeowners.WithDialect(codeowners.DialectGitea))
+	require.NoError(t, err)
+
+	// Every matching rule applies with Gitea's dialect, so earlier rules
+	// aren't overridden by later ones
+	usage := newRuleUsage(ruleset)
+	require.NoError(t, usage.record("main.go"))
+	assert.Equal(t, []ruleProblem{{rule: &ruleset[2], reason: "matches no files"}}, usage.unused())
+	assert.Equal(t, []declaredOwner{{owner: "@org/docs", rules: []*codeowners.Rule{&ruleset[2]}}}, usage.unusedOwners())
+}
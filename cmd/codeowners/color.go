@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hmarr/codeowners"
+)
+
+// ANSI escape sequences used to colorize text output.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// ownerColors maps owner types to the color they're shown in.
+var ownerColors = map[string]string{
+	codeowners.TeamOwner:     ansiCyan,
+	codeowners.UsernameOwner: ansiGreen,
+	codeowners.EmailOwner:    ansiYellow,
+	codeowners.GroupOwner:    ansiCyan,
+}
+
+// useColor decides whether output should be colorized, given the value of the
+// --color flag. In auto mode, color is used when stdout is a terminal and the
+// NO_COLOR environment variable (https://no-color.org) isn't set.
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		return isTerminal(os.Stdout), nil
+	}
+	return false, fmt.Errorf("unknown color mode %q", mode)
+}
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in the escape sequences for the color provided.
+func colorize(s, color string) string {
+	if color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// colorizeOwner returns the string representation of an owner, colored
+// according to its type.
+func colorizeOwner(o codeowners.Owner) string {
+	return colorize(o.String(), ownerColors[o.Type])
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+)
+
+// completionScripts holds the completion script for each supported shell.
+// The scripts ask the hidden __complete command for candidates, and fall back
+// to completing file names when there aren't any.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for codeowners
+_codeowners() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    COMPREPLY=($(codeowners __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [ ${#COMPREPLY[@]} -eq 0 ]; then
+        COMPREPLY=($(compgen -f -- "$cur"))
+    fi
+}
+complete -o filenames -F _codeowners codeowners
+`,
+	"zsh": `#compdef codeowners
+# zsh completion for codeowners
+_codeowners() {
+    local -a candidates
+    candidates=("${(@f)$(codeowners __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if [[ -n "${candidates[*]}" ]]; then
+        compadd -- "${candidates[@]}"
+    else
+        _files
+    fi
+}
+compdef _codeowners codeowners
+`,
+	"fish": `# fish completion for codeowners
+function __codeowners_complete
+    codeowners __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c codeowners -a '(__codeowners_complete)'
+`,
+}
+
+// ownerFlags are the flags whose values are owners.
+var ownerFlags = map[string]bool{"-o": true, "--owner": true, "-O": true, "--not-owner": true}
+
+// runCompletion runs the completion command, which prints a shell completion
+// script.
+func runCompletion(args []string) int {
+	fs := flag.NewFlagSet("completion", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners completion <bash|zsh|fish>\n\n")
+		fmt.Fprintf(os.Stderr, "To load completions in bash, for example, add this to ~/.bashrc:\n\n")
+		fmt.Fprintf(os.Stderr, "  source <(codeowners completion bash)\n")
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "error: expected a shell")
+		fs.Usage()
+		return 2
+	}
+	script, ok := completionScripts[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: unsupported shell %q\n", fs.Arg(0))
+		return 1
+	}
+	fmt.Print(script)
+	return 0
+}
+
+// runComplete runs the hidden __complete command, which the completion
+// scripts use to find candidates for the word being completed.
+func runComplete(args []string) int {
+	for _, c := range completions(args) {
+		fmt.Println(c)
+	}
+	return 0
+}
+
+// completions returns the candidates for the last of the words on the command
+// line, excluding the program name. The words before it are used to find what
+// it is. If nothing can be suggested, such as for paths, nil is returned so
+// that the shell can complete file names instead.
+func completions(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur, prev := words[len(words)-1], words[:len(words)-1]
+	if len(prev) > 0 {
+		if _, ok := commands[prev[0]]; ok {
+			// Subcommands' arguments are mostly paths
+			return nil
+		}
+	}
+
+	// Find the flag whose value is being completed, if any. Bash splits
+	// --owner=value into three words, while the other shells keep it
+	// together, so the prefix has to be included in the candidates.
+	flagName, prefix := "", ""
+	switch {
+	case len(prev) > 0 && ownerFlags[prev[len(prev)-1]]:
+		flagName = prev[len(prev)-1]
+	case len(prev) > 1 && prev[len(prev)-1] == "=":
+		flagName = prev[len(prev)-2]
+	case strings.HasPrefix(cur, "--") && strings.Contains(cur, "="):
+		i := strings.Index(cur, "=")
+		flagName, prefix, cur = cur[:i], cur[:i+1], cur[i+1:]
+	}
+	if ownerFlags[flagName] {
+		// Owner flags take comma-separated lists
+		if i := strings.LastIndex(cur, ","); i >= 0 {
+			prefix, cur = prefix+cur[:i+1], cur[i+1:]
+		}
+		return withPrefix(prefix, matchingPrefix(completionOwners(prev), cur))
+	}
+	if flagName != "" {
+		return nil
+	}
+
+	if strings.HasPrefix(cur, "-") {
+		fs := flag.NewFlagSet("codeowners", flag.ContinueOnError)
+		var root rootFlags
+		root.register(fs)
+		var names []string
+		fs.VisitAll(func(f *flag.Flag) {
+			names = append(names, "--"+f.Name)
+		})
+		return matchingPrefix(names, cur)
+	}
+	if len(prev) == 0 {
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return matchingPrefix(names, cur)
+	}
+	return nil
+}
+
+// completionOwners returns the owners declared in the CODEOWNERS files named
+// by the -f flags among the words, or the file at the standard location.
+func completionOwners(words []string) []string {
+	var paths []string
+	settings := parseSettings{dialect: codeowners.DialectGitHub}
+	for i, w := range words {
+		switch {
+		case (w == "-f" || w == "--file") && i+1 < len(words):
+			paths = append(paths, words[i+1])
+		case strings.HasPrefix(w, "--file="):
+			paths = append(paths, strings.TrimPrefix(w, "--file="))
+		case w == "--dialect" && i+1 < len(words):
+			settings.dialect = codeowners.Dialect(words[i+1])
+		case strings.HasPrefix(w, "--dialect="):
+			settings.dialect = codeowners.Dialect(strings.TrimPrefix(w, "--dialect="))
+		case w == "--negation":
+			settings.negation = true
+		case w == "--lenient-owners":
+			settings.lenientOwners = true
+		case w == "--brace-expansion":
+			settings.braceExpansion = true
+		}
+	}
+	if stdinCount(paths) > 0 {
+		return nil
+	}
+	ruleset, err := loadCodeowners(paths, settings)
+	if err != nil {
+		return nil
+	}
+	return ownerNames(ruleset)
+}
+
+// ownerNames returns the name of each owner declared in the ruleset.
+func ownerNames(ruleset codeowners.Ruleset) []string {
+	var names []string
+	for _, o := range declaredOwners(ruleset, false) {
+		names = append(names, o.owner)
+	}
+	return names
+}
+
+// matchingPrefix returns the candidates that start with prefix.
+func matchingPrefix(candidates []string, prefix string) []string {
+	var matching []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matching = append(matching, c)
+		}
+	}
+	return matching
+}
+
+// withPrefix prepends prefix to each of the candidates.
+func withPrefix(prefix string, candidates []string) []string {
+	if prefix == "" {
+		return candidates
+	}
+	prefixed := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		prefixed = append(prefixed, prefix+c)
+	}
+	return prefixed
+}
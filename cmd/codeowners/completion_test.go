@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "CODEOWNERS")
+	require.NoError(t, os.WriteFile(path, []byte("* @org/everyone\n/docs/ @org/docs @alice\n/src/ @Alice bob@example.com\n"), 0o644))
+
+	examples := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{"owner values", []string{"-f", path, "-o", ""}, []string{"@alice", "@org/docs", "@org/everyone", "bob@example.com"}},
+		{"owner prefix", []string{"-f", path, "--not-owner", "@org/"}, []string{"@org/docs", "@org/everyone"}},
+		{"owner with equals", []string{"--file=" + path, "--owner=@org/d"}, []string{"--owner=@org/docs"}},
+		{"owner with split equals", []string{"-f", path, "--owner", "=", "@org/e"}, []string{"@org/everyone"}},
+		{"owner lists", []string{"-f", path, "-o", "@alice,b"}, []string{"@alice,bob@example.com"}},
+		{"missing file", []string{"-f", path + ".missing", "-o", ""}, nil},
+		{"flags", []string{"--prun"}, []string{"--prune"}},
+		{"other flag values", []string{"--format", ""}, nil},
+		{"commands", []string{"co"}, []string{"completion", "convert", "coverage"}},
+		{"paths", []string{"src/", ""}, nil},
+		{"subcommand arguments", []string{"explain", ""}, nil},
+	}
+	for _, e := range examples {
+		t.Run(e.name, func(t *testing.T) {
+			assert.Equal(t, e.want, completions(e.words))
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+)
+
+// roleOwner is the owner type for GitLab role mentions, such as @@developer,
+// which the convert command understands so that it can translate them.
+const roleOwner = "role"
+
+var (
+	// sectionHeaderRegexp matches GitLab section headers, capturing the
+	// optional marker, the name, the number of approvals required, and any
+	// default owners.
+	sectionHeaderRegexp = regexp.MustCompile(`\A(\^?)\[([^\]]+)\](?:\[(\d+)\])?(?:\s+(.*))?\z`)
+	roleRegexp          = regexp.MustCompile(`\A@@([a-zA-Z_]+)\z`)
+)
+
+// dialectOwnerMatchers matches the owners of both GitHub and GitLab, for the
+// commands that rewrite CODEOWNERS files without changing their meaning.
+var dialectOwnerMatchers = []codeowners.OwnerMatcher{
+	codeowners.OwnerMatchFunc(matchRoleOwner),
+	codeowners.OwnerMatchFunc(codeowners.MatchEmailOwner),
+	codeowners.OwnerMatchFunc(codeowners.MatchTeamOwner),
+	codeowners.OwnerMatchFunc(codeowners.MatchUsernameOwner),
+}
+
+// parseForRewriting parses the contents of a CODEOWNERS file for a command
+// that rewrites it, with any other options given.
+func parseForRewriting(contents []byte, settings parseSettings, opts ...codeowners.ParseOption) (codeowners.Ruleset, error) {
+	settings.rewriting = true
+	return codeowners.ParseFile(bytes.NewReader(contents), append(settings.options(), opts...)...)
+}
+
+// matchRoleOwner matches a GitLab role mention.
+func matchRoleOwner(s string) (codeowners.Owner, error) {
+	match := roleRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return codeowners.Owner{}, codeowners.ErrNoMatch
+	}
+	// Owners are shown with an @ in front of their value, so keep one here
+	return codeowners.Owner{Value: "@" + match[1], Type: roleOwner}, nil
+}
+
+// runConvert runs the convert command, which rewrites a CODEOWNERS file for a
+// different platform.
+func runConvert(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	var path, to string
+	fs.StringVarP(&path, "file", "f", "", "CODEOWNERS file path (- for stdin)")
+	fs.StringVar(&to, "to", "", "dialect to convert to (github, gitlab)")
+	var parse parseFlags
+	parse.register(fs)
+	registerGitFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners convert --to <dialect> [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Constructs the target doesn't support are translated or dropped, with a warning.\n\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+	if to != "github" && to != "gitlab" {
+		if to == "" {
+			fmt.Fprintln(os.Stderr, "error: --to is required")
+		} else {
+			fmt.Fprintf(os.Stderr, "error: unknown dialect %q\n", to)
+		}
+		fs.Usage()
+		return 2
+	}
+
+	d, err := codeowners.ParseDialect(parse.dialect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if path == "" {
+		path = d.FindFile()
+		if path == "" {
+			fmt.Fprintln(os.Stderr, "error: could not find CODEOWNERS file at any of the standard locations")
+			return 1
+		}
+	}
+	var r io.Reader = os.Stdin
+	if path == "-" {
+		path = "<stdin>"
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	warnings, err := convertCodeowners(out, contents, parse.settings(), to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", fileError(path, err))
+		return 1
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s:%d: %s\n", path, w.Line, w.Message)
+	}
+	return 0
+}
+
+// convertCodeowners writes the CODEOWNERS file contents, parsed with the given
+// settings, in the given dialect,
+// returning warnings about anything that couldn't be carried over exactly.
+// Comments and blank lines are kept, and rules are normalized.
+func convertCodeowners(w io.Writer, contents []byte, settings parseSettings, to string) ([]finding, error) {
+	ruleset, err := parseForRewriting(contents, settings)
+	if err != nil {
+		return nil, err
+	}
+	rules := make(map[int]codeowners.Rule, len(ruleset))
+	for _, rule := range ruleset {
+		rules[rule.LineNumber] = rule
+	}
+
+	var warnings []finding
+	warn := func(line int, format string, args ...interface{}) {
+		warnings = append(warnings, finding{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, line := range fileLines(contents) {
+		lineNo := i + 1
+		line = strings.TrimSpace(line)
+
+		var converted string
+		if rule, ok := rules[lineNo]; ok {
+			converted = convertRule(rule, to, func(format string, args ...interface{}) {
+				warn(lineNo, format, args...)
+			})
+		} else if match := sectionHeaderRegexp.FindStringSubmatch(line); match != nil {
+			optional, name, approvals := match[1] != "", strings.TrimSpace(match[2]), match[3]
+			defaultOwners := strings.Fields(match[4])
+			if to == "gitlab" {
+				converted = match[1] + "[" + name + "]"
+				if approvals != "" {
+					converted += "[" + approvals + "]"
+				}
+				converted = strings.Join(append([]string{converted}, defaultOwners...), " ")
+			} else {
+				// GitHub has no sections, so keep the name as a comment
+				converted = "# [" + name + "]"
+				warn(lineNo, "section %q flattened, as GitHub doesn't support sections", name)
+				if optional {
+					warn(lineNo, "section %q is optional, but GitHub requires approval from code owners", name)
+				}
+				if approvals != "" {
+					warn(lineNo, "section %q requires %s approvals, which GitHub doesn't support", name, approvals)
+				}
+			}
+		} else {
+			// Blank lines and comments
+			converted = line
+		}
+
+		if _, err := fmt.Fprintln(w, converted); err != nil {
+			return nil, err
+		}
+	}
+	return warnings, nil
+}
+
+// convertRule renders a rule in the given dialect, calling warn for anything
+// that can't be carried over. Rules that inherit their section's default
+// owners keep relying on them for GitLab, but without sections, they have to
+// be listed on each rule for GitHub.
+func convertRule(rule codeowners.Rule, to string, warn func(format string, args ...interface{})) string {
+	listed := rule.Owners
+	if to == "gitlab" {
+		listed = listedOwners(rule)
+	}
+	var owners []string
+	for _, o := range listed {
+		if o.Type == roleOwner && to == "github" {
+			warn("role mention %s dropped, as GitHub doesn't support roles", o)
+			continue
+		}
+		owners = append(owners, o.String())
+	}
+	owners = append(owners, rule.InvalidOwners...)
+	if to == "github" && len(rule.Owners) > 0 && len(owners) == 0 {
+		warn("rule has no owners left, so matching files will be unowned")
+	}
+
+	converted := strings.Join(append([]string{rule.RawPattern()}, owners...), " ")
+	if rule.Comment != "" {
+		converted += " # " + rule.Comment
+	}
+	return converted
+}
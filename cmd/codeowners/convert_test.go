@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertCodeowners(t *testing.T) {
+	contents := strings.Join([]string{
+		"# Comment",
+		"*.go   @org/team  # Go code",
+		"",
+		"^[Docs][2] @docs @@maintainer",
+		"*.md",
+		"/docs/  @alice",
+		"[Ops]",
+		"/ops/ @@developer",
+		"",
+	}, "\n")
+
+	var buf bytes.Buffer
+	warnings, err := convertCodeowners(&buf, []byte(contents), parseSettings{dialect: codeowners.DialectGitHub}, "github")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"# Comment",
+		"*.go @org/team # Go code",
+		"",
+		"# [Docs]",
+		"*.md @docs",
+		"/docs/ @alice",
+		"# [Ops]",
+		"/ops/",
+		"",
+	}, "\n"), buf.String())
+	assert.Equal(t, []finding{
+		{Line: 4, Message: `section "Docs" flattened, as GitHub doesn't support sections`},
+		{Line: 4, Message: `section "Docs" is optional, but GitHub requires approval from code owners`},
+		{Line: 4, Message: `section "Docs" requires 2 approvals, which GitHub doesn't support`},
+		{Line: 5, Message: "role mention @@maintainer dropped, as GitHub doesn't support roles"},
+		{Line: 7, Message: `section "Ops" flattened, as GitHub doesn't support sections`},
+		{Line: 8, Message: "role mention @@developer dropped, as GitHub doesn't support roles"},
+		{Line: 8, Message: "rule has no owners left, so matching files will be unowned"},
+	}, warnings)
+
+	buf.Reset()
+	warnings, err = convertCodeowners(&buf, []byte(contents), parseSettings{dialect: codeowners.DialectGitHub}, "gitlab")
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, strings.Replace(strings.Replace(contents, "   @org/team  ", " @org/team ", 1), "  @alice", " @alice", 1), buf.String())
+}
+
+func TestConvertCodeownersRoundTrip(t *testing.T) {
+	contents := "# Owners\n\n* @org/everyone\n/src/ @alice bob@example.com\n/vendor/\n"
+	for _, to := range []string{"github", "gitlab"} {
+		var buf bytes.Buffer
+		warnings, err := convertCodeowners(&buf, []byte(contents), parseSettings{dialect: codeowners.DialectGitHub}, to)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.Equal(t, contents, buf.String())
+	}
+
+	// Files are parsed with the settings given
+	contents = "* @org/everyone\n!/vendor/\n"
+	var buf bytes.Buffer
+	_, err := convertCodeowners(&buf, []byte(contents), parseSettings{dialect: codeowners.DialectGitHub}, "gitlab")
+	assert.Error(t, err)
+	warnings, err := convertCodeowners(&buf, []byte(contents), parseSettings{dialect: codeowners.DialectGitHub, negation: true}, "gitlab")
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, contents, buf.String())
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hmarr/codeowners"
+	"github.com/mattn/go-runewidth"
+	flag "github.com/spf13/pflag"
+)
+
+// runCoverage runs the coverage command, which reports the proportion of
+// files that have an owner.
+func runCoverage(args []string) int {
+	fs := flag.NewFlagSet("coverage", flag.ContinueOnError)
+	var (
+		rulesetFlags rulesetFlags
+		walkFlags    walkFlags
+		byDirectory  bool
+		failUnder    float64
+	)
+	rulesetFlags.register(fs)
+	walkFlags.register(fs)
+	fs.BoolVar(&byDirectory, "by-directory", false, "also show the coverage of each top-level directory")
+	fs.Float64Var(&failUnder, "fail-under", 0, "exit with an error if the percentage of owned files is below this")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners coverage [flags] [<path>...]\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := walkFlags.checkConflicts(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+	if failUnder < 0 || failUnder > 100 {
+		fmt.Fprintf(os.Stderr, "error: --fail-under must be between 0 and 100\n")
+		return 1
+	}
+
+	walkOpts, err := walkFlags.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	ruleset, err := rulesetFlags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	walkOpts.useSettings(rulesetFlags.settings())
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	cov := newCoverage(paths)
+	compiled, err := ruleset.Compile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	err = matchPaths(
+		func(send func(string) error) error {
+			return walkPaths(paths, walkOpts, send)
+		},
+		func(path string) (*result, error) {
+			rule, err := walkOpts.match(compiled, walkOpts.repo.relativePath(path))
+			if err != nil {
+				return nil, err
+			}
+			cov.record(path, rule)
+			return nil, nil
+		},
+		func(res result) error { return nil },
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if err := cov.write(out, byDirectory); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if fs.Changed("fail-under") && cov.total.percentage() < failUnder {
+		out.Flush()
+		fmt.Fprintf(os.Stderr, "error: coverage of %.1f%% is below %.1f%%\n", cov.total.percentage(), failUnder)
+		return 1
+	}
+	return 0
+}
+
+// coverageCount counts owned and unowned files.
+type coverageCount struct {
+	owned int
+	total int
+}
+
+// percentage returns the percentage of files that are owned. If there aren't
+// any files, there's nothing left to own, so it's 100%.
+func (c coverageCount) percentage() float64 {
+	if c.total == 0 {
+		return 100
+	}
+	return float64(c.owned) / float64(c.total) * 100
+}
+
+// coverage records file ownership across the paths being walked, and within
+// each of their top-level directories. It's safe for concurrent use.
+type coverage struct {
+	startPaths []string
+
+	mu    sync.Mutex
+	total coverageCount
+	dirs  map[string]*coverageCount
+}
+
+func newCoverage(startPaths []string) *coverage {
+	return &coverage{startPaths: startPaths, dirs: make(map[string]*coverageCount)}
+}
+
+// record counts a file, given the rule it matched, which may be nil.
+func (c *coverage) record(path string, rule *codeowners.Rule) {
+	owned := rule != nil && len(rule.Owners) > 0
+	dir := c.topLevelDir(path)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count := c.dirs[dir]
+	if count == nil {
+		count = &coverageCount{}
+		c.dirs[dir] = count
+	}
+	for _, counter := range []*coverageCount{&c.total, count} {
+		counter.total++
+		if owned {
+			counter.owned++
+		}
+	}
+}
+
+// topLevelDir returns the directory directly beneath the start path that
+// contains a file, with a trailing slash. Files directly inside a start path,
+// or that are start paths themselves, are grouped under the start path.
+func (c *coverage) topLevelDir(path string) string {
+	for _, start := range c.startPaths {
+		rel, err := filepath.Rel(start, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if i := strings.IndexRune(rel, filepath.Separator); i >= 0 {
+			return filepath.Join(start, rel[:i]) + string(filepath.Separator)
+		}
+		return start
+	}
+	return path
+}
+
+func (c *coverage) write(w io.Writer, byDirectory bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if byDirectory {
+		dirs := make([]string, 0, len(c.dirs))
+		width := 0
+		for dir := range c.dirs {
+			dirs = append(dirs, dir)
+			if n := runewidth.StringWidth(dir); n > width {
+				width = n
+			}
+		}
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			count := c.dirs[dir]
+			padded := runewidth.FillRight(dir, width)
+			if _, err := fmt.Fprintf(w, "%s  %d/%d  %.1f%%\n", padded, count.owned, count.total, count.percentage()); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%d of %d files owned (%.1f%%), %d unowned\n",
+		c.total.owned, c.total.total, c.total.percentage(), c.total.total-c.total.owned)
+	return err
+}
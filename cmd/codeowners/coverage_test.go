@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverage(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("/src/ @org/team\n/docs/ @user\n/docs/generated/\n"))
+	require.NoError(t, err)
+
+	cov := newCoverage([]string{".", "../lib"})
+	for _, path := range []string{
+		"README.md",
+		"src/main.go",
+		"src/util/util.go",
+		"docs/index.md",
+		"docs/generated/api.md",
+		"../lib/lib.go",
+	} {
+		rule, err := ruleset.Match(path)
+		require.NoError(t, err)
+		cov.record(path, rule)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, cov.write(&buf, true))
+	assert.Equal(t, strings.Join([]string{
+		".       0/1  0.0%",
+		"../lib  0/1  0.0%",
+		"docs/   1/2  50.0%",
+		"src/    2/2  100.0%",
+		"",
+		"3 of 6 files owned (50.0%), 3 unowned",
+		"",
+	}, "\n"), buf.String())
+
+	assert.Equal(t, 100.0, coverageCount{}.percentage())
+}
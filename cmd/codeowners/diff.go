@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// runDiff runs the diff command, which shows the owners of the files changed
+// between two git revisions, followed by every owner of the change.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	var rulesetFlags rulesetFlags
+	rulesetFlags.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners diff [flags] <base>..<head>\n")
+		fmt.Fprintf(os.Stderr, "       codeowners diff [flags] <base>\n\n")
+		fmt.Fprintf(os.Stderr, "With a single revision, changes in the working tree since it are shown.\n\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "error: expected a single revision range")
+		fs.Usage()
+		return 2
+	}
+
+	ruleset, err := rulesetFlags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	paths, err := getChangedFiles(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	formatter := newTextFormatter(out, formatOptions{})
+	owners := make(map[string]bool)
+	for _, path := range paths {
+		rule, err := matchRule(ruleset, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		res := filters{}.apply(path, rule)
+		for _, o := range res.owners {
+			owners[o.String()] = true
+		}
+		if err := formatter.write(*res); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+	if err := formatter.close(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if len(owners) > 0 {
+		union := make([]string, 0, len(owners))
+		for o := range owners {
+			union = append(union, o)
+		}
+		sort.Strings(union)
+		fmt.Fprintf(out, "\nowners: %s\n", strings.Join(union, " "))
+	}
+	return 0
+}
+
+// getChangedFiles returns the files changed in a revision range, as accepted
+// by git diff, relative to the root of the repository.
+func getChangedFiles(revisions string) ([]string, error) {
+	if strings.HasPrefix(revisions, "-") {
+		return nil, fmt.Errorf("invalid revision range %q", revisions)
+	}
+	out, err := gitOutput("diff", "--name-status", "-z", "-M", revisions, "--")
+	if err != nil {
+		return nil, err
+	}
+	return parseNameStatus(out)
+}
+
+// parseNameStatus parses the output of git diff --name-status -z, returning
+// the paths that were changed. Deleted files are reported by the path they
+// were deleted from, and renamed or copied files by their new path.
+func parseNameStatus(out []byte) ([]string, error) {
+	if len(out) == 0 {
+		return nil, nil
+	}
+	fields := bytes.Split(bytes.TrimSuffix(out, []byte{0}), []byte{0})
+
+	var paths []string
+	for i := 0; i < len(fields); i++ {
+		status := string(fields[i])
+		if status == "" {
+			return nil, fmt.Errorf("unexpected output from git diff")
+		}
+		// Renames and copies are followed by the old path, then the new one
+		n := 1
+		if status[0] == 'R' || status[0] == 'C' {
+			n = 2
+		}
+		if i+n >= len(fields) {
+			return nil, fmt.Errorf("unexpected output from git diff")
+		}
+		i += n
+		paths = append(paths, string(fields[i]))
+	}
+	return paths, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNameStatus(t *testing.T) {
+	out := "M\x00src/main.go\x00D\x00docs/old.md\x00R087\x00lib/a.go\x00pkg/a.go\x00C100\x00x.txt\x00y.txt\x00"
+	paths, err := parseNameStatus([]byte(out))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"src/main.go", "docs/old.md", "pkg/a.go", "y.txt"}, paths)
+
+	paths, err = parseNameStatus(nil)
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+
+	_, err = parseNameStatus([]byte("R100\x00lib/a.go\x00"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	"github.com/mattn/go-runewidth"
+	flag "github.com/spf13/pflag"
+)
+
+// runDrift runs the drift command, which compares the owners of each file with
+// the people who have actually been changing it.
+func runDrift(args []string) int {
+	fs := flag.NewFlagSet("drift", flag.ContinueOnError)
+	var (
+		rulesetFlags rulesetFlags
+		since        string
+		mapPath      string
+		top          int
+		depth        int
+		minDrift     float64
+		format       string
+	)
+	rulesetFlags.register(fs)
+	fs.StringVar(&since, "since", "6.months", "only consider commits more recent than this date")
+	fs.StringVar(&mapPath, "map", "", "file mapping commit author emails to owners, one \"<email> <owner>\" pair per line")
+	fs.IntVarP(&top, "top", "n", 3, "number of top contributors to show for each file")
+	fs.IntVar(&depth, "depth", 0, "roll files up into directories this many levels below the repository root (0 to show each file)")
+	fs.Float64Var(&minDrift, "min-drift", 0, "only show files and directories with at least this drift")
+	fs.StringVar(&format, "format", "text", "output format (text, json)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners drift [flags] [<path>...]\n\n")
+		fmt.Fprintf(os.Stderr, "Drift is the percentage of recently changed lines written by someone other\n")
+		fmt.Fprintf(os.Stderr, "than the owners.\n\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if top <= 0 {
+		fmt.Fprintf(os.Stderr, "error: invalid --top %d\n", top)
+		return 1
+	}
+	if depth < 0 {
+		fmt.Fprintf(os.Stderr, "error: invalid --depth %d\n", depth)
+		return 1
+	}
+	if minDrift < 0 || minDrift > 100 {
+		fmt.Fprintln(os.Stderr, "error: --min-drift must be between 0 and 100")
+		return 1
+	}
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "error: unknown format %q\n", format)
+		return 1
+	}
+
+	authorMap, err := readAuthorMap(mapPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	ruleset, err := rulesetFlags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	// Files that have been deleted since don't need owners any more
+	tracked, err := getTrackedFiles(findRepository(), false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	changes, err := recentChanges(since, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	report := newDriftReport(authorMap, depth)
+	for _, c := range changes {
+		if !tracked[c.path] {
+			continue
+		}
+		rule, err := matchRule(ruleset, c.path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		report.record(c, rule)
+	}
+
+	rows := report.rows(top, minDrift)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if format == "json" {
+		err = writeDriftJSON(out, rows)
+	} else {
+		err = writeDrift(out, rows)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// fileChange is the number of lines a commit's author added or removed in a
+// file.
+type fileChange struct {
+	email string
+	path  string
+	lines int
+}
+
+// recentChanges returns the changes made to each file by the commits since a
+// date, limited to the paths provided if there are any. Paths are relative
+// to the repository root.
+func recentChanges(since string, paths []string) ([]fileChange, error) {
+	// Each commit's author is marked with a leading \x01, so it can't be
+	// mistaken for a file, and renames are treated as a deletion and an
+	// addition so that every file is listed by its own path
+	args := []string{"log", "-z", "--numstat", "--no-renames", "--format=%x01%ae"}
+	if since != "" {
+		args = append(args, "--since="+since)
+	}
+	out, err := gitOutput(append(append(args, "--"), paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseNumstat(string(out))
+}
+
+// parseNumstat parses the output of git log -z --numstat, with each commit
+// introduced by its author's email address preceded by \x01.
+func parseNumstat(out string) ([]fileChange, error) {
+	var changes []fileChange
+	email := ""
+	for _, entry := range strings.Split(out, "\x00") {
+		entry = strings.TrimPrefix(entry, "\n")
+		if entry == "" {
+			continue
+		}
+		if entry[0] == '\x01' {
+			email = entry[1:]
+			continue
+		}
+		fields := strings.SplitN(entry, "\t", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected git log output %q", entry)
+		}
+		lines := 0
+		// Binary files are listed with - in place of line counts, but
+		// changing one still counts for something
+		if fields[0] == "-" {
+			lines = 1
+		} else {
+			for _, field := range fields[:2] {
+				n, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("unexpected git log output %q", entry)
+				}
+				lines += n
+			}
+		}
+		changes = append(changes, fileChange{email: email, path: fields[2], lines: lines})
+	}
+	return changes, nil
+}
+
+// driftGroup accumulates the changes to a file, or to the files in a
+// directory.
+type driftGroup struct {
+	owners map[string]bool
+	// ownerLines counts the changed lines written by the owners of the
+	// files they were changed in.
+	ownerLines int
+	totalLines int
+	// contributors counts the changed lines written by each contributor.
+	contributors map[string]int
+}
+
+// driftReport compares the owners of files with who has been changing them.
+type driftReport struct {
+	authorMap map[string]string
+	depth     int
+	groups    map[string]*driftGroup
+}
+
+func newDriftReport(authorMap map[string]string, depth int) *driftReport {
+	return &driftReport{authorMap: authorMap, depth: depth, groups: make(map[string]*driftGroup)}
+}
+
+// record counts a change to a file, given the rule the file matched, which
+// may be nil. An author counts as an owner if their email address is one of
+// the rule's owners, or maps to one of them in the author map.
+func (d *driftReport) record(c fileChange, rule *codeowners.Rule) {
+	email := strings.ToLower(c.email)
+	contributor, ok := d.authorMap[email]
+	if !ok {
+		contributor = email
+	}
+
+	key := d.groupKey(c.path)
+	g := d.groups[key]
+	if g == nil {
+		g = &driftGroup{owners: make(map[string]bool), contributors: make(map[string]int)}
+		d.groups[key] = g
+	}
+	byOwner := false
+	if rule != nil {
+		for _, o := range rule.Owners {
+			g.owners[o.String()] = true
+			// As on GitHub, owners are compared case-insensitively
+			if strings.EqualFold(o.String(), contributor) || strings.EqualFold(o.String(), email) {
+				byOwner = true
+			}
+		}
+	}
+	g.totalLines += c.lines
+	if byOwner {
+		g.ownerLines += c.lines
+	}
+	g.contributors[contributor] += c.lines
+}
+
+// groupKey returns the file or directory a file's changes are counted under.
+// With a depth, files are rolled up into the directory that many levels below
+// the root containing them, with a trailing slash, or into the directory
+// they're in if it's not that deep. Files at the root are shown on their own.
+func (d *driftReport) groupKey(file string) string {
+	if d.depth == 0 {
+		return file
+	}
+	segments := strings.Split(file, "/")
+	if len(segments) <= d.depth {
+		if len(segments) == 1 {
+			return file
+		}
+		return path.Dir(file) + "/"
+	}
+	return strings.Join(segments[:d.depth], "/") + "/"
+}
+
+// driftRow is the drift command's summary of a file or directory.
+type driftRow struct {
+	Path   string   `json:"path"`
+	Owners []string `json:"owners"`
+	// Drift is the percentage of the changed lines written by someone other
+	// than the owners.
+	Drift        float64            `json:"drift"`
+	Lines        int                `json:"lines"`
+	Contributors []driftContributor `json:"contributors"`
+}
+
+// driftContributor is one of the people who changed a file the most.
+type driftContributor struct {
+	Contributor string `json:"contributor"`
+	Lines       int    `json:"lines"`
+	// Share is the percentage of the changed lines they wrote.
+	Share float64 `json:"share"`
+}
+
+// rows returns a row for each file or directory with at least minDrift, with
+// its top contributors, ordered from the most drift to the least.
+func (d *driftReport) rows(top int, minDrift float64) []driftRow {
+	rows := []driftRow{}
+	for key, g := range d.groups {
+		if g.totalLines == 0 {
+			continue
+		}
+		row := driftRow{
+			Path:   key,
+			Owners: []string{},
+			Drift:  float64(g.totalLines-g.ownerLines) / float64(g.totalLines) * 100,
+			Lines:  g.totalLines,
+		}
+		if row.Drift < minDrift {
+			continue
+		}
+		for owner := range g.owners {
+			row.Owners = append(row.Owners, owner)
+		}
+		sort.Strings(row.Owners)
+
+		for contributor, lines := range g.contributors {
+			share := float64(lines) / float64(g.totalLines) * 100
+			row.Contributors = append(row.Contributors, driftContributor{Contributor: contributor, Lines: lines, Share: share})
+		}
+		sort.Slice(row.Contributors, func(i, j int) bool {
+			a, b := row.Contributors[i], row.Contributors[j]
+			if a.Lines != b.Lines {
+				return a.Lines > b.Lines
+			}
+			return a.Contributor < b.Contributor
+		})
+		if len(row.Contributors) > top {
+			row.Contributors = row.Contributors[:top]
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Drift != rows[j].Drift {
+			return rows[i].Drift > rows[j].Drift
+		}
+		return rows[i].Path < rows[j].Path
+	})
+	return rows
+}
+
+func writeDrift(w io.Writer, rows []driftRow) error {
+	pathWidth := runewidth.StringWidth("PATH")
+	ownersWidth := runewidth.StringWidth("OWNERS")
+	owners := make([]string, len(rows))
+	for i, row := range rows {
+		owners[i] = strings.Join(row.Owners, " ")
+		if len(row.Owners) == 0 {
+			owners[i] = unownedRow
+		}
+		if n := runewidth.StringWidth(row.Path); n > pathWidth {
+			pathWidth = n
+		}
+		if n := runewidth.StringWidth(owners[i]); n > ownersWidth {
+			ownersWidth = n
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "%6s  %s  %s  %s\n", "DRIFT", runewidth.FillRight("PATH", pathWidth), runewidth.FillRight("OWNERS", ownersWidth), "TOP CONTRIBUTORS"); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		contributors := make([]string, 0, len(row.Contributors))
+		for _, c := range row.Contributors {
+			contributors = append(contributors, fmt.Sprintf("%s (%.0f%%)", c.Contributor, c.Share))
+		}
+		if _, err := fmt.Fprintf(w, "%5.1f%%  %s  %s  %s\n", row.Drift, runewidth.FillRight(row.Path, pathWidth), runewidth.FillRight(owners[i], ownersWidth), strings.Join(contributors, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDriftJSON(w io.Writer, rows []driftRow) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNumstat(t *testing.T) {
+	out := "\x01alice@example.com\x00\n3\t1\tsrc/main.go\x00-\t-\tassets/logo.png\x00" +
+		"\x01Bob@Example.com\x00\n0\t2\tdir/with\ttab.go\x00" +
+		"\x01empty@example.com\x00"
+	changes, err := parseNumstat(out)
+	require.NoError(t, err)
+	assert.Equal(t, []fileChange{
+		{email: "alice@example.com", path: "src/main.go", lines: 4},
+		{email: "alice@example.com", path: "assets/logo.png", lines: 1},
+		{email: "Bob@Example.com", path: "dir/with\ttab.go", lines: 2},
+	}, changes)
+
+	_, err = parseNumstat("\x01alice@example.com\x00\nnot numstat\x00")
+	assert.Error(t, err)
+}
+
+func TestDriftReport(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader(strings.Join([]string{
+		"/src/ @org/backend",
+		"/src/ui/ @carol",
+		"/docs/ dave@example.com",
+	}, "\n")))
+	require.NoError(t, err)
+	authorMap := map[string]string{
+		"alice@example.com": "@org/backend",
+		"carol@example.com": "@Carol",
+	}
+	changes := []fileChange{
+		{email: "alice@example.com", path: "src/api/api.go", lines: 30},
+		{email: "bob@example.com", path: "src/api/api.go", lines: 10},
+		{email: "Carol@example.com", path: "src/ui/app.ts", lines: 20},
+		{email: "bob@example.com", path: "src/ui/app.ts", lines: 20},
+		{email: "DAVE@example.com", path: "docs/guide.md", lines: 5},
+		{email: "bob@example.com", path: "README.md", lines: 1},
+	}
+	report := func(depth int) *driftReport {
+		d := newDriftReport(authorMap, depth)
+		for _, c := range changes {
+			rule, err := ruleset.Match(c.path)
+			require.NoError(t, err)
+			d.record(c, rule)
+		}
+		return d
+	}
+
+	rows := report(0).rows(1, 0)
+	assert.Equal(t, []driftRow{
+		{Path: "README.md", Owners: []string{}, Drift: 100, Lines: 1, Contributors: []driftContributor{{"bob@example.com", 1, 100}}},
+		{Path: "src/ui/app.ts", Owners: []string{"@carol"}, Drift: 50, Lines: 40, Contributors: []driftContributor{{"@Carol", 20, 50}}},
+		{Path: "src/api/api.go", Owners: []string{"@org/backend"}, Drift: 25, Lines: 40, Contributors: []driftContributor{{"@org/backend", 30, 75}}},
+		{Path: "docs/guide.md", Owners: []string{"dave@example.com"}, Drift: 0, Lines: 5, Contributors: []driftContributor{{"dave@example.com", 5, 100}}},
+	}, rows)
+
+	// Rolled up, each file's changes are still compared with its own owners
+	rows = report(1).rows(2, 30)
+	assert.Equal(t, []driftRow{
+		{Path: "README.md", Owners: []string{}, Drift: 100, Lines: 1, Contributors: []driftContributor{{"bob@example.com", 1, 100}}},
+		{Path: "src/", Owners: []string{"@carol", "@org/backend"}, Drift: 37.5, Lines: 80, Contributors: []driftContributor{
+			{"@org/backend", 30, 37.5},
+			{"bob@example.com", 30, 37.5},
+		}},
+	}, rows)
+
+	d := newDriftReport(nil, 2)
+	assert.Equal(t, "README.md", d.groupKey("README.md"))
+	assert.Equal(t, "src/", d.groupKey("src/main.go"))
+	assert.Equal(t, "src/api/", d.groupKey("src/api/v1/api.go"))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+)
+
+// explanation is the explain command's report for a single path.
+type explanation struct {
+	Path string `json:"path"`
+	// Rules holds every rule that matches the path, in file order.
+	Rules []explainedRule `json:"rules"`
+	// Owners holds the owners of the path, from the winning rules.
+	Owners []string `json:"owners"`
+}
+
+// explainedRule is a rule that matches a path being explained.
+type explainedRule struct {
+	Line    int      `json:"line"`
+	Pattern string   `json:"pattern"`
+	Owners  []string `json:"owners"`
+	Section string   `json:"section,omitempty"`
+	// Winning is set for the last matching rule, which takes precedence, or
+	// with sections, for the last matching rule in each section.
+	Winning bool `json:"winning"`
+}
+
+// runExplain runs the explain command, which shows every rule that matches a
+// path, to make it clear why it has the owners it does.
+func runExplain(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	var (
+		rulesetFlags rulesetFlags
+		format       string
+	)
+	rulesetFlags.register(fs)
+	fs.StringVar(&format, "format", "text", "output format (text, json)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners explain [flags] <path>...\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "error: expected a path to explain")
+		fs.Usage()
+		return 2
+	}
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "error: unknown format %q\n", format)
+		return 1
+	}
+
+	ruleset, err := rulesetFlags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	repo := findRepository()
+	explanations := make([]explanation, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		e, err := explain(ruleset, repo.relativePath(path))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		// Show the path as it was given, rather than as it was matched
+		e.Path = filepath.Clean(path)
+		explanations = append(explanations, e)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(explanations)
+	} else {
+		err = writeExplanations(out, explanations)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// explain finds the rules that match a path. With GitLab-style sections, the
+// last matching rule in each section wins, and the path's owners are those of
+// every winning rule.
+func explain(ruleset codeowners.Ruleset, path string) (explanation, error) {
+	winners, err := ruleset.MatchSections(path)
+	if err != nil {
+		return explanation{}, err
+	}
+	// The winners point into the ruleset, so they're told apart from other
+	// rules on the same line, such as those from another file or expanded
+	// from the same braces
+	winning := make(map[*codeowners.Rule]bool, len(winners))
+	for _, rule := range winners {
+		winning[rule] = true
+	}
+
+	e := explanation{Path: path, Rules: []explainedRule{}, Owners: []string{}}
+	// The last matching rule is the last of the winners in the ruleset
+	var last *codeowners.Rule
+	for i := range ruleset {
+		rule := &ruleset[i]
+		match, err := rule.Match(path)
+		if err != nil {
+			return explanation{}, err
+		}
+		if !match {
+			continue
+		}
+		last = rule
+		owners := []string{}
+		for _, o := range rule.Owners {
+			owners = append(owners, o.String())
+		}
+		e.Rules = append(e.Rules, explainedRule{
+			Line:    rule.LineNumber,
+			Pattern: rule.RawPattern(),
+			Owners:  owners,
+			Section: rule.Section,
+			Winning: winning[rule],
+		})
+	}
+	if rule := combineSections(winners, last); rule != nil {
+		for _, o := range rule.Owners {
+			e.Owners = append(e.Owners, o.String())
+		}
+	}
+	return e, nil
+}
+
+func writeExplanations(w io.Writer, explanations []explanation) error {
+	for i, e := range explanations {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if len(e.Rules) == 0 {
+			if _, err := fmt.Fprintf(w, "%s isn't matched by any rule, so it's unowned\n", e.Path); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := fmt.Fprintf(w, "%s is matched by:\n", e.Path); err != nil {
+			return err
+		}
+		for _, r := range e.Rules {
+			rule := strings.Join(append([]string{r.Pattern}, r.Owners...), " ")
+			switch {
+			case r.Winning && len(e.Owners) == 0:
+				rule += " (wins, so it's unowned)"
+			case r.Winning:
+				rule += " (wins)"
+			}
+			if r.Section != "" {
+				rule = "[" + r.Section + "] " + rule
+			}
+			if _, err := fmt.Fprintf(w, "  line %d: %s\n", r.Line, rule); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
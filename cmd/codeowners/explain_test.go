@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader(strings.Join([]string{
+		"* @org/everyone",
+		"*.go @org/go",
+		"[Generated]",
+		"/src/gen/",
+	}, "\n")))
+	require.NoError(t, err)
+
+	var explanations []explanation
+	for _, path := range []string{"src/main.go", "src/gen/api.go"} {
+		e, err := explain(ruleset, path)
+		require.NoError(t, err)
+		explanations = append(explanations, e)
+	}
+	e, err := explain(codeowners.Ruleset{}, "README.md")
+	require.NoError(t, err)
+	explanations = append(explanations, e)
+	e, err = explain(ruleset[2:], "src/gen/api.go")
+	require.NoError(t, err)
+	explanations = append(explanations, e)
+
+	assert.Equal(t, []string{"@org/go"}, explanations[0].Owners)
+	// The last match in each section wins, so the rule without owners in its
+	// own section doesn't make the file unowned
+	assert.Equal(t, []string{"@org/go"}, explanations[1].Owners)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeExplanations(&buf, explanations))
+	assert.Equal(t, strings.Join([]string{
+		"src/main.go is matched by:",
+		"  line 1: * @org/everyone",
+		"  line 2: *.go @org/go (wins)",
+		"",
+		"src/gen/api.go is matched by:",
+		"  line 1: * @org/everyone",
+		"  line 2: *.go @org/go (wins)",
+		"  line 4: [Generated] /src/gen/ (wins)",
+		"",
+		"README.md isn't matched by any rule, so it's unowned",
+		"",
+		"src/gen/api.go is matched by:",
+		"  line 4: [Generated] /src/gen/ (wins, so it's unowned)",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestExplainRulesOnTheSameLine(t *testing.T) {
+	// Rules from different files, or expanded from the same braces, share
+	// line numbers, but only the one that wins is marked as winning
+	base, err := codeowners.ParseFile(strings.NewReader("* @org/everyone\n*.go @org/go\n"))
+	require.NoError(t, err)
+	override, err := codeowners.ParseFile(strings.NewReader("/docs/ @org/docs\n*.go @org/go-reviewers\n"))
+	require.NoError(t, err)
+	expanded, err := codeowners.ParseFile(strings.NewReader("src/{*.go,main.*} @alice\n"), codeowners.WithBraceExpansion())
+	require.NoError(t, err)
+
+	e, err := explain(codeowners.Concat(base, override, expanded), "src/main.go")
+	require.NoError(t, err)
+	var winning []bool
+	for _, r := range e.Rules {
+		winning = append(winning, r.Winning)
+	}
+	assert.Equal(t, []bool{false, false, false, false, true}, winning)
+	assert.Equal(t, []string{"@alice"}, e.Owners)
+}
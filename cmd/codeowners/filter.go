@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+)
+
+// filters holds the flags that decide which results are shown.
+type filters struct {
+	// owners limits results to files owned by these owners (--owner).
+	owners []string
+	// ownerRegexps limits results to files owned by owners matching any of
+	// these patterns (--owner-regex), in addition to the owners filters.
+	ownerRegexps []*regexp.Regexp
+	// notOwners excludes files owned by any of these owners (--not-owner).
+	notOwners []string
+	// caseSensitive makes the owners and notOwners filters case-sensitive
+	// (--case-sensitive). GitHub ignores case, so by default they do too.
+	caseSensitive bool
+	// ownerTypes limits the owners shown to those of these types
+	// (--owner-type), using the library's owner type names.
+	ownerTypes []string
+	// unowned limits results to unowned files, in addition to those owned by
+	// any owners filters (--unowned).
+	unowned bool
+	// owned hides unowned files (--owned). It can't be combined with unowned.
+	owned bool
+	// optionalUnowned treats files whose owners all come from optional
+	// GitLab sections as unowned (--optional-unowned).
+	optionalUnowned bool
+	// minOwners, if positive, limits results to files whose rule has fewer
+	// than this many owners, or fewer than its section's required approvals,
+	// including unowned files (--min-owners).
+	minOwners int
+	// patterns limits results to files whose matching rule has one of these
+	// patterns (--pattern).
+	patterns []string
+	// patternRegexps limits results to files whose matching rule has a
+	// pattern matching any of these expressions (--pattern-regex), in
+	// addition to the patterns filters.
+	patternRegexps []*regexp.Regexp
+	// ruleLine, if positive, limits results to files whose matching rule is on
+	// this line of the CODEOWNERS file (--rule-line).
+	ruleLine int
+}
+
+// apply decides whether a path should be shown given the rule it matched,
+// which may be nil. It returns the result to show, or nil if the path was
+// filtered out.
+func (f filters) apply(path string, rule *codeowners.Rule) *result {
+	// Files with enough owners pass the policy, so there's nothing to report.
+	// Rules in GitLab-style sections that require more approvals than that
+	// need enough owners to give them.
+	if f.minOwners > 0 && rule != nil && len(rule.Owners) >= f.minOwners && len(rule.Owners) >= rule.MinApprovals {
+		return nil
+	}
+	if f.filteringByPattern() && (rule == nil || !f.matchesPattern(rule.RawPattern())) {
+		return nil
+	}
+	if f.ruleLine > 0 && (rule == nil || rule.LineNumber != f.ruleLine) {
+		return nil
+	}
+
+	// If we didn't get a match, the file is unowned
+	if rule == nil || rule.Owners == nil || f.optionalUnowned && rule.Optional {
+		// Unless explicitly requested, don't show unowned files if we're filtering by owner
+		if (!f.filteringByOwner() || f.unowned) && !f.owned {
+			return &result{path: path, unowned: true, rule: rule}
+		}
+		return nil
+	}
+
+	owners := rule.Owners
+	if len(f.ownerTypes) > 0 {
+		owners = f.ownersOfTypes(owners)
+		// If none of the owners are of the right type, then as far as the user
+		// is concerned the file is unowned
+		if len(owners) == 0 {
+			if f.unowned {
+				return &result{path: path, unowned: true, rule: rule}
+			}
+			return nil
+		}
+	}
+
+	// Figure out which of the owners we need to show according to the --owner filters
+	ownersToShow := make([]codeowners.Owner, 0, len(owners))
+	for _, o := range owners {
+		// If there are no filters, show all owners
+		filterMatch := !f.filteringByOwner() && !f.unowned
+		if filterMatch || f.matchesOwner(o) {
+			ownersToShow = append(ownersToShow, o)
+		}
+	}
+
+	// If the owners slice is empty, no owners matched the filters so don't show anything
+	if len(ownersToShow) == 0 {
+		return nil
+	}
+
+	// Exclusions are applied after the include filters, and to all of the
+	// file's owners rather than just the ones being shown
+	for _, o := range rule.Owners {
+		for _, filter := range f.notOwners {
+			if f.ownerEqual(filter, o) {
+				return nil
+			}
+		}
+	}
+
+	return &result{path: path, owners: ownersToShow, rule: rule}
+}
+
+// filteringByOwner reports whether any --owner or --owner-regex filters were
+// provided.
+func (f filters) filteringByOwner() bool {
+	return len(f.owners) > 0 || len(f.ownerRegexps) > 0
+}
+
+// matchesOwner reports whether an owner passes the --owner and --owner-regex
+// filters.
+func (f filters) matchesOwner(o codeowners.Owner) bool {
+	for _, filter := range f.owners {
+		if f.ownerEqual(filter, o) {
+			return true
+		}
+	}
+	for _, re := range f.ownerRegexps {
+		if re.MatchString(o.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// filteringByPattern reports whether any --pattern or --pattern-regex filters
+// were provided.
+func (f filters) filteringByPattern() bool {
+	return len(f.patterns) > 0 || len(f.patternRegexps) > 0
+}
+
+// matchesPattern reports whether a rule's pattern passes the --pattern and
+// --pattern-regex filters.
+func (f filters) matchesPattern(pattern string) bool {
+	for _, filter := range f.patterns {
+		if filter == pattern {
+			return true
+		}
+	}
+	for _, re := range f.patternRegexps {
+		if re.MatchString(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRules returns an error if any of the --pattern, --pattern-regex, or
+// --rule-line filters don't match a rule in the ruleset, as they're most
+// likely mistakes.
+func (f filters) checkRules(ruleset codeowners.Ruleset) error {
+	for _, pattern := range f.patterns {
+		if !rulesetHas(ruleset, func(p string) bool { return p == pattern }) {
+			return fmt.Errorf("no rule has the pattern %q", pattern)
+		}
+	}
+	for _, re := range f.patternRegexps {
+		if !rulesetHas(ruleset, re.MatchString) {
+			return fmt.Errorf("no rule has a pattern matching %q", re)
+		}
+	}
+	if f.ruleLine > 0 {
+		found := false
+		for _, rule := range ruleset {
+			if rule.LineNumber == f.ruleLine {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("there's no rule on line %d of the CODEOWNERS file", f.ruleLine)
+		}
+	}
+	return nil
+}
+
+// rulesetHas reports whether any rule in the ruleset has a pattern for which
+// match returns true.
+func rulesetHas(ruleset codeowners.Ruleset, match func(pattern string) bool) bool {
+	for _, rule := range ruleset {
+		if match(rule.RawPattern()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownerEqual reports whether an --owner or --not-owner filter, which has had
+// any leading @ removed, refers to an owner.
+func (f filters) ownerEqual(filter string, o codeowners.Owner) bool {
+	value := strings.TrimLeft(o.Value, "@")
+	if f.caseSensitive {
+		return filter == value
+	}
+	return strings.EqualFold(filter, value)
+}
+
+// ownersOfTypes returns the owners whose type passes the --owner-type filters.
+func (f filters) ownersOfTypes(owners []codeowners.Owner) []codeowners.Owner {
+	var matching []codeowners.Owner
+	for _, o := range owners {
+		for _, t := range f.ownerTypes {
+			if o.Type == t {
+				matching = append(matching, o)
+				break
+			}
+		}
+	}
+	return matching
+}
+
+// parseOwnerType converts an --owner-type value into the corresponding
+// library owner type.
+func parseOwnerType(s string) (string, error) {
+	switch s {
+	case "team":
+		return codeowners.TeamOwner, nil
+	case "user", "username":
+		return codeowners.UsernameOwner, nil
+	case "email":
+		return codeowners.EmailOwner, nil
+	case "group":
+		return codeowners.GroupOwner, nil
+	}
+	return "", fmt.Errorf("unknown owner type %q", s)
+}
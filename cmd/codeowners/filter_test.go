@@ -0,0 +1,307 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiltersApply(t *testing.T) {
+	team := codeowners.Owner{Value: "org/team", Type: codeowners.TeamOwner}
+	user := codeowners.Owner{Value: "user", Type: codeowners.UsernameOwner}
+	email := codeowners.Owner{Value: "user@example.com", Type: codeowners.EmailOwner}
+	mixedRule := &codeowners.Rule{Owners: []codeowners.Owner{team, user, email}}
+	userRule := &codeowners.Rule{Owners: []codeowners.Owner{user}}
+
+	examples := []struct {
+		name    string
+		filters filters
+		rule    *codeowners.Rule
+		// owners is nil when the file should be filtered out
+		owners  []codeowners.Owner
+		unowned bool
+	}{
+		{
+			name:   "no filters",
+			rule:   mixedRule,
+			owners: []codeowners.Owner{team, user, email},
+		},
+		{
+			name:    "no filters, unowned",
+			rule:    nil,
+			unowned: true,
+		},
+		{
+			name:    "owner filter",
+			filters: filters{owners: []string{"user"}},
+			rule:    mixedRule,
+			owners:  []codeowners.Owner{user},
+		},
+		{
+			name:    "owner filter, not matching",
+			filters: filters{owners: []string{"other"}},
+			rule:    mixedRule,
+		},
+		{
+			name:    "owner filter ignores case",
+			filters: filters{owners: []string{"Org/Team", "USER@example.com"}},
+			rule:    mixedRule,
+			owners:  []codeowners.Owner{team, email},
+		},
+		{
+			name:    "owner filter, case-sensitive",
+			filters: filters{owners: []string{"Org/Team"}, caseSensitive: true},
+			rule:    mixedRule,
+		},
+		{
+			name:    "owner filter hides unowned",
+			filters: filters{owners: []string{"user"}},
+			rule:    nil,
+		},
+		{
+			name:    "owner filter with unowned",
+			filters: filters{owners: []string{"user"}, unowned: true},
+			rule:    nil,
+			unowned: true,
+		},
+		{
+			name:    "unowned hides owned",
+			filters: filters{unowned: true},
+			rule:    mixedRule,
+		},
+		{
+			name:    "owner regex filter",
+			filters: filters{ownerRegexps: []*regexp.Regexp{regexp.MustCompile(`^org/`)}},
+			rule:    mixedRule,
+			owners:  []codeowners.Owner{team},
+		},
+		{
+			name:    "owner regex filter, not matching",
+			filters: filters{ownerRegexps: []*regexp.Regexp{regexp.MustCompile(`^other/`)}},
+			rule:    mixedRule,
+		},
+		{
+			name:    "owner regex filter hides unowned",
+			filters: filters{ownerRegexps: []*regexp.Regexp{regexp.MustCompile(`^org/`)}},
+			rule:    nil,
+		},
+		{
+			name: "owner regex and owner filters",
+			filters: filters{
+				owners:       []string{"user"},
+				ownerRegexps: []*regexp.Regexp{regexp.MustCompile(`@example\.com$`)},
+			},
+			rule:   mixedRule,
+			owners: []codeowners.Owner{user, email},
+		},
+		{
+			name:    "owned",
+			filters: filters{owned: true},
+			rule:    mixedRule,
+			owners:  []codeowners.Owner{team, user, email},
+		},
+		{
+			name:    "owned hides unowned",
+			filters: filters{owned: true},
+			rule:    nil,
+		},
+		{
+			name:    "owned hides rules without owners",
+			filters: filters{owned: true},
+			rule:    &codeowners.Rule{},
+		},
+		{
+			name:    "owned with owner filter",
+			filters: filters{owned: true, owners: []string{"user"}},
+			rule:    mixedRule,
+			owners:  []codeowners.Owner{user},
+		},
+		{
+			name:    "min-owners, section requires more approvals",
+			filters: filters{minOwners: 1},
+			rule:    &codeowners.Rule{Owners: []codeowners.Owner{user}, MinApprovals: 2},
+			owners:  []codeowners.Owner{user},
+		},
+		{
+			name:   "optional owners",
+			rule:   &codeowners.Rule{Owners: []codeowners.Owner{user}, Optional: true},
+			owners: []codeowners.Owner{user},
+		},
+		{
+			name:    "optional owners, optional-unowned",
+			filters: filters{optionalUnowned: true},
+			rule:    &codeowners.Rule{Owners: []codeowners.Owner{user}, Optional: true},
+			unowned: true,
+		},
+		{
+			name:    "required owners, optional-unowned",
+			filters: filters{optionalUnowned: true},
+			rule:    userRule,
+			owners:  []codeowners.Owner{user},
+		},
+		{
+			name:    "not-owner filter",
+			filters: filters{notOwners: []string{"user"}},
+			rule:    mixedRule,
+		},
+		{
+			name:    "not-owner filter ignores case",
+			filters: filters{notOwners: []string{"USER"}},
+			rule:    mixedRule,
+		},
+		{
+			name:    "not-owner filter, not matching",
+			filters: filters{notOwners: []string{"other"}},
+			rule:    mixedRule,
+			owners:  []codeowners.Owner{team, user, email},
+		},
+		{
+			name:    "not-owner filter applies to owners hidden by owner filter",
+			filters: filters{owners: []string{"org/team"}, notOwners: []string{"user"}},
+			rule:    mixedRule,
+		},
+		{
+			name:    "owner type filter",
+			filters: filters{ownerTypes: []string{codeowners.TeamOwner}},
+			rule:    mixedRule,
+			owners:  []codeowners.Owner{team},
+		},
+		{
+			name:    "multiple owner type filters",
+			filters: filters{ownerTypes: []string{codeowners.TeamOwner, codeowners.EmailOwner}},
+			rule:    mixedRule,
+			owners:  []codeowners.Owner{team, email},
+		},
+		{
+			name:    "owner type filter hides files with no owners of that type",
+			filters: filters{ownerTypes: []string{codeowners.TeamOwner}},
+			rule:    userRule,
+		},
+		{
+			name:    "owner type filter with unowned shows files with no owners of that type",
+			filters: filters{ownerTypes: []string{codeowners.TeamOwner}, unowned: true},
+			rule:    userRule,
+			unowned: true,
+		},
+		{
+			name:    "owner type filter with unowned hides files with owners of that type",
+			filters: filters{ownerTypes: []string{codeowners.TeamOwner}, unowned: true},
+			rule:    mixedRule,
+		},
+		{
+			name:    "owner type and owner filters",
+			filters: filters{owners: []string{"user", "org/team"}, ownerTypes: []string{codeowners.UsernameOwner}},
+			rule:    mixedRule,
+			owners:  []codeowners.Owner{user},
+		},
+		{
+			name:    "owner type and owner filters, not matching",
+			filters: filters{owners: []string{"org/team"}, ownerTypes: []string{codeowners.UsernameOwner}},
+			rule:    mixedRule,
+		},
+		{
+			name:    "min owners shows files with too few owners",
+			filters: filters{minOwners: 2},
+			rule:    userRule,
+			owners:  []codeowners.Owner{user},
+		},
+		{
+			name:    "min owners hides files with enough owners",
+			filters: filters{minOwners: 2},
+			rule:    mixedRule,
+		},
+		{
+			name:    "min owners shows unowned files",
+			filters: filters{minOwners: 2},
+			rule:    nil,
+			unowned: true,
+		},
+	}
+
+	for _, e := range examples {
+		t.Run(e.name, func(t *testing.T) {
+			res := e.filters.apply("file.txt", e.rule)
+			if e.owners == nil && !e.unowned {
+				assert.Nil(t, res)
+				return
+			}
+			if assert.NotNil(t, res) {
+				assert.Equal(t, e.unowned, res.unowned)
+				assert.Equal(t, e.owners, res.owners)
+			}
+		})
+	}
+}
+
+func TestFiltersPattern(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("*.go @user\n/docs/ @org/team\n"))
+	require.NoError(t, err)
+	goRule, docsRule := &ruleset[0], &ruleset[1]
+
+	f := filters{patterns: []string{"*.go"}}
+	assert.NotNil(t, f.apply("main.go", goRule))
+	assert.Nil(t, f.apply("docs/index.md", docsRule))
+	assert.Nil(t, f.apply("README.md", nil))
+	assert.NoError(t, f.checkRules(ruleset))
+
+	f = filters{patternRegexps: []*regexp.Regexp{regexp.MustCompile(`^/docs`)}}
+	assert.Nil(t, f.apply("main.go", goRule))
+	assert.NotNil(t, f.apply("docs/index.md", docsRule))
+	assert.NoError(t, f.checkRules(ruleset))
+
+	f = filters{patterns: []string{"*.md"}}
+	assert.EqualError(t, f.checkRules(ruleset), `no rule has the pattern "*.md"`)
+	f = filters{patternRegexps: []*regexp.Regexp{regexp.MustCompile(`^/src`)}}
+	assert.EqualError(t, f.checkRules(ruleset), "no rule has a pattern matching \"^/src\"")
+}
+
+func TestFiltersRuleLine(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("*.go @user\n\n# Docs\n/docs/ @org/team\n"))
+	require.NoError(t, err)
+	goRule, docsRule := &ruleset[0], &ruleset[1]
+
+	f := filters{ruleLine: 4}
+	assert.Nil(t, f.apply("main.go", goRule))
+	assert.NotNil(t, f.apply("docs/index.md", docsRule))
+	assert.Nil(t, f.apply("README.md", nil))
+	assert.NoError(t, f.checkRules(ruleset))
+
+	f = filters{ruleLine: 3}
+	assert.EqualError(t, f.checkRules(ruleset), "there's no rule on line 3 of the CODEOWNERS file")
+	f = filters{ruleLine: 40}
+	assert.EqualError(t, f.checkRules(ruleset), "there's no rule on line 40 of the CODEOWNERS file")
+}
+
+func TestFiltersNegation(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("* @org/everyone\n!docs/\ndocs/api/ @org/api\n[Go]\n*.go @org/go\n"), codeowners.WithNegation())
+	require.NoError(t, err)
+	unowned := func(path string) bool {
+		rule, err := matchRule(ruleset, path)
+		require.NoError(t, err)
+		res := filters{unowned: true}.apply(path, rule)
+		return res != nil && res.unowned
+	}
+
+	// Paths excluded by a negated rule are unowned, unless a later rule, or
+	// another section, owns them again
+	assert.True(t, unowned("docs/guide.md"))
+	assert.False(t, unowned("docs/api/index.md"))
+	assert.False(t, unowned("docs/main.go"))
+	assert.False(t, unowned("README.md"))
+}
+
+func TestFiltersCRLF(t *testing.T) {
+	// Owners at the end of lines don't keep the \r from Windows line endings
+	ruleset, err := codeowners.ParseFile(strings.NewReader("\ufeff*.go @org/team\r\n/docs/ @org/docs\r\n"))
+	require.NoError(t, err)
+	f := filters{owners: []string{"org/team"}}
+	res := f.apply("main.go", &ruleset[0])
+	if assert.NotNil(t, res) {
+		assert.Equal(t, []codeowners.Owner{{Value: "org/team", Type: codeowners.TeamOwner}}, res.owners)
+	}
+	assert.Nil(t, f.apply("docs/index.md", &ruleset[1]))
+}
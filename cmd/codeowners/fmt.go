@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	"github.com/mattn/go-runewidth"
+	flag "github.com/spf13/pflag"
+)
+
+// runFmt runs the fmt command, which rewrites a CODEOWNERS file with
+// consistent formatting.
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	var (
+		path  string
+		check bool
+		align bool
+	)
+	fs.StringVarP(&path, "file", "f", "", "CODEOWNERS file path (- to read from stdin and write to stdout)")
+	fs.BoolVar(&check, "check", false, "exit with an error if the file isn't formatted, rather than rewriting it")
+	fs.BoolVar(&align, "align", false, "align the owners of consecutive rules in a column")
+	var parse parseFlags
+	parse.register(fs)
+	registerGitFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners fmt [flags]\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	d, err := codeowners.ParseDialect(parse.dialect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if path == "" {
+		path = d.FindFile()
+		if path == "" {
+			fmt.Fprintln(os.Stderr, "error: could not find CODEOWNERS file at any of the standard locations")
+			return 1
+		}
+	}
+	var contents []byte
+	if path == "-" {
+		contents, err = io.ReadAll(os.Stdin)
+	} else {
+		contents, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	formatted, err := formatCodeowners(contents, parse.settings(), align)
+	if err != nil {
+		name := path
+		if path == "-" {
+			name = "<stdin>"
+		}
+		fmt.Fprintf(os.Stderr, "error: %v\n", fileError(name, err))
+		return 1
+	}
+
+	switch {
+	case check:
+		if !bytes.Equal(contents, formatted) {
+			if path == "-" {
+				path = "<stdin>"
+			}
+			fmt.Fprintf(os.Stderr, "%s isn't formatted\n", path)
+			return 1
+		}
+	case path == "-":
+		if _, err := os.Stdout.Write(formatted); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	case !bytes.Equal(contents, formatted):
+		info, err := os.Stat(path)
+		if err == nil {
+			err = os.WriteFile(path, formatted, info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// formattedLine is a line of a CODEOWNERS file being formatted. Rules are
+// kept in parts so that their owners can be aligned.
+type formattedLine struct {
+	text string
+	rule *codeowners.Rule
+}
+
+// formatCodeowners returns the contents of a CODEOWNERS file, parsed with the
+// given settings, formatted canonically. Comments, blank lines and the order of the rules are kept,
+// while whitespace is normalized: rules have a single space between their
+// pattern and each owner (or their owners aligned in a column, if align is
+// set), trailing whitespace and any byte order mark are removed, runs of
+// blank lines are collapsed and the file ends with a single newline.
+func formatCodeowners(contents []byte, settings parseSettings, align bool) ([]byte, error) {
+	contents = bytes.TrimPrefix(contents, []byte(byteOrderMark))
+	ruleset, err := parseForRewriting(contents, settings)
+	if err != nil {
+		return nil, err
+	}
+	rules := make(map[int]*codeowners.Rule, len(ruleset))
+	for i := range ruleset {
+		rules[ruleset[i].LineNumber] = &ruleset[i]
+	}
+
+	var lines []formattedLine
+	for i, line := range fileLines(contents) {
+		if rule, ok := rules[i+1]; ok {
+			lines = append(lines, formattedLine{rule: rule})
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && (len(lines) == 0 || (lines[len(lines)-1].rule == nil && lines[len(lines)-1].text == "")) {
+			// Drop leading blank lines, and collapse runs of them into one
+			continue
+		}
+		if len(line) > 0 && line[0] != '#' {
+			// Section headers are the only other lines a CODEOWNERS file can
+			// have
+			line = strings.Join(strings.Fields(line), " ")
+		}
+		lines = append(lines, formattedLine{text: line})
+	}
+	if n := len(lines); n > 0 && lines[n-1].rule == nil && lines[n-1].text == "" {
+		lines = lines[:n-1]
+	}
+
+	var buf bytes.Buffer
+	for start := 0; start < len(lines); {
+		if lines[start].rule == nil {
+			buf.WriteString(lines[start].text + "\n")
+			start++
+			continue
+		}
+
+		// Owners are aligned within each run of consecutive rules
+		end := start
+		width := 0
+		for ; end < len(lines) && lines[end].rule != nil; end++ {
+			if n := runewidth.StringWidth(lines[end].rule.RawPattern()); n > width {
+				width = n
+			}
+		}
+		for _, line := range lines[start:end] {
+			buf.WriteString(formatRule(*line.rule, width, align) + "\n")
+		}
+		start = end
+	}
+	return buf.Bytes(), nil
+}
+
+// formatRule formats a single rule, padding its pattern to the given width if
+// align is set.
+func formatRule(rule codeowners.Rule, width int, align bool) string {
+	parts := []string{rule.RawPattern()}
+	owners := listedOwners(rule)
+	if align && (len(owners) > 0 || len(rule.InvalidOwners) > 0 || rule.Comment != "") {
+		parts[0] = runewidth.FillRight(parts[0], width)
+	}
+	for _, o := range owners {
+		parts = append(parts, o.String())
+	}
+	// Owners ignored with --lenient-owners are kept, after the valid ones
+	parts = append(parts, rule.InvalidOwners...)
+	if rule.Comment != "" {
+		parts = append(parts, "# "+rule.Comment)
+	}
+	return strings.Join(parts, " ")
+}
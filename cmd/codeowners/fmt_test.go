@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatCodeowners(t *testing.T) {
+	contents := strings.Join([]string{
+		"",
+		"#   Owners  ",
+		"*.go    @org/team   @alice  ",
+		"/docs/**/secret   @security #sensitive",
+		"/vendor/",
+		"",
+		"",
+		"  [Docs]   @docs  ",
+		"*.md",
+		"",
+		"",
+	}, "\n")
+
+	formatted, err := formatCodeowners([]byte(contents), parseSettings{dialect: codeowners.DialectGitHub}, false)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"#   Owners",
+		"*.go @org/team @alice",
+		"/docs/**/secret @security # sensitive",
+		"/vendor/",
+		"",
+		"[Docs] @docs",
+		"*.md",
+		"",
+	}, "\n"), string(formatted))
+
+	formatted, err = formatCodeowners([]byte(contents), parseSettings{dialect: codeowners.DialectGitHub}, true)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"#   Owners",
+		"*.go            @org/team @alice",
+		"/docs/**/secret @security # sensitive",
+		"/vendor/",
+		"",
+		"[Docs] @docs",
+		"*.md",
+		"",
+	}, "\n"), string(formatted))
+
+	// Formatting is idempotent
+	again, err := formatCodeowners(formatted, parseSettings{dialect: codeowners.DialectGitHub}, true)
+	require.NoError(t, err)
+	assert.Equal(t, string(formatted), string(again))
+
+	// Windows line endings and byte order marks are removed
+	formatted, err = formatCodeowners([]byte("\ufeff*.go  @org/team\r\n/docs/ @docs \r\n"), parseSettings{dialect: codeowners.DialectGitHub}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "*.go @org/team\n/docs/ @docs\n", string(formatted))
+}
+
+func TestFormatCodeownersInvalid(t *testing.T) {
+	_, err := formatCodeowners([]byte("*.go @org/team\n*.md bad\n"), parseSettings{dialect: codeowners.DialectGitHub}, false)
+	assert.EqualError(t, err, "line 2: invalid owner format 'bad' at position 6")
+
+	// Files are parsed with the settings given, as the other commands parse
+	// them
+	_, err = formatCodeowners([]byte("*.go @org/team\n!/vendor/\n"), parseSettings{dialect: codeowners.DialectGitHub}, false)
+	assert.Error(t, err)
+	formatted, err := formatCodeowners([]byte("*.go  @org/team\n!/vendor/  \n"), parseSettings{dialect: codeowners.DialectGitHub, negation: true}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "*.go @org/team\n!/vendor/\n", string(formatted))
+	formatted, err = formatCodeowners([]byte("*.go  @org/go bad\n"), parseSettings{dialect: codeowners.DialectGitHub, lenientOwners: true}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "*.go @org/go bad\n", string(formatted))
+}
+
+func TestRewritingLongLines(t *testing.T) {
+	// Lines can be longer than bufio.Scanner's buffer, as generated files'
+	// sometimes are
+	owners := make([]string, 15000)
+	for i := range owners {
+		owners[i] = fmt.Sprintf("@org/generated-team-%d", i)
+	}
+	line := "/docs/ " + strings.Join(owners, " ")
+	require.Greater(t, len(line), 300000)
+	contents := "* @org/team\n" + line + "\n"
+	settings := parseSettings{dialect: codeowners.DialectGitHub}
+
+	formatted, err := formatCodeowners([]byte(contents), settings, false)
+	require.NoError(t, err)
+	assert.True(t, contents == string(formatted))
+
+	sorted, warnings, err := sortCodeowners([]byte(contents), settings)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.True(t, contents == string(sorted))
+
+	var buf bytes.Buffer
+	_, err = convertCodeowners(&buf, []byte(contents), settings, "gitlab")
+	require.NoError(t, err)
+	assert.True(t, contents == buf.String())
+}
@@ -0,0 +1,906 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hmarr/codeowners"
+	"github.com/mattn/go-runewidth"
+	"gopkg.in/yaml.v3"
+)
+
+// formatter writes match results to the output in a particular format.
+type formatter interface {
+	// write outputs a single result. Results are provided in input order.
+	write(res result) error
+	// close finishes the output, writing anything that had to wait until all
+	// results had been seen.
+	close() error
+}
+
+// formatOptions holds the settings that affect how results are rendered.
+type formatOptions struct {
+	// unownedLabel replaces the format's usual representation of the owners of
+	// an unowned file, if set.
+	unownedLabel string
+	// unownedOnly is set when the user asked to see unowned files.
+	unownedOnly bool
+	// annotationLevel is the severity of github-actions annotations.
+	annotationLevel string
+	// color enables colorized text output.
+	color bool
+	// columnWidth is the width of the path column in text output. If it's
+	// zero, the column is sized automatically.
+	columnWidth int
+	// ownerSummary follows text and JSON output with every owner of the
+	// results, for --patch.
+	ownerSummary bool
+	// showRule adds the rule that matched each result, and its line number.
+	showRule bool
+}
+
+// showRuleFormats are the formats that can show the rule that matched each
+// result.
+var showRuleFormats = map[string]bool{"csv": true, "json": true, "jsonl": true, "markdown": true, "text": true, "tsv": true}
+
+// ruleText returns a rule as it would appear in a CODEOWNERS file, with its
+// pattern followed by its owners, or "" if there's no rule.
+func ruleText(rule *codeowners.Rule) string {
+	if rule == nil {
+		return ""
+	}
+	fields := []string{rule.RawPattern()}
+	for _, o := range listedOwners(*rule) {
+		fields = append(fields, o.String())
+	}
+	return strings.Join(fields, " ")
+}
+
+// describeMatch describes the rule a path matched for --show-rule, given the
+// rule's text: with its line number, and noting when its owners are the
+// default owners of its section.
+func describeMatch(text string, rule *codeowners.Rule) string {
+	desc := fmt.Sprintf("%s, line %d", text, rule.LineNumber)
+	if rule.InheritsOwners {
+		desc += ", owners from section"
+	}
+	return desc
+}
+
+// listedOwners returns the owners listed on a rule's own line, leaving out
+// the default owners it inherited from its GitLab-style section.
+func listedOwners(rule codeowners.Rule) []codeowners.Owner {
+	if rule.InheritsOwners {
+		return nil
+	}
+	return rule.Owners
+}
+
+// sectionRuleText returns a rule as ruleText does, preceded by the name of
+// its section in brackets, as in the section's header.
+func sectionRuleText(rule *codeowners.Rule) string {
+	if rule.Section == "" {
+		return ruleText(rule)
+	}
+	return "[" + rule.Section + "] " + ruleText(rule)
+}
+
+// ruleColumns returns the pattern and line number columns for a result's rule,
+// or empty columns if no rule matched.
+func ruleColumns(rule *codeowners.Rule) []string {
+	if rule == nil {
+		return []string{"", ""}
+	}
+	return []string{rule.RawPattern(), strconv.Itoa(rule.LineNumber)}
+}
+
+// ownerSet collects the distinct owners of a set of results.
+type ownerSet map[string]bool
+
+func (s ownerSet) add(res result) {
+	for _, o := range res.ownerStrings() {
+		s[o] = true
+	}
+}
+
+// sorted returns the owners in order.
+func (s ownerSet) sorted() []string {
+	owners := make([]string, 0, len(s))
+	for o := range s {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// formatters maps the names accepted by --format to constructors for the
+// corresponding formatter.
+var formatters = map[string]func(w io.Writer, opts formatOptions) formatter{
+	"text":           newTextFormatter,
+	"json":           newJSONFormatter,
+	"jsonl":          newJSONLinesFormatter,
+	"csv":            newCSVFormatter,
+	"tsv":            newTSVFormatter,
+	"yaml":           newYAMLFormatter,
+	"markdown":       newMarkdownFormatter,
+	"sarif":          newSARIFFormatter,
+	"github-actions": newGitHubActionsFormatter,
+}
+
+// failer may be implemented by formatters whose output represents a failure,
+// such as a report of problems, in which case the tool exits non-zero.
+type failer interface {
+	failed() bool
+}
+
+// formatNames returns the sorted names of the available output formats.
+func formatNames() []string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ownerStrings returns the string representation of each of the result's
+// owners, in order.
+func (res result) ownerStrings() []string {
+	owners := make([]string, 0, len(res.owners))
+	for _, o := range res.owners {
+		owners = append(owners, o.String())
+	}
+	return owners
+}
+
+// optionalOwners returns the owners of a result that only come from optional
+// GitLab sections, so their approval isn't required, keyed by their lowercase
+// names.
+func (res result) optionalOwners() map[string]bool {
+	optional := make(map[string]bool)
+	required := make(map[string]bool)
+	for _, rule := range res.sections {
+		for _, o := range rule.Owners {
+			if rule.Optional {
+				optional[strings.ToLower(o.String())] = true
+			} else {
+				required[strings.ToLower(o.String())] = true
+			}
+		}
+	}
+	for owner := range required {
+		delete(optional, owner)
+	}
+	return optional
+}
+
+const (
+	// defaultColumnWidth is the width of the path column in text output when
+	// it can't be sized to fit the paths.
+	defaultColumnWidth = 70
+	// autoWidthLimit is the number of results the text formatter will hold
+	// back to size the path column before falling back to the default width.
+	autoWidthLimit = 1000
+)
+
+// textFormatter writes the default human-readable output: the path padded to a
+// column, followed by the owners.
+//
+// With a fixed column width, lines are written as results arrive. Otherwise
+// the column is sized to the widest path, which requires holding results back
+// until they've all been seen. To keep memory bounded on large walks, once
+// more than autoWidthLimit results have been held back the formatter gives up
+// and uses the default width for everything.
+type textFormatter struct {
+	w            io.Writer
+	unownedLabel string
+	// ownerString renders each owner, which allows them to be colorized.
+	ownerString func(o codeowners.Owner) string
+	// width is the width of the path column, or 0 while it's being determined.
+	width   int
+	pending []result
+	// owners collects the owners of the results if they're to be listed at
+	// the end.
+	owners   ownerSet
+	showRule bool
+}
+
+func newTextFormatter(w io.Writer, opts formatOptions) formatter {
+	label := opts.unownedLabel
+	if label == "" {
+		label = "(unowned)"
+	}
+	f := &textFormatter{
+		w:            w,
+		unownedLabel: label,
+		ownerString:  codeowners.Owner.String,
+		width:        opts.columnWidth,
+		showRule:     opts.showRule,
+	}
+	if opts.color {
+		f.unownedLabel = colorize(label, ansiRed)
+		f.ownerString = colorizeOwner
+	}
+	if opts.ownerSummary {
+		f.owners = make(ownerSet)
+	}
+	return f
+}
+
+func (f *textFormatter) write(res result) error {
+	if f.owners != nil {
+		f.owners.add(res)
+	}
+	if f.width > 0 {
+		return f.writeLine(res)
+	}
+
+	f.pending = append(f.pending, res)
+	if len(f.pending) > autoWidthLimit {
+		return f.flush(defaultColumnWidth)
+	}
+	return nil
+}
+
+func (f *textFormatter) close() error {
+	if f.width == 0 {
+		width := 0
+		for _, res := range f.pending {
+			if w := runewidth.StringWidth(res.path); w > width {
+				width = w
+			}
+		}
+		if err := f.flush(width); err != nil {
+			return err
+		}
+	}
+	if len(f.owners) > 0 {
+		_, err := fmt.Fprintf(f.w, "\nowners: %s\n", strings.Join(f.owners.sorted(), " "))
+		return err
+	}
+	return nil
+}
+
+// flush fixes the column width and writes out any held back results.
+func (f *textFormatter) flush(width int) error {
+	f.width = width
+	for _, res := range f.pending {
+		if err := f.writeLine(res); err != nil {
+			return err
+		}
+	}
+	f.pending = nil
+	return nil
+}
+
+func (f *textFormatter) writeLine(res result) error {
+	owners := f.unownedLabel
+	if !res.unowned {
+		optional := res.optionalOwners()
+		strs := make([]string, 0, len(res.owners))
+		for _, o := range res.owners {
+			str := f.ownerString(o)
+			if optional[strings.ToLower(o.String())] {
+				str += " (optional)"
+			}
+			strs = append(strs, str)
+		}
+		owners = strings.Join(strs, " ")
+	}
+
+	// Pad by display width rather than bytes or runes so that paths containing
+	// wide characters (e.g. CJK) still line up
+	padding := f.width - runewidth.StringWidth(res.path)
+	if padding < 0 {
+		padding = 0
+	}
+	if f.showRule && len(res.sections) > 0 {
+		for _, rule := range res.sections {
+			owners += "  [" + describeMatch(sectionRuleText(rule), rule) + "]"
+		}
+	} else if f.showRule && res.rule != nil {
+		owners += "  [" + describeMatch(ruleText(res.rule), res.rule) + "]"
+	}
+	_, err := fmt.Fprintf(f.w, "%s%s  %s\n", res.path, strings.Repeat(" ", padding), owners)
+	return err
+}
+
+// jsonResult is the JSON representation of a result.
+type jsonResult struct {
+	Path    string   `json:"path"`
+	Owners  []string `json:"owners"`
+	Unowned bool     `json:"unowned"`
+	Pattern string   `json:"pattern,omitempty"`
+	Line    int      `json:"line,omitempty"`
+	// Inherited is set when the owners are the default owners of the rule's
+	// GitLab-style section, and Approvals is the number of approvals the
+	// section requires, with --show-rule.
+	Inherited bool `json:"inherited,omitempty"`
+	Approvals int  `json:"approvals,omitempty"`
+	// Optional lists the owners that only come from optional GitLab
+	// sections, and Sections holds the rule that matched in each section of
+	// a CODEOWNERS file with sections.
+	Optional []string      `json:"optional,omitempty"`
+	Sections []jsonSection `json:"sections,omitempty"`
+}
+
+// jsonSection is the JSON representation of the rule that matched a path in
+// one section.
+type jsonSection struct {
+	Section   string   `json:"section"`
+	Owners    []string `json:"owners"`
+	Pattern   string   `json:"pattern"`
+	Line      int      `json:"line"`
+	Optional  bool     `json:"optional,omitempty"`
+	Inherited bool     `json:"inherited,omitempty"`
+	Approvals int      `json:"approvals,omitempty"`
+}
+
+// newJSONResult returns the JSON representation of a result, including the
+// rule it matched with --show-rule.
+func newJSONResult(res result, showRule bool) jsonResult {
+	jr := jsonResult{
+		Path:    res.path,
+		Owners:  res.ownerStrings(),
+		Unowned: res.unowned,
+	}
+	if showRule && res.rule != nil {
+		jr.Pattern = res.rule.RawPattern()
+		jr.Line = res.rule.LineNumber
+		jr.Inherited = res.rule.InheritsOwners
+		jr.Approvals = res.rule.MinApprovals
+	}
+	if optional := res.optionalOwners(); len(optional) > 0 {
+		for _, o := range res.owners {
+			if optional[strings.ToLower(o.String())] {
+				jr.Optional = append(jr.Optional, o.String())
+			}
+		}
+	}
+	for _, rule := range res.sections {
+		owners := make([]string, 0, len(rule.Owners))
+		for _, o := range rule.Owners {
+			owners = append(owners, o.String())
+		}
+		jr.Sections = append(jr.Sections, jsonSection{
+			Section:   rule.Section,
+			Owners:    owners,
+			Pattern:   rule.RawPattern(),
+			Line:      rule.LineNumber,
+			Optional:  rule.Optional,
+			Inherited: rule.InheritsOwners,
+			Approvals: rule.MinApprovals,
+		})
+	}
+	return jr
+}
+
+// jsonFormatter writes all results as a single JSON array. Elements are
+// written as results arrive so the whole array never has to be held in memory.
+//
+// With an owner summary, the array is the files field of an object whose
+// owners field lists every owner of the results.
+type jsonFormatter struct {
+	w        io.Writer
+	count    int
+	owners   ownerSet
+	showRule bool
+}
+
+func newJSONFormatter(w io.Writer, opts formatOptions) formatter {
+	f := &jsonFormatter{w: w, showRule: opts.showRule}
+	if opts.ownerSummary {
+		f.owners = make(ownerSet)
+	}
+	return f
+}
+
+func (f *jsonFormatter) write(res result) error {
+	data, err := json.Marshal(newJSONResult(res, f.showRule))
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n  "
+	if f.count == 0 {
+		sep = "[\n  "
+	}
+	if f.owners != nil {
+		f.owners.add(res)
+		sep = strings.ReplaceAll(sep, "\n", "\n  ")
+		if f.count == 0 {
+			sep = "{\n  \"files\": " + sep
+		}
+	}
+	f.count++
+	if _, err := io.WriteString(f.w, sep); err != nil {
+		return err
+	}
+	_, err = f.w.Write(data)
+	return err
+}
+
+func (f *jsonFormatter) close() error {
+	if f.owners != nil {
+		end := "\n  ]"
+		if f.count == 0 {
+			end = "{\n  \"files\": []"
+		}
+		owners, err := json.Marshal(f.owners.sorted())
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(f.w, "%s,\n  \"owners\": %s\n}\n", end, owners)
+		return err
+	}
+	if f.count == 0 {
+		_, err := io.WriteString(f.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(f.w, "\n]\n")
+	return err
+}
+
+// jsonLinesFormatter writes each result as a JSON object on its own line, so
+// output can be consumed as a stream while the walk is still in progress.
+type jsonLinesFormatter struct {
+	enc      *json.Encoder
+	showRule bool
+}
+
+func newJSONLinesFormatter(w io.Writer, opts formatOptions) formatter {
+	return &jsonLinesFormatter{enc: json.NewEncoder(w), showRule: opts.showRule}
+}
+
+func (f *jsonLinesFormatter) write(res result) error {
+	jr := newJSONResult(res, f.showRule)
+	// Lines always include the pattern, so that they can be filtered by
+	// rule as they stream
+	if res.rule != nil {
+		jr.Pattern = res.rule.RawPattern()
+	}
+	return f.enc.Encode(jr)
+}
+
+func (f *jsonLinesFormatter) close() error {
+	return nil
+}
+
+// csvFormatter writes a header row followed by one row per result, with the
+// owners space-separated in a single cell. With --show-rule, the pattern and
+// line number of the matching rule follow in two more.
+type csvFormatter struct {
+	w            *csv.Writer
+	unownedLabel string
+	wroteHeader  bool
+	showRule     bool
+}
+
+func newCSVFormatter(w io.Writer, opts formatOptions) formatter {
+	return &csvFormatter{w: csv.NewWriter(w), unownedLabel: opts.unownedLabel, showRule: opts.showRule}
+}
+
+func (f *csvFormatter) writeHeader() error {
+	f.wroteHeader = true
+	header := []string{"path", "owners"}
+	if f.showRule {
+		header = append(header, "pattern", "line")
+	}
+	return f.w.Write(header)
+}
+
+func (f *csvFormatter) write(res result) error {
+	if !f.wroteHeader {
+		if err := f.writeHeader(); err != nil {
+			return err
+		}
+	}
+	owners := f.unownedLabel
+	if !res.unowned {
+		owners = strings.Join(res.ownerStrings(), " ")
+	}
+	record := []string{res.path, owners}
+	if f.showRule {
+		record = append(record, ruleColumns(res.rule)...)
+	}
+	return f.w.Write(record)
+}
+
+func (f *csvFormatter) close() error {
+	// Always write the header, even if there were no results
+	if !f.wroteHeader {
+		if err := f.writeHeader(); err != nil {
+			return err
+		}
+	}
+	f.w.Flush()
+	return f.w.Error()
+}
+
+// tsvEscaper escapes the characters that would otherwise break the
+// one-record-per-line, tab-separated structure of TSV output.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// tsvFormatter writes one tab-separated path and owners pair per line, with no
+// padding, for consumption by tools like cut and awk. With --show-rule, the
+// pattern and line number of the matching rule follow.
+type tsvFormatter struct {
+	w            io.Writer
+	unownedLabel string
+	showRule     bool
+}
+
+func newTSVFormatter(w io.Writer, opts formatOptions) formatter {
+	return &tsvFormatter{w: w, unownedLabel: opts.unownedLabel, showRule: opts.showRule}
+}
+
+func (f *tsvFormatter) write(res result) error {
+	owners := f.unownedLabel
+	if !res.unowned {
+		owners = strings.Join(res.ownerStrings(), " ")
+	}
+	fields := []string{res.path, owners}
+	if f.showRule {
+		fields = append(fields, ruleColumns(res.rule)...)
+	}
+	for i, field := range fields {
+		fields[i] = tsvEscaper.Replace(field)
+	}
+	_, err := fmt.Fprintf(f.w, "%s\n", strings.Join(fields, "\t"))
+	return err
+}
+
+func (f *tsvFormatter) close() error {
+	return nil
+}
+
+// yamlFormatter writes a YAML mapping of each path to a flow-style list of its
+// owners. Each entry is encoded separately so results can be streamed, which
+// is fine as concatenated single-entry mappings form a single larger mapping.
+type yamlFormatter struct {
+	w     io.Writer
+	count int
+}
+
+func newYAMLFormatter(w io.Writer, opts formatOptions) formatter {
+	return &yamlFormatter{w: w}
+}
+
+func (f *yamlFormatter) write(res result) error {
+	// Build the nodes by hand, rather than marshaling a map, so the encoder
+	// quotes keys only where necessary and lists owners in flow style
+	owners := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, o := range res.ownerStrings() {
+		owners.Content = append(owners.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: o})
+	}
+	entry := &yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: res.path},
+			owners,
+		},
+	}
+
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f.count++
+	_, err = f.w.Write(data)
+	return err
+}
+
+func (f *yamlFormatter) close() error {
+	// Make sure the output is still a mapping when there's nothing in it
+	if f.count == 0 {
+		_, err := io.WriteString(f.w, "{}\n")
+		return err
+	}
+	return nil
+}
+
+// markdownEscaper escapes the characters that would otherwise break the
+// structure of a markdown table cell.
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ")
+
+// markdownFormatter writes a GitHub-flavored markdown table of paths and their
+// owners. When only unowned files were requested, the number of unowned files
+// is written underneath the table.
+type markdownFormatter struct {
+	w            io.Writer
+	unownedLabel string
+	unownedOnly  bool
+	showRule     bool
+	rows         int
+}
+
+func newMarkdownFormatter(w io.Writer, opts formatOptions) formatter {
+	label := opts.unownedLabel
+	if label == "" {
+		label = "(unowned)"
+	}
+	return &markdownFormatter{w: w, unownedLabel: label, unownedOnly: opts.unownedOnly, showRule: opts.showRule}
+}
+
+func (f *markdownFormatter) writeHeader() error {
+	header := "| Path | Owners |\n| --- | --- |\n"
+	if f.showRule {
+		header = "| Path | Owners | Rule | Line |\n| --- | --- | --- | --- |\n"
+	}
+	_, err := io.WriteString(f.w, header)
+	return err
+}
+
+func (f *markdownFormatter) write(res result) error {
+	// Owned files can still reach us when --owner is also used, but this table
+	// is meant to be a list of files needing attention
+	if f.unownedOnly && !res.unowned {
+		return nil
+	}
+	if f.rows == 0 {
+		if err := f.writeHeader(); err != nil {
+			return err
+		}
+	}
+	f.rows++
+
+	owners := f.unownedLabel
+	if !res.unowned {
+		owners = strings.Join(res.ownerStrings(), " ")
+	}
+	cells := []string{res.path, owners}
+	if f.showRule {
+		line := ""
+		if res.rule != nil {
+			line = strconv.Itoa(res.rule.LineNumber)
+		}
+		cells = append(cells, ruleText(res.rule), line)
+	}
+	for i, cell := range cells {
+		cells[i] = markdownEscaper.Replace(cell)
+	}
+	_, err := fmt.Fprintf(f.w, "| %s |\n", strings.Join(cells, " | "))
+	return err
+}
+
+func (f *markdownFormatter) close() error {
+	if f.rows == 0 {
+		if err := f.writeHeader(); err != nil {
+			return err
+		}
+	}
+	if f.unownedOnly {
+		noun := "files"
+		if f.rows == 1 {
+			noun = "file"
+		}
+		_, err := fmt.Fprintf(f.w, "\n%d unowned %s\n", f.rows, noun)
+		return err
+	}
+	return nil
+}
+
+// actionsPropertyEscaper and actionsDataEscaper escape values in GitHub
+// Actions workflow commands, which are otherwise delimited by newlines, colons,
+// and commas.
+var (
+	actionsPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+	actionsDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+)
+
+// gitHubActionsFormatter writes a GitHub Actions workflow command for each
+// unowned file, so that they're annotated on the pull request diff. Owned
+// files produce no output. Annotation file paths must be relative to the
+// repository root, regardless of where the tool is run from.
+type gitHubActionsFormatter struct {
+	w     io.Writer
+	level string
+	count int
+}
+
+func newGitHubActionsFormatter(w io.Writer, opts formatOptions) formatter {
+	return &gitHubActionsFormatter{w: w, level: opts.annotationLevel}
+}
+
+func (f *gitHubActionsFormatter) write(res result) error {
+	if !res.unowned {
+		return nil
+	}
+	f.count++
+	path := res.path
+	if res.repoPath != "" {
+		path = res.repoPath
+	}
+	_, err := fmt.Fprintf(f.w, "::%s file=%s::%s\n",
+		f.level, actionsPropertyEscaper.Replace(path), actionsDataEscaper.Replace("File has no code owner"))
+	return err
+}
+
+func (f *gitHubActionsFormatter) close() error {
+	return nil
+}
+
+func (f *gitHubActionsFormatter) failed() bool {
+	return f.count > 0
+}
+
+// pathsFormatter writes only the path of each result, followed by a
+// terminator: a newline for --quiet, or a NUL byte for --print0, mirroring
+// find -print0.
+type pathsFormatter struct {
+	w          io.Writer
+	terminator string
+}
+
+func newPathsFormatter(w io.Writer, terminator string) formatter {
+	return &pathsFormatter{w: w, terminator: terminator}
+}
+
+func (f *pathsFormatter) write(res result) error {
+	if _, err := io.WriteString(f.w, res.path); err != nil {
+		return err
+	}
+	_, err := io.WriteString(f.w, f.terminator)
+	return err
+}
+
+func (f *pathsFormatter) close() error {
+	return nil
+}
+
+// ownersFormatter writes each distinct owner of the results once, sorted, with
+// the number of results they own. Unowned results aren't counted.
+type ownersFormatter struct {
+	w      io.Writer
+	counts map[string]int
+}
+
+func newOwnersFormatter(w io.Writer, opts formatOptions) formatter {
+	return &ownersFormatter{w: w, counts: make(map[string]int)}
+}
+
+func (f *ownersFormatter) write(res result) error {
+	for _, o := range res.ownerStrings() {
+		f.counts[o]++
+	}
+	return nil
+}
+
+func (f *ownersFormatter) close() error {
+	owners := make([]string, 0, len(f.counts))
+	for o := range f.counts {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+	for _, o := range owners {
+		if _, err := fmt.Fprintf(f.w, "%s (%d)\n", o, f.counts[o]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupByOwnerFormatter writes each owner followed by an indented list of the
+// files they own, with unowned files grouped together at the end. Files with
+// several owners are listed under each of them.
+type groupByOwnerFormatter struct {
+	w            io.Writer
+	unownedLabel string
+	files        map[string][]string
+	unowned      []string
+}
+
+func newGroupByOwnerFormatter(w io.Writer, opts formatOptions) formatter {
+	label := opts.unownedLabel
+	if label == "" {
+		label = "(unowned)"
+	}
+	return &groupByOwnerFormatter{w: w, unownedLabel: label, files: make(map[string][]string)}
+}
+
+func (f *groupByOwnerFormatter) write(res result) error {
+	if res.unowned {
+		f.unowned = append(f.unowned, res.path)
+		return nil
+	}
+	for _, o := range res.ownerStrings() {
+		f.files[o] = append(f.files[o], res.path)
+	}
+	return nil
+}
+
+func (f *groupByOwnerFormatter) close() error {
+	owners := make([]string, 0, len(f.files))
+	for o := range f.files {
+		owners = append(owners, o)
+	}
+	sort.Strings(owners)
+
+	first := true
+	writeGroup := func(heading string, paths []string) error {
+		if !first {
+			if _, err := io.WriteString(f.w, "\n"); err != nil {
+				return err
+			}
+		}
+		first = false
+		if _, err := fmt.Fprintln(f.w, heading); err != nil {
+			return err
+		}
+		for _, p := range paths {
+			if _, err := fmt.Fprintf(f.w, "  %s\n", p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, o := range owners {
+		if err := writeGroup(o, f.files[o]); err != nil {
+			return err
+		}
+	}
+	if len(f.unowned) > 0 {
+		return writeGroup(f.unownedLabel, f.unowned)
+	}
+	return nil
+}
+
+// templateFuncs are the functions available to --template templates, in
+// addition to the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// parseTemplate parses a --template template, so that any syntax errors can be
+// reported before any files are matched.
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("template").Funcs(templateFuncs).Parse(text)
+}
+
+// templateData is the data that --template templates are executed with.
+type templateData struct {
+	Path       string
+	Owners     []string
+	Unowned    bool
+	Pattern    string
+	LineNumber int
+}
+
+// templateFormatter executes a user-provided template for each result, with
+// each execution followed by a newline.
+type templateFormatter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func newTemplateFormatter(w io.Writer, tmpl *template.Template) formatter {
+	return &templateFormatter{w: w, tmpl: tmpl}
+}
+
+func (f *templateFormatter) write(res result) error {
+	data := templateData{
+		Path:    res.path,
+		Owners:  res.ownerStrings(),
+		Unowned: res.unowned,
+	}
+	if res.rule != nil {
+		data.Pattern = res.rule.RawPattern()
+		data.LineNumber = res.rule.LineNumber
+	}
+	if err := f.tmpl.Execute(f.w, data); err != nil {
+		return err
+	}
+	_, err := io.WriteString(f.w, "\n")
+	return err
+}
+
+func (f *templateFormatter) close() error {
+	return nil
+}
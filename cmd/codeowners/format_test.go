@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowRule(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("# Backend\n/src/ @org/backend @alice\n/docs/\n"))
+	require.NoError(t, err)
+	write := func(f formatter) {
+		for _, path := range []string{"src/main.go", "docs/guide.md", "README.md"} {
+			rule, err := ruleset.Match(path)
+			require.NoError(t, err)
+			require.NoError(t, f.write(*filters{}.apply(path, rule)))
+		}
+		require.NoError(t, f.close())
+	}
+
+	var buf bytes.Buffer
+	write(newTextFormatter(&buf, formatOptions{showRule: true}))
+	assert.Equal(t, strings.Join([]string{
+		"src/main.go    @org/backend @alice  [/src/ @org/backend @alice, line 2]",
+		"docs/guide.md  (unowned)  [/docs/, line 3]",
+		"README.md      (unowned)",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	write(newJSONFormatter(&buf, formatOptions{showRule: true}))
+	assert.JSONEq(t, `[
+		{"path": "src/main.go", "owners": ["@org/backend", "@alice"], "unowned": false, "pattern": "/src/", "line": 2},
+		{"path": "docs/guide.md", "owners": [], "unowned": true, "pattern": "/docs/", "line": 3},
+		{"path": "README.md", "owners": [], "unowned": true}
+	]`, buf.String())
+
+	buf.Reset()
+	write(newCSVFormatter(&buf, formatOptions{showRule: true}))
+	assert.Equal(t, strings.Join([]string{
+		"path,owners,pattern,line",
+		"src/main.go,@org/backend @alice,/src/,2",
+		"docs/guide.md,,/docs/,3",
+		"README.md,,,",
+		"",
+	}, "\n"), buf.String())
+
+	// Without the flag, the output is unchanged
+	buf.Reset()
+	write(newCSVFormatter(&buf, formatOptions{}))
+	assert.Equal(t, "path,owners\nsrc/main.go,@org/backend @alice\ndocs/guide.md,\nREADME.md,\n", buf.String())
+}
+
+func TestShowRuleConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   outputFlags
+		wantErr string
+	}{
+		{"text", outputFlags{format: "text", groupBy: "file", showRule: true}, ""},
+		{"tsv", outputFlags{format: "tsv", groupBy: "file", showRule: true}, ""},
+		{"yaml", outputFlags{format: "yaml", groupBy: "file", showRule: true}, "--show-rule can't be used with --format yaml"},
+		{"quiet", outputFlags{format: "text", groupBy: "file", pathsOnly: true, showRule: true}, "--show-rule can't be used with --quiet/--print0"},
+		{"group by owner", outputFlags{format: "text", groupBy: "owner", showRule: true}, "--show-rule can't be used with --group-by owner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.flags.newFormatterFunc()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSections(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("* @org/everyone\n[Backend]\n/src/ @org/backend\n[Docs]\n*.md @org/docs\n"))
+	require.NoError(t, err)
+	write := func(f formatter) {
+		for _, path := range []string{"src/README.md", "LICENSE"} {
+			sections, last, err := matchSections(ruleset, path)
+			require.NoError(t, err)
+			res := filters{}.apply(path, combineSections(sections, last))
+			if hasSections(sections) {
+				res.sections = sections
+			}
+			require.NoError(t, f.write(*res))
+		}
+		require.NoError(t, f.close())
+	}
+
+	// Each section that matches adds its owners
+	var buf bytes.Buffer
+	write(newTextFormatter(&buf, formatOptions{}))
+	assert.Equal(t, "src/README.md  @org/everyone @org/backend @org/docs\nLICENSE        @org/everyone\n", buf.String())
+
+	buf.Reset()
+	write(newTextFormatter(&buf, formatOptions{showRule: true}))
+	assert.Equal(t, strings.Join([]string{
+		"src/README.md  @org/everyone @org/backend @org/docs  [* @org/everyone, line 1]  [[Backend] /src/ @org/backend, line 3]  [[Docs] *.md @org/docs, line 5]",
+		"LICENSE        @org/everyone  [* @org/everyone, line 1]",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	write(newJSONFormatter(&buf, formatOptions{}))
+	assert.JSONEq(t, `[
+		{"path": "src/README.md", "owners": ["@org/everyone", "@org/backend", "@org/docs"], "unowned": false, "sections": [
+			{"section": "", "owners": ["@org/everyone"], "pattern": "*", "line": 1},
+			{"section": "Backend", "owners": ["@org/backend"], "pattern": "/src/", "line": 3},
+			{"section": "Docs", "owners": ["@org/docs"], "pattern": "*.md", "line": 5}
+		]},
+		{"path": "LICENSE", "owners": ["@org/everyone"], "unowned": false}
+	]`, buf.String())
+}
+
+func TestMatchRuleCombinesSectionsFromSeveralFiles(t *testing.T) {
+	base, err := codeowners.ParseFile(strings.NewReader("* @org/everyone\n\n[Docs]\n*.md @org/docs\n"))
+	require.NoError(t, err)
+	override, err := codeowners.ParseFile(strings.NewReader("/docs/ @org/writers\n"))
+	require.NoError(t, err)
+	ruleset := codeowners.Concat(base, override)
+
+	// The override comes last, although its line number is lower
+	rule, err := matchRule(ruleset, "docs/index.md")
+	require.NoError(t, err)
+	assert.Equal(t, "/docs/", rule.RawPattern())
+	assert.Equal(t, 1, rule.LineNumber)
+	assert.Equal(t, []codeowners.Owner{{Value: "org/writers", Type: codeowners.TeamOwner}, {Value: "org/docs", Type: codeowners.TeamOwner}}, rule.Owners)
+}
+
+func TestOptionalSections(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("[Backend]\n/src/ @org/backend\n^[Docs]\n*.md @org/docs @org/backend\n"))
+	require.NoError(t, err)
+	sections, last, err := matchSections(ruleset, "src/README.md")
+	require.NoError(t, err)
+	rule := combineSections(sections, last)
+	assert.False(t, rule.Optional)
+	res := filters{}.apply("src/README.md", rule)
+	res.sections = sections
+
+	// Owners required by another section aren't optional
+	var buf bytes.Buffer
+	f := newTextFormatter(&buf, formatOptions{})
+	require.NoError(t, f.write(*res))
+	require.NoError(t, f.close())
+	assert.Equal(t, "src/README.md  @org/backend @org/docs (optional)\n", buf.String())
+
+	jr := newJSONResult(*res, false)
+	assert.Equal(t, []string{"@org/docs"}, jr.Optional)
+	assert.Equal(t, []bool{false, true}, []bool{jr.Sections[0].Optional, jr.Sections[1].Optional})
+
+	// Files only owned by optional sections are optional
+	sections, last, err = matchSections(ruleset, "README.md")
+	require.NoError(t, err)
+	assert.True(t, combineSections(sections, last).Optional)
+}
+
+func TestShowRuleInheritedOwners(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("[Docs] @org/docs\n*.md\n/docs/api/ @org/api\n"))
+	require.NoError(t, err)
+	write := func(f formatter) {
+		for _, path := range []string{"README.md", "docs/api/index.md"} {
+			sections, last, err := matchSections(ruleset, path)
+			require.NoError(t, err)
+			res := filters{}.apply(path, combineSections(sections, last))
+			res.sections = sections
+			require.NoError(t, f.write(*res))
+		}
+		require.NoError(t, f.close())
+	}
+
+	var buf bytes.Buffer
+	write(newTextFormatter(&buf, formatOptions{showRule: true}))
+	assert.Equal(t, strings.Join([]string{
+		"README.md          @org/docs  [[Docs] *.md, line 2, owners from section]",
+		"docs/api/index.md  @org/api  [[Docs] /docs/api/ @org/api, line 3]",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	write(newJSONLinesFormatter(&buf, formatOptions{showRule: true}))
+	assert.Equal(t, strings.Join([]string{
+		`{"path":"README.md","owners":["@org/docs"],"unowned":false,"pattern":"*.md","line":2,"inherited":true,"sections":[{"section":"Docs","owners":["@org/docs"],"pattern":"*.md","line":2,"inherited":true}]}`,
+		`{"path":"docs/api/index.md","owners":["@org/api"],"unowned":false,"pattern":"/docs/api/","line":3,"sections":[{"section":"Docs","owners":["@org/api"],"pattern":"/docs/api/","line":3}]}`,
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestAnnotationsFromSubdirectory(t *testing.T) {
+	// Run from src/api, the paths are given relative to it, but annotations
+	// are resolved relative to the root of the repository
+	var f rootFlags
+	write := func(w formatter) {
+		require.NoError(t, w.write(*f.result("main.go", "src/api/main.go", nil)))
+		require.NoError(t, w.write(*f.result("../util.go", "src/util.go", nil)))
+		require.NoError(t, w.close())
+	}
+
+	var buf bytes.Buffer
+	write(newGitHubActionsFormatter(&buf, formatOptions{annotationLevel: "warning"}))
+	assert.Equal(t, "::warning file=src/api/main.go::File has no code owner\n::warning file=src/util.go::File has no code owner\n", buf.String())
+
+	buf.Reset()
+	write(newSARIFFormatter(&buf, formatOptions{}))
+	var doc sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	var uris []string
+	for _, r := range doc.Runs[0].Results {
+		uris = append(uris, r.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	}
+	assert.Equal(t, []string{"src/api/main.go", "src/util.go"}, uris)
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	flag "github.com/spf13/pflag"
+)
+
+// repository describes the git repository containing the current directory.
+// CODEOWNERS patterns are relative to the root of the repository, so paths
+// relative to the current directory need converting before they're matched.
+type repository struct {
+	// root is the absolute path of the top level of the working tree, or ""
+	// if we're not in a repository.
+	root string
+	// prefix is the path of the current directory relative to root, with a
+	// trailing slash, or "" if we're at the root or not in a repository.
+	prefix string
+	// gitDir is the absolute path of the git directory if it was chosen with
+	// --git-dir or GIT_DIR, rather than found in the working tree.
+	gitDir string
+}
+
+// String describes the repository for error messages, including the git
+// directory if it isn't the one in the working tree.
+func (r repository) String() string {
+	if r.gitDir != "" {
+		return fmt.Sprintf("%s (git dir %s)", r.root, r.gitDir)
+	}
+	return r.root
+}
+
+// registerGitFlags registers the flags that point git at a repository other
+// than the one containing the current directory.
+func registerGitFlags(fs *flag.FlagSet) {
+	fs.String("git-dir", "", "path to the git repository, as with git --git-dir (defaults to $GIT_DIR)")
+	fs.String("work-tree", "", "path to the working tree of the repository, as with git --work-tree (defaults to $GIT_WORK_TREE)")
+}
+
+// applyGitFlags exports --git-dir and --work-tree as GIT_DIR and
+// GIT_WORK_TREE, so that they apply to every git command we run, including
+// those run by the codeowners package. Either may instead be set in the
+// environment already, so both are made absolute, as paths are resolved
+// relative to the working tree: if the current directory isn't inside it,
+// we change to its root.
+func applyGitFlags(fs *flag.FlagSet) error {
+	for _, f := range []struct{ flag, env string }{{"git-dir", "GIT_DIR"}, {"work-tree", "GIT_WORK_TREE"}} {
+		value := os.Getenv(f.env)
+		if fs.Lookup(f.flag) != nil && fs.Changed(f.flag) {
+			value, _ = fs.GetString(f.flag)
+		}
+		if value == "" {
+			continue
+		}
+		abs, err := filepath.Abs(value)
+		if err != nil {
+			return err
+		}
+		os.Setenv(f.env, abs)
+	}
+
+	workTree := os.Getenv("GIT_WORK_TREE")
+	if workTree == "" {
+		return nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(cwd); err == nil {
+		cwd = resolved
+	}
+	root := workTree
+	if resolved, err := filepath.EvalSymlinks(workTree); err == nil {
+		root = resolved
+	}
+	if isWithin(cwd, root) {
+		return nil
+	}
+	if err := os.Chdir(workTree); err != nil {
+		return fmt.Errorf("invalid work tree: %w", err)
+	}
+	return nil
+}
+
+// findRepository finds the repository containing the current directory, using
+// git if it's installed, or by looking for a .git directory (or file, in
+// worktrees and submodules) in each of the parent directories otherwise. Like
+// git, it uses GIT_DIR and GIT_WORK_TREE if they're set.
+func findRepository() repository {
+	gitDir := os.Getenv("GIT_DIR")
+	out, err := exec.Command("git", "rev-parse", "--is-inside-work-tree", "--show-toplevel", "--show-prefix").Output()
+	lines := strings.SplitN(string(out), "\n", 4)
+	switch {
+	case err == nil && len(lines) >= 3 && lines[0] == "true":
+		return repository{root: filepath.FromSlash(lines[1]), prefix: lines[2], gitDir: gitDir}
+	case lines[0] == "false":
+		// We're inside a .git directory, or a bare repository, so there are
+		// no files to match
+		return repository{}
+	}
+	var exitErr *exec.ExitError
+	if gitDir != "" && errors.As(err, &exitErr) {
+		// git ran, so GIT_DIR isn't a repository
+		return repository{}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return repository{}
+	}
+	if gitDir != "" {
+		// Without GIT_WORK_TREE, git treats the current directory as the
+		// root of the working tree
+		root := os.Getenv("GIT_WORK_TREE")
+		if root == "" {
+			root = cwd
+		}
+		rel, err := filepath.Rel(root, cwd)
+		if err != nil || !isWithin(cwd, root) {
+			return repository{}
+		}
+		repo := repository{root: root, gitDir: gitDir}
+		if rel != "." {
+			repo.prefix = filepath.ToSlash(rel) + "/"
+		}
+		return repo
+	}
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			rel, err := filepath.Rel(dir, cwd)
+			if err != nil {
+				return repository{}
+			}
+			repo := repository{root: dir}
+			if rel != "." {
+				repo.prefix = filepath.ToSlash(rel) + "/"
+			}
+			return repo
+		}
+		if filepath.Dir(dir) == dir {
+			return repository{}
+		}
+	}
+}
+
+// relativePath converts a path relative to the current directory into one
+// relative to the repository root, using forward slashes. Absolute paths
+// inside the repository are made relative to the root too.
+func (r repository) relativePath(p string) string {
+	if filepath.IsAbs(p) {
+		if r.root != "" {
+			if rel, err := filepath.Rel(r.root, p); err == nil && isWithin(p, r.root) {
+				return filepath.ToSlash(rel)
+			}
+		}
+		return filepath.ToSlash(p)
+	}
+	return path.Join(r.prefix, filepath.ToSlash(p))
+}
+
+// gitOutput runs git with the given arguments and returns its output. If git
+// fails, the error includes whatever it wrote to stderr, and says which
+// repository it was run in.
+func gitOutput(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s in %s: %s", args[0], gitLocation(), msg)
+		}
+		return nil, fmt.Errorf("git %s in %s: %w", args[0], gitLocation(), err)
+	}
+	return out, nil
+}
+
+// gitLocation describes the repository git commands run from the current
+// directory use, for error messages: the git directory if it's been chosen
+// with --git-dir or GIT_DIR, or the current directory otherwise.
+func gitLocation() string {
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		return "git dir " + gitDir
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		return cwd
+	}
+	return "the current directory"
+}
+
+// splitNUL splits output from a git command run with -z into its entries.
+func splitNUL(out []byte) []string {
+	var entries []string
+	for _, entry := range strings.Split(string(out), "\x00") {
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// getStagedFiles returns the files that have been added, copied, modified or
+// renamed in the index, relative to the root of the repository. Deleted files
+// are left out, as there's nothing left to own.
+func getStagedFiles() ([]string, error) {
+	out, err := gitOutput("diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return splitNUL(out), nil
+}
+
+// getFilesAtRef returns the files in the tree of a git revision, relative to
+// the root of the repository, limited to the paths provided (which are
+// relative to the current directory) if there are any.
+func getFilesAtRef(ref string, paths []string) ([]string, error) {
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid revision %q", ref)
+	}
+	args := append([]string{"ls-tree", "-r", "-z", "--name-only", "--full-name", ref, "--"}, paths...)
+	out, err := gitOutput(args...)
+	if err != nil {
+		return nil, err
+	}
+	return splitNUL(out), nil
+}
+
+// readFileAtRef returns the contents of a file in a git revision, given its
+// path relative to the root of the repository.
+func readFileAtRef(ref, path string) ([]byte, error) {
+	return gitOutput("cat-file", "blob", ref+":"+path)
+}
+
+// errNotRepository is returned when git is needed outside a repository.
+var errNotRepository = errors.New("this is not a git repository")
+
+// notRepositoryError wraps errNotRepository with the location searched for a
+// repository.
+func notRepositoryError() error {
+	return fmt.Errorf("%w: %s", errNotRepository, gitLocation())
+}
+
+// errSparseIndex is returned when the index is sparse, and lists directories
+// outside the sparse-checkout cone rather than the files inside them.
+var errSparseIndex = errors.New("the index is sparse")
+
+// getTrackedFiles returns the set of files tracked by git, relative to the
+// root of the repository. In a sparse checkout, files that aren't in the
+// working tree are only included if withSkipWorktree is set. The files are
+// read from the index directly, so git doesn't need to be installed, but if
+// the index can't be read (for example because it uses a feature that isn't
+// supported), git is run instead.
+func getTrackedFiles(repo repository, withSkipWorktree bool) (map[string]bool, error) {
+	if repo.root == "" {
+		return nil, notRepositoryError()
+	}
+	if files, err := readIndexFiles(repo, withSkipWorktree); err == nil {
+		return files, nil
+	}
+	return listTrackedFiles(repo, withSkipWorktree)
+}
+
+// readIndexFiles returns the set of files in the index of the repository.
+// Files in the index are tracked, even if
+// they've been deleted from the working tree, unless the deletion has been
+// staged. Files being merged have an index entry for each side of the merge,
+// but only appear once. Files marked skip-worktree by sparse checkout are
+// only included if withSkipWorktree is set.
+func readIndexFiles(repo repository, withSkipWorktree bool) (map[string]bool, error) {
+	var r *git.Repository
+	var err error
+	if repo.gitDir != "" {
+		storage := filesystem.NewStorage(osfs.New(repo.gitDir), cache.NewObjectLRUDefault())
+		r, err = git.Open(storage, osfs.New(repo.root))
+	} else {
+		r, err = git.PlainOpenWithOptions(repo.root, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	}
+	if err != nil {
+		return nil, err
+	}
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]bool, len(idx.Entries))
+	for _, e := range idx.Entries {
+		// A sparse index has a single entry for each directory outside the
+		// cone, which only git can expand into the files it contains
+		if strings.HasSuffix(e.Name, "/") {
+			return nil, errSparseIndex
+		}
+		if e.SkipWorktree && !withSkipWorktree {
+			continue
+		}
+		files[e.Name] = true
+	}
+	return files, nil
+}
+
+// listTrackedFiles runs git ls-files to list the files tracked by git, for
+// when the index can't be read directly.
+func listTrackedFiles(repo repository, withSkipWorktree bool) (map[string]bool, error) {
+	// With -t, each file is preceded by a tag describing its status, which
+	// is S for files marked skip-worktree
+	tagged, err := listGitFiles(repo, "ls-files", "-t")
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]bool, len(tagged))
+	for entry := range tagged {
+		tag, file, ok := strings.Cut(entry, " ")
+		if !ok || (tag == "S" && !withSkipWorktree) {
+			continue
+		}
+		files[file] = true
+	}
+	return files, nil
+}
+
+// getUntrackedFiles returns the set of files that aren't tracked by git, but
+// aren't ignored either, relative to the root of the repository.
+func getUntrackedFiles(repo repository) (map[string]bool, error) {
+	if repo.root == "" {
+		return nil, notRepositoryError()
+	}
+	return listGitFiles(repo, "ls-files", "--others", "--exclude-standard")
+}
+
+// listGitFiles runs git ls-files from the root of the repository, so that
+// every file is listed relative to the root, wherever we're run from. The
+// files are separated by NUL bytes (-z), so that git doesn't quote unusual
+// paths, or split ones containing newlines, and read as git lists them
+// rather than once it's finished, as there can be millions of them.
+func listGitFiles(repo repository, args ...string) (map[string]bool, error) {
+	cmd := exec.Command("git", append(args, "-z")...)
+	cmd.Dir = repo.root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git ls-files in %s: %w", repo, err)
+	}
+
+	files := make(map[string]bool)
+	readErr := readPaths(stdout, true, func(file string) error {
+		files[file] = true
+		return nil
+	})
+	if readErr != nil {
+		// git would block writing the rest of the list otherwise
+		cmd.Process.Kill()
+	}
+	if err := cmd.Wait(); err != nil && readErr == nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git ls-files in %s: %s", repo, msg)
+		}
+		return nil, fmt.Errorf("git ls-files in %s: %w", repo, err)
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("git ls-files in %s: %w", repo, readErr)
+	}
+	return files, nil
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryRelativePath(t *testing.T) {
+	root := filepath.FromSlash("/home/user/repo")
+	tests := []struct {
+		name string
+		repo repository
+		path string
+		want string
+	}{
+		{"at the root", repository{root: root}, "src/main.go", "src/main.go"},
+		{"leading dot", repository{root: root}, "./src/main.go", "src/main.go"},
+		{"in a subdirectory", repository{root: root, prefix: "src/api/"}, "main.go", "src/api/main.go"},
+		{"current directory", repository{root: root, prefix: "src/api/"}, ".", "src/api"},
+		{"parent directory", repository{root: root, prefix: "src/api/"}, "../util.go", "src/util.go"},
+		{"absolute path in the repository", repository{root: root, prefix: "src/"}, filepath.Join(root, "docs", "a.md"), "docs/a.md"},
+		{"absolute path outside the repository", repository{root: root}, filepath.FromSlash("/etc/hosts"), filepath.ToSlash(filepath.FromSlash("/etc/hosts"))},
+		{"outside a repository", repository{}, "src/main.go", "src/main.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.repo.relativePath(tt.path))
+		})
+	}
+}
+
+// gitRepository creates a git repository in a temporary directory, with a
+// commit adding the files provided, and changes into it for the rest of the
+// test. The test is skipped if git isn't installed.
+func gitRepository(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func TestFilesAtRef(t *testing.T) {
+	dir := gitRepository(t, map[string]string{
+		".github/CODEOWNERS": "* @org/everyone\n/src/ @org/src\n",
+		"src/main.go":        "",
+		"src/api/api.go":     "",
+		"README.md":          "",
+	})
+	// Changes to the working tree don't affect what's in the revision
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".github", "CODEOWNERS"), []byte("* @org/changed\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.go"), nil, 0o644))
+
+	files, err := getFilesAtRef("HEAD", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{".github/CODEOWNERS", "README.md", "src/api/api.go", "src/main.go"}, files)
+
+	ruleset, err := loadCodeownersAtRef("HEAD", parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	require.Len(t, ruleset, 2)
+	assert.Equal(t, "/src/", ruleset[1].RawPattern())
+
+	// Paths are relative to the current directory, but files are listed
+	// relative to the root
+	require.NoError(t, os.Chdir(filepath.Join(dir, "src")))
+	files, err = getFilesAtRef("HEAD", []string{"api"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"src/api/api.go"}, files)
+
+	_, err = getFilesAtRef("--output=x", nil)
+	assert.Error(t, err)
+	_, err = loadCodeownersAtRef("does-not-exist", parseSettings{dialect: codeowners.DialectGitHub})
+	assert.Error(t, err)
+}
+
+// runGit runs git in the current directory, failing the test if it fails.
+func runGit(t *testing.T, args ...string) {
+	t.Helper()
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestTrackedFiles(t *testing.T) {
+	dir := gitRepository(t, map[string]string{
+		"kept.go":           "",
+		"removed.go":        "",
+		"deleted.go":        "",
+		"renamed.go":        "",
+		"conflict.txt":      "base\n",
+		"dir/with space.go": "",
+	})
+	// Unmerged files have several index entries
+	runGit(t, "checkout", "-q", "-b", "other")
+	require.NoError(t, os.WriteFile("conflict.txt", []byte("other\n"), 0o644))
+	runGit(t, "commit", "-q", "-am", "other")
+	runGit(t, "checkout", "-q", "-")
+	require.NoError(t, os.WriteFile("conflict.txt", []byte("main\n"), 0o644))
+	runGit(t, "commit", "-q", "-am", "main")
+	cmd := exec.Command("git", "merge", "-q", "other")
+	require.Error(t, cmd.Run(), "expected a merge conflict")
+
+	// Files staged for deletion aren't tracked any more, but files deleted
+	// without staging it are
+	runGit(t, "rm", "-q", "--cached", "removed.go")
+	require.NoError(t, os.Remove("deleted.go"))
+	runGit(t, "mv", "renamed.go", "moved.go")
+	require.NoError(t, os.WriteFile("intent.go", nil, 0o644))
+	runGit(t, "add", "--intent-to-add", "intent.go")
+	require.NoError(t, os.WriteFile("untracked.go", nil, 0o644))
+
+	want := map[string]bool{
+		"kept.go":           true,
+		"deleted.go":        true,
+		"moved.go":          true,
+		"intent.go":         true,
+		"conflict.txt":      true,
+		"dir/with space.go": true,
+	}
+	fromIndex, err := readIndexFiles(repository{root: dir}, false)
+	require.NoError(t, err)
+	assert.Equal(t, want, fromIndex)
+
+	fromGit, err := listTrackedFiles(repository{root: dir}, false)
+	require.NoError(t, err)
+	assert.Equal(t, fromIndex, fromGit)
+
+	// Tracked files are relative to the root wherever we're run from
+	require.NoError(t, os.Chdir("dir"))
+	tracked, err := getTrackedFiles(findRepository(), false)
+	require.NoError(t, err)
+	assert.Equal(t, want, tracked)
+}
+
+func TestTrackedFilesOutsideRepository(t *testing.T) {
+	_, err := getTrackedFiles(repository{}, false)
+	assert.ErrorIs(t, err, errNotRepository)
+	_, err = getUntrackedFiles(repository{})
+	assert.ErrorIs(t, err, errNotRepository)
+}
+
+func TestSparseCheckout(t *testing.T) {
+	dir := gitRepository(t, map[string]string{
+		"README.md":        "",
+		"src/main.go":      "",
+		"docs/guide.md":    "",
+		"docs/api/spec.md": "",
+	})
+	runGit(t, "sparse-checkout", "set", "--cone", "src")
+	_, err := os.Stat(filepath.Join("docs", "guide.md"))
+	require.True(t, os.IsNotExist(err), "docs should be outside the cone")
+
+	materialized := map[string]bool{"README.md": true, "src/main.go": true}
+	all := map[string]bool{"README.md": true, "src/main.go": true, "docs/guide.md": true, "docs/api/spec.md": true}
+	for _, withSkipWorktree := range []bool{false, true} {
+		want := materialized
+		if withSkipWorktree {
+			want = all
+		}
+		fromIndex, err := readIndexFiles(repository{root: dir}, withSkipWorktree)
+		require.NoError(t, err)
+		assert.Equal(t, want, fromIndex)
+		fromGit, err := listTrackedFiles(repository{root: dir}, withSkipWorktree)
+		require.NoError(t, err)
+		assert.Equal(t, want, fromGit)
+	}
+
+	// With --sparse=index, files outside the cone are reported beneath the
+	// start paths, even though they aren't in the working tree
+	walk := func(startPaths ...string) ([]string, error) {
+		opts, err := (&walkFlags{sparse: "index", maxDepth: -1}).options()
+		require.NoError(t, err)
+		var paths []string
+		err = walkPaths(startPaths, opts, func(path string) error {
+			paths = append(paths, filepath.ToSlash(path))
+			return nil
+		})
+		return paths, err
+	}
+	paths, err := walk(".")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"README.md", "docs/api/spec.md", "docs/guide.md", "src/main.go"}, paths)
+	paths, err = walk("docs/", "README.md")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs/api/spec.md", "docs/guide.md", "README.md"}, paths)
+	_, err = walk("missing")
+	assert.Error(t, err)
+
+	require.NoError(t, os.Chdir("src"))
+	paths, err = walk("..")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"../README.md", "../docs/api/spec.md", "../docs/guide.md", "../src/main.go"}, paths)
+
+	// A sparse index lists whole directories outside the cone, which git
+	// has to expand into the files inside them
+	runGit(t, "sparse-checkout", "init", "--cone", "--sparse-index")
+	_, err = readIndexFiles(repository{root: dir}, true)
+	assert.Error(t, err)
+	tracked, err := getTrackedFiles(findRepository(), true)
+	require.NoError(t, err)
+	assert.Equal(t, all, tracked)
+}
+
+func TestLinkedWorktree(t *testing.T) {
+	gitRepository(t, map[string]string{
+		"README.md":      "",
+		"src/main.go":    "",
+		"src/api/api.go": "",
+	})
+	worktree := filepath.Join(t.TempDir(), "worktree")
+	runGit(t, "worktree", "add", "-q", worktree)
+	runGit(t, "rm", "-q", "src/main.go")
+	runGit(t, "commit", "-q", "-m", "remove main.go")
+	info, err := os.Lstat(filepath.Join(worktree, ".git"))
+	require.NoError(t, err)
+	require.True(t, info.Mode().IsRegular(), ".git should be a file in a linked worktree")
+
+	// The worktree has an index of its own, so changes to the main working
+	// tree aren't seen
+	require.NoError(t, os.Chdir(filepath.Join(worktree, "src")))
+	want := map[string]bool{"README.md": true, "src/main.go": true, "src/api/api.go": true}
+	check := func() {
+		repo := findRepository()
+		assert.Equal(t, worktree, evalSymlinks(t, repo.root))
+		assert.Equal(t, "src/", repo.prefix)
+		tracked, err := getTrackedFiles(repo, false)
+		require.NoError(t, err)
+		assert.Equal(t, want, tracked)
+	}
+	check()
+	// Without git, the repository is found by looking for the .git file
+	t.Setenv("PATH", "")
+	check()
+}
+
+func TestFindRepositoryInGitDir(t *testing.T) {
+	dir := gitRepository(t, map[string]string{"README.md": ""})
+	require.NoError(t, os.Chdir(filepath.Join(dir, ".git")))
+	assert.Equal(t, repository{}, findRepository())
+}
+
+func TestSubmodule(t *testing.T) {
+	sub := gitRepository(t, map[string]string{"lib/lib.go": ""})
+	super := gitRepository(t, map[string]string{"main.go": ""})
+	runGit(t, "-c", "protocol.file.allow=always", "submodule", "add", "-q", sub, "vendor/lib")
+	runGit(t, "commit", "-q", "-m", "add submodule")
+
+	require.NoError(t, os.Chdir(filepath.Join(super, "vendor", "lib", "lib")))
+	check := func() {
+		repo := findRepository()
+		assert.Equal(t, evalSymlinks(t, filepath.Join(super, "vendor", "lib")), evalSymlinks(t, repo.root))
+		assert.Equal(t, "lib/", repo.prefix)
+		tracked, err := getTrackedFiles(repo, false)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"lib/lib.go": true}, tracked)
+	}
+	check()
+	t.Setenv("PATH", "")
+	check()
+}
+
+// evalSymlinks resolves any symlinks in path, as temporary directories are
+// often beneath one, and git reports paths with them resolved.
+func evalSymlinks(t *testing.T, path string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(path)
+	require.NoError(t, err)
+	return resolved
+}
+
+func TestTrackedWithUntracked(t *testing.T) {
+	dir := gitRepository(t, map[string]string{
+		".gitignore":  "build/\n",
+		"src/main.go": "",
+	})
+	for name, contents := range map[string]string{
+		"src/new.go":    "",
+		"build/out.bin": "",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	}
+
+	walk := func(tracked string) []string {
+		opts, err := (&walkFlags{tracked: tracked, maxDepth: -1}).options()
+		require.NoError(t, err)
+		var paths []string
+		require.NoError(t, walkPaths([]string{"."}, opts, func(path string) error {
+			paths = append(paths, filepath.ToSlash(path))
+			return nil
+		}))
+		return paths
+	}
+	assert.Equal(t, []string{".gitignore", "build/out.bin", "src/main.go", "src/new.go"}, walk(""))
+	assert.Equal(t, []string{".gitignore", "src/main.go"}, walk("true"))
+	// New files are shown, but ignored files still aren't
+	assert.Equal(t, []string{".gitignore", "src/main.go", "src/new.go"}, walk("with-untracked"))
+
+	_, err := (&walkFlags{tracked: "everything"}).options()
+	assert.Error(t, err)
+}
+
+func TestListGitFilesUnusualNames(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows doesn't allow quotes or newlines in file names")
+	}
+	// git quotes paths like these in its output, unless it's run with -z
+	names := []string{"with space.go", `with "quotes".go`, "with\nnewline.go", "caf\u00e9.go", "\u65e5\u672c\u8a9e.md", `back\slash.go`}
+	files := make(map[string]string)
+	want := make(map[string]bool)
+	for _, name := range names {
+		files["tracked/"+name] = ""
+		want["tracked/"+name] = true
+	}
+	dir := gitRepository(t, files)
+	wantUntracked := make(map[string]bool)
+	for _, name := range names {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "new "+name), nil, 0o644))
+		wantUntracked["new "+name] = true
+	}
+
+	tracked, err := listTrackedFiles(repository{root: dir}, false)
+	require.NoError(t, err)
+	assert.Equal(t, want, tracked)
+	fromIndex, err := readIndexFiles(repository{root: dir}, false)
+	require.NoError(t, err)
+	assert.Equal(t, want, fromIndex)
+	untracked, err := getUntrackedFiles(repository{root: dir})
+	require.NoError(t, err)
+	assert.Equal(t, wantUntracked, untracked)
+}
+
+func TestSeparateGitDir(t *testing.T) {
+	dir := gitRepository(t, map[string]string{"README.md": "", "src/main.go": ""})
+	gitDir := filepath.Join(t.TempDir(), "repo.git")
+	require.NoError(t, os.Rename(filepath.Join(dir, ".git"), gitDir))
+	elsewhere := t.TempDir()
+	require.NoError(t, os.Chdir(elsewhere))
+	// Clear the variables for the test, so they're restored afterwards
+	t.Setenv("GIT_DIR", "")
+	t.Setenv("GIT_WORK_TREE", "")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	registerGitFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--git-dir", gitDir, "--work-tree", dir}))
+	require.NoError(t, applyGitFlags(fs))
+	assert.Equal(t, gitDir, os.Getenv("GIT_DIR"))
+
+	// Paths are relative to the working tree, as we weren't inside it
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, evalSymlinks(t, dir), evalSymlinks(t, cwd))
+
+	repo := findRepository()
+	assert.Equal(t, evalSymlinks(t, dir), evalSymlinks(t, repo.root))
+	assert.Equal(t, gitDir, repo.gitDir)
+	want := map[string]bool{"README.md": true, "src/main.go": true}
+	fromIndex, err := readIndexFiles(repo, false)
+	require.NoError(t, err)
+	assert.Equal(t, want, fromIndex)
+	fromGit, err := listTrackedFiles(repo, false)
+	require.NoError(t, err)
+	assert.Equal(t, want, fromGit)
+
+	// Inside the working tree, paths stay relative to the current directory
+	require.NoError(t, os.Chdir(filepath.Join(dir, "src")))
+	require.NoError(t, applyGitFlags(fs))
+	assert.Equal(t, "src/", findRepository().prefix)
+}
+
+func TestGitErrorsNameRepository(t *testing.T) {
+	gitRepository(t, map[string]string{"README.md": ""})
+	missing := filepath.Join(t.TempDir(), "missing.git")
+	t.Setenv("GIT_DIR", missing)
+
+	_, err := getTrackedFiles(findRepository(), false)
+	assert.ErrorIs(t, err, errNotRepository)
+	assert.Contains(t, err.Error(), missing)
+	_, err = gitOutput("log")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "git log in git dir "+missing)
+}
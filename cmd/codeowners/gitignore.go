@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitignorePattern is a single pattern from a .gitignore file.
+type gitignorePattern struct {
+	glob   glob
+	negate bool
+}
+
+// gitignoreMatcher decides whether paths are ignored according to the
+// .gitignore files in the directories containing them, without needing git to
+// be installed. Patterns in a .gitignore file apply relative to the directory
+// it's in, later patterns override earlier ones, and patterns in deeper
+// directories override those further up, all as git does.
+//
+// Directories are loaded lazily as the walk reaches them; all paths are
+// handled as absolute paths internally.
+type gitignoreMatcher struct {
+	// patterns maps the absolute path of each loaded directory to the
+	// patterns from its .gitignore file, which may be empty.
+	patterns map[string][]gitignorePattern
+}
+
+// newGitignoreMatcher creates an empty matcher. addStartPath must be called
+// before walking each start path.
+func newGitignoreMatcher() *gitignoreMatcher {
+	return &gitignoreMatcher{patterns: make(map[string][]gitignorePattern)}
+}
+
+// addStartPath prepares the matcher for a walk from startPath, loading any
+// .gitignore files between the repository root and startPath, along with the
+// repository's .git/info/exclude file.
+func (m *gitignoreMatcher) addStartPath(startPath string) error {
+	abs, err := filepath.Abs(startPath)
+	if err != nil {
+		return err
+	}
+
+	// Find the root of the repository by looking for .git in each of the
+	// parent directories in turn. Outside a repository, only the .gitignore
+	// files beneath the start path are used.
+	root := abs
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			root = dir
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	if _, loaded := m.patterns[root]; !loaded {
+		exclude, err := readGitignore(filepath.Join(root, ".git", "info", "exclude"))
+		if err != nil {
+			return err
+		}
+		if err := m.loadDir(root); err != nil {
+			return err
+		}
+		m.patterns[root] = append(exclude, m.patterns[root]...)
+	}
+
+	// Load from the top down, so precedence is determined correctly
+	var dirs []string
+	for dir := filepath.Dir(abs); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := m.loadDir(dirs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDir loads the .gitignore file in the directory provided, if there is
+// one and it hasn't already been loaded.
+func (m *gitignoreMatcher) loadDir(dir string) error {
+	if _, loaded := m.patterns[dir]; loaded {
+		return nil
+	}
+	patterns, err := readGitignore(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return err
+	}
+	m.patterns[dir] = patterns
+	return nil
+}
+
+// ignored reports whether the file or directory at the absolute path provided
+// is ignored. The .gitignore files in its parent directories must already have
+// been loaded.
+func (m *gitignoreMatcher) ignored(abs string, isDir bool) bool {
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		patterns := m.patterns[dir]
+		if len(patterns) > 0 {
+			rel, err := filepath.Rel(dir, abs)
+			if err == nil {
+				rel = filepath.ToSlash(rel)
+				// The last matching pattern decides
+				for i := len(patterns) - 1; i >= 0; i-- {
+					if patterns[i].glob.match(rel, isDir) {
+						return !patterns[i].negate
+					}
+				}
+			}
+		}
+		// Directories above the repository root are never loaded, so there's
+		// no need to stop there
+		if filepath.Dir(dir) == dir {
+			return false
+		}
+	}
+}
+
+// readGitignore reads the patterns from a .gitignore file. A missing file has
+// no patterns.
+func readGitignore(path string) ([]gitignorePattern, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []gitignorePattern{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := []gitignorePattern{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		pattern, ok := parseGitignoreLine(scanner.Text())
+		if ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// parseGitignoreLine parses a single line of a .gitignore file, returning
+// false if the line doesn't contain a pattern.
+func parseGitignoreLine(line string) (gitignorePattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+
+	// Trailing spaces are ignored unless they're escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return gitignorePattern{}, false
+	}
+
+	p := gitignorePattern{}
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	}
+	g, err := compileGlob(line)
+	if err != nil {
+		// git silently ignores patterns it can't make sense of, so we do too
+		return gitignorePattern{}, false
+	}
+	p.glob = g
+	return p, true
+}
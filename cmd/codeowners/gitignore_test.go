@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitignoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".git/info/exclude": "*.log\n",
+		".gitignore":        "# build output\n*.o\n!keep.o\nbuild/\n/root-only.txt\ntrailing.txt   \n",
+		"sub/.gitignore":    "*.tmp\n!*.o\nlocal/\n",
+	}
+	for path, contents := range files {
+		full := filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		require.NoError(t, os.WriteFile(full, []byte(contents), 0o644))
+	}
+
+	m := newGitignoreMatcher()
+	require.NoError(t, m.addStartPath(filepath.Join(root, "sub")))
+	require.NoError(t, m.loadDir(filepath.Join(root, "sub")))
+
+	examples := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"main.c", false, false},
+		{"main.o", false, true},
+		{"keep.o", false, false},
+		{"deep/dir/main.o", false, true},
+		{"debug.log", false, true},
+		{"build", true, true},
+		{"build", false, false},
+		{"deep/build", true, true},
+		{"root-only.txt", false, true},
+		{"sub/root-only.txt", false, false},
+		{"trailing.txt", false, true},
+		{".gitignore", false, false},
+
+		// Patterns in deeper .gitignore files take precedence
+		{"sub/main.o", false, false},
+		{"sub/scratch.tmp", false, true},
+		{"scratch.tmp", false, false},
+		{"sub/local", true, true},
+		{"local", true, false},
+	}
+
+	for _, e := range examples {
+		t.Run(e.path, func(t *testing.T) {
+			abs := filepath.Join(root, filepath.FromSlash(e.path))
+			assert.Equal(t, e.ignored, m.ignored(abs, e.isDir))
+		})
+	}
+}
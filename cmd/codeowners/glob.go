@@ -0,0 +1,127 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// glob is a compiled --ignore pattern.
+//
+// Globs use the familiar shell wildcards, where '*' and '?' match anything but
+// a slash, and "**" matches across directories. As with gitignore, a glob
+// with no slashes in it (other than a trailing one) matches a file or
+// directory of that name at any depth, a trailing slash restricts the glob to
+// directories, and a backslash escapes the character that follows it. Bracket
+// expressions like [a-z] match a single character from the set, and are
+// negated by a leading '!' or '^'.
+type glob struct {
+	re      *regexp.Regexp
+	dirOnly bool
+}
+
+// compileGlob compiles a glob pattern for matching against slash-separated
+// relative paths.
+func compileGlob(pattern string) (glob, error) {
+	g := glob{}
+	if strings.HasSuffix(pattern, "/") {
+		g.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var re strings.Builder
+	re.WriteString(`\A`)
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case strings.HasPrefix(pattern[i:], "**/"):
+			// Zero or more leading directories
+			re.WriteString(`(?:.*/)?`)
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(`.*`)
+			i++
+		case ch == '*':
+			re.WriteString(`[^/]*`)
+		case ch == '?':
+			re.WriteString(`[^/]`)
+		case ch == '[':
+			class, n := bracketExpression(pattern[i:])
+			if n == 0 {
+				// Unterminated, so treat the bracket as a literal
+				re.WriteString(`\[`)
+				continue
+			}
+			re.WriteString(class)
+			i += n - 1
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString(`\z`)
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return glob{}, err
+	}
+	g.re = compiled
+	return g, nil
+}
+
+// bracketExpression converts the bracket expression at the start of s into a
+// regexp character class, returning it along with the length of the
+// expression. If s doesn't start with a terminated bracket expression, the
+// returned length is 0.
+func bracketExpression(s string) (string, int) {
+	var class strings.Builder
+	class.WriteString("[")
+	i := 1
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		// Wildcards never match a slash, even negated sets
+		class.WriteString("^/")
+		i++
+	}
+	// A ']' straight after the opening bracket is part of the set
+	first := true
+	for ; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == ']' && !first:
+			class.WriteString("]")
+			return class.String(), i + 1
+		case ch == '\\' && i+1 < len(s):
+			i++
+			class.WriteString(regexp.QuoteMeta(s[i : i+1]))
+		case ch == '-':
+			class.WriteString("-")
+		default:
+			class.WriteString(regexp.QuoteMeta(s[i : i+1]))
+		}
+		first = false
+	}
+	return "", 0
+}
+
+// match tests whether a slash-separated relative path matches the glob.
+func (g glob) match(path string, isDir bool) bool {
+	if g.dirOnly && !isDir {
+		return false
+	}
+	return g.re.MatchString(path)
+}
+
+// matchAnyGlob tests whether a path matches any of the globs provided.
+func matchAnyGlob(globs []glob, path string, isDir bool) bool {
+	for _, g := range globs {
+		if g.match(path, isDir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlobMatch(t *testing.T) {
+	examples := []struct {
+		glob  string
+		path  string
+		isDir bool
+		match bool
+	}{
+		// Globs without a slash match at any depth
+		{"vendor", "vendor", true, true},
+		{"vendor", "a/b/vendor", true, true},
+		{"vendor", "vendored", true, false},
+		{"*.txt", "notes.txt", false, true},
+		{"*.txt", "docs/notes.txt", false, true},
+		{"*.txt", "notes.txt.bak", false, false},
+		{"?.go", "a.go", false, true},
+		{"?.go", "ab.go", false, false},
+
+		// Globs with a slash are relative to the walk root
+		{"docs/*.md", "docs/a.md", false, true},
+		{"docs/*.md", "docs/sub/a.md", false, false},
+		{"docs/*.md", "x/docs/a.md", false, false},
+		{"/docs", "docs", true, true},
+		{"/docs", "x/docs", true, false},
+
+		// Double asterisks match across directories
+		{"docs/**/*.md", "docs/a.md", false, true},
+		{"docs/**/*.md", "docs/a/b/c.md", false, true},
+		{"**/gen/*.go", "gen/a.go", false, true},
+		{"**/gen/*.go", "a/b/gen/a.go", false, true},
+		{"build/**", "build/a/b", false, true},
+
+		// A trailing slash only matches directories
+		{"dist/", "dist", true, true},
+		{"dist/", "dist", false, false},
+		{"dist/", "a/dist", true, true},
+
+		// Bracket expressions
+		{"v[0-9].txt", "v1.txt", false, true},
+		{"v[0-9].txt", "vx.txt", false, false},
+		{"v[!0-9].txt", "vx.txt", false, true},
+		{"v[!0-9].txt", "v1.txt", false, false},
+		{"[]a].txt", "].txt", false, true},
+		{"a[.txt", "a[.txt", false, true},
+
+		// Escapes and regex metacharacters are literal
+		{`\*.go`, "*.go", false, true},
+		{`\*.go`, "a.go", false, false},
+		{"a+b.(c)", "a+b.(c)", false, true},
+		{"a+b.(c)", "aab.(c)", false, false},
+	}
+
+	for _, e := range examples {
+		t.Run(e.glob+" "+e.path, func(t *testing.T) {
+			g, err := compileGlob(e.glob)
+			require.NoError(t, err)
+			assert.Equal(t, e.match, g.match(e.path, e.isDir))
+		})
+	}
+}
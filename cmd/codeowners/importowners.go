@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// ownersFile is a Kubernetes-style OWNERS file.
+type ownersFile struct {
+	Approvers []string `yaml:"approvers"`
+	Reviewers []string `yaml:"reviewers"`
+	Options   struct {
+		NoParentOwners bool `yaml:"no_parent_owners"`
+	} `yaml:"options"`
+	Filters map[string]interface{} `yaml:"filters"`
+}
+
+// ownersAliases is a Kubernetes-style OWNERS_ALIASES file, which defines
+// groups of users that may be named in OWNERS files.
+type ownersAliases struct {
+	Aliases map[string][]string `yaml:"aliases"`
+}
+
+// runImportOwners runs the import-owners command, which generates CODEOWNERS
+// rules from Kubernetes-style OWNERS files.
+func runImportOwners(args []string) int {
+	fs := flag.NewFlagSet("import-owners", flag.ContinueOnError)
+	var (
+		walkFlags   walkFlags
+		outputPath  string
+		aliasesPath string
+	)
+	walkFlags.register(fs)
+	fs.StringVarP(&outputPath, "output", "o", "", "write the CODEOWNERS file to this path, rather than stdout")
+	fs.StringVar(&aliasesPath, "aliases", "OWNERS_ALIASES", "OWNERS_ALIASES file to expand aliases from, if it exists")
+	registerGitFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners import-owners [flags] [<path>...]\n\n")
+		fmt.Fprintf(os.Stderr, "Approvers become owners, and reviewers are ignored.\n\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := walkFlags.checkConflicts(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+	if walkFlags.submodules == "recurse" {
+		fmt.Fprintln(os.Stderr, "error: --submodules=recurse can't be used with import-owners, as a submodule's OWNERS files belong in its own CODEOWNERS file")
+		return 1
+	}
+
+	walkOpts, err := walkFlags.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	aliases := map[string][]string{}
+	var a ownersAliases
+	if err := readYAMLFile(aliasesPath, &a); err == nil {
+		aliases = a.Aliases
+	} else if !os.IsNotExist(err) || fs.Changed("aliases") {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	repo := findRepository()
+	files := make(map[string]ownersFile)
+	err = walkPaths(paths, walkOpts, func(p string) error {
+		if filepath.Base(p) != "OWNERS" {
+			return nil
+		}
+		var f ownersFile
+		if err := readYAMLFile(p, &f); err != nil {
+			return err
+		}
+		files[repo.relativePath(filepath.Dir(p))] = f
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if outputPath != "" {
+		f, err := os.Create(outputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+	for _, warning := range writeImportedRules(out, files, aliases) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	return 0
+}
+
+// readYAMLFile decodes the YAML file at path into v.
+func readYAMLFile(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := yaml.NewDecoder(f).Decode(v); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// writeImportedRules writes a CODEOWNERS file equivalent to the OWNERS files,
+// which are keyed by the directory they're in relative to the repository
+// root, returning warnings about anything that can't be represented.
+//
+// Approvers in an OWNERS file can approve changes anywhere beneath its
+// directory, along with the approvers of its parent directories unless
+// no_parent_owners is set. As the last matching rule in a CODEOWNERS file
+// takes precedence, each rule lists the inherited owners too.
+func writeImportedRules(w io.Writer, files map[string]ownersFile, aliases map[string][]string) []string {
+	dirs := make([]string, 0, len(files))
+	for dir := range files {
+		dirs = append(dirs, dir)
+	}
+	// Parents sort before their children, so their rules come first
+	sort.Strings(dirs)
+
+	var warnings []string
+	owners := make(map[string][]string, len(dirs))
+	fmt.Fprintln(w, "# Generated from OWNERS files by codeowners import-owners")
+	for _, dir := range dirs {
+		f := files[dir]
+		ownersPath := path.Join(dir, "OWNERS")
+		if len(f.Filters) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: filters aren't supported, so they were ignored", ownersPath))
+		}
+
+		var own []string
+		for _, approver := range f.Approvers {
+			if members, ok := aliases[approver]; ok {
+				own = append(own, members...)
+			} else {
+				own = append(own, approver)
+			}
+		}
+		owners[dir] = dedupe(own)
+		if !f.Options.NoParentOwners {
+			owners[dir] = dedupe(append(own, owners[parentOwnersDir(dir, files)]...))
+		}
+
+		// Directories that don't change who can approve don't need a rule
+		if len(own) == 0 && !f.Options.NoParentOwners {
+			continue
+		}
+		if len(owners[dir]) == 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: no approvers, so files beneath it will be unowned", ownersPath))
+		}
+
+		parts := []string{"/" + dir + "/"}
+		if dir == "." {
+			parts[0] = "*"
+		}
+		for _, o := range owners[dir] {
+			// OWNERS files name GitHub users without the @
+			parts = append(parts, "@"+strings.TrimPrefix(o, "@"))
+		}
+		fmt.Fprintln(w, strings.Join(parts, " "))
+	}
+	return warnings
+}
+
+// parentOwnersDir returns the closest directory above dir that has an OWNERS
+// file, or "" if there isn't one.
+func parentOwnersDir(dir string, files map[string]ownersFile) string {
+	for dir != "." && dir != "/" {
+		dir = path.Dir(dir)
+		if _, ok := files[dir]; ok {
+			return dir
+		}
+	}
+	return ""
+}
+
+// dedupe removes repeated strings, keeping the first occurrence of each.
+func dedupe(strs []string) []string {
+	seen := make(map[string]bool, len(strs))
+	deduped := make([]string, 0, len(strs))
+	for _, s := range strs {
+		if !seen[s] {
+			seen[s] = true
+			deduped = append(deduped, s)
+		}
+	}
+	return deduped
+}
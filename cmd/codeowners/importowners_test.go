@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteImportedRules(t *testing.T) {
+	files := map[string]ownersFile{
+		".":                 {Approvers: []string{"root"}},
+		"pkg/api":           {Approvers: []string{"api-approvers", "carol", "root"}},
+		"pkg/util":          {Reviewers: []string{"dave"}},
+		"pkg/util/internal": {Approvers: []string{"erin"}},
+		"vendor":            {},
+	}
+	var docs ownersFile
+	docs.Approvers = []string{"docs-lead"}
+	docs.Options.NoParentOwners = true
+	files["docs"] = docs
+	var generated ownersFile
+	generated.Options.NoParentOwners = true
+	files["docs/generated"] = generated
+
+	aliases := map[string][]string{"api-approvers": {"alice", "bob"}}
+
+	var buf bytes.Buffer
+	warnings := writeImportedRules(&buf, files, aliases)
+	assert.Equal(t, strings.Join([]string{
+		"# Generated from OWNERS files by codeowners import-owners",
+		"* @root",
+		"/docs/ @docs-lead",
+		"/docs/generated/",
+		"/pkg/api/ @alice @bob @carol @root",
+		"/pkg/util/internal/ @erin @root",
+		"",
+	}, "\n"), buf.String())
+	assert.Equal(t, []string{"docs/generated/OWNERS: no approvers, so files beneath it will be unowned"}, warnings)
+}
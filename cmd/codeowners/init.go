@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	"github.com/mattn/go-runewidth"
+	flag "github.com/spf13/pflag"
+)
+
+// placeholderOwner is the owner given to rules in a generated CODEOWNERS file
+// when --default-owner isn't passed. It's a valid owner, so the file can be
+// checked straight away, but it's obvious that it needs replacing.
+const placeholderOwner = "@TODO"
+
+// maxHistoryCommits is the number of recent commits to each directory that's
+// considered with --from-history.
+const maxHistoryCommits = 1000
+
+// vendoredDirs holds the names of directories that contain third-party code,
+// which doesn't need owners of its own.
+var vendoredDirs = map[string]bool{
+	"vendor":           true,
+	"node_modules":     true,
+	"third_party":      true,
+	"bower_components": true,
+}
+
+// runInit runs the init command, which generates a starter CODEOWNERS file
+// from the directories in the repository.
+func runInit(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	var (
+		depth         int
+		defaultOwners []string
+		output        string
+		force         bool
+		fromHistory   bool
+		since         string
+		top           int
+		mapPath       string
+	)
+	fs.IntVar(&depth, "depth", 1, "add a rule for each directory down to this depth")
+	fs.StringSliceVar(&defaultOwners, "default-owner", nil, "owner for the rules (may be repeated; defaults to "+placeholderOwner+")")
+	fs.StringVarP(&output, "output", "o", ".github/CODEOWNERS", "path to write the file to, or - for stdout")
+	fs.BoolVar(&force, "force", false, "overwrite an existing CODEOWNERS file")
+	fs.BoolVar(&fromHistory, "from-history", false, "suggest owners for each directory from who has committed to it")
+	fs.StringVar(&since, "since", "", "with --from-history, only consider commits more recent than this date (e.g. 1.year)")
+	fs.IntVarP(&top, "top", "n", 2, "with --from-history, number of owners to suggest for each directory")
+	fs.StringVar(&mapPath, "map", "", "with --from-history, file mapping commit author emails to owners")
+	registerGitFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners init [flags]\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	if depth < 1 {
+		fmt.Fprintf(os.Stderr, "error: invalid --depth %d\n", depth)
+		return 1
+	}
+	if top <= 0 {
+		fmt.Fprintf(os.Stderr, "error: invalid --top %d\n", top)
+		return 1
+	}
+	if !fromHistory && (since != "" || mapPath != "" || fs.Changed("top")) {
+		fmt.Fprintln(os.Stderr, "error: --since, --top and --map can only be used with --from-history")
+		return 1
+	}
+	// A second CODEOWNERS file would be as confusing as overwriting the
+	// first, so one at any of the standard locations counts
+	if !force && output != "-" {
+		existing := codeowners.FindFileAtStandardLocation()
+		if fileExists(output) {
+			existing = output
+		}
+		if existing != "" {
+			fmt.Fprintf(os.Stderr, "error: %s already exists (pass --force to overwrite it)\n", existing)
+			return 1
+		}
+	}
+	if len(defaultOwners) == 0 {
+		defaultOwners = []string{placeholderOwner}
+	}
+	authorMap, err := readAuthorMap(mapPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	dirs, err := layoutDirs(".", depth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	repo := findRepository()
+	rules := []skeletonRule{{pattern: suggestedPattern(".", true), owners: defaultOwners}}
+	for _, dir := range dirs {
+		rule := skeletonRule{pattern: "/" + repo.relativePath(dir) + "/", owners: defaultOwners}
+		if fromHistory {
+			emails, err := commitAuthors(dir, since, maxHistoryCommits)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return 1
+			}
+			if candidates := rankAuthors(emails, authorMap); len(candidates) > 0 {
+				if len(candidates) > top {
+					candidates = candidates[:top]
+				}
+				rule.owners = nil
+				for _, c := range candidates {
+					rule.owners = append(rule.owners, c.owner)
+				}
+			}
+		}
+		rules = append(rules, rule)
+	}
+
+	contents := skeletonCodeowners(rules)
+	if output == "-" {
+		os.Stdout.Write(contents)
+		return 0
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(output, contents, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d rules to %s\n", len(rules), output)
+	return 0
+}
+
+// fileExists reports whether there's a file or directory at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// layoutDirs returns the directories beneath root, down to the given depth, as
+// slash-separated paths relative to root. They're in lexical order, with each
+// directory followed by the directories inside it. Hidden, vendored and
+// gitignored directories are skipped, along with everything inside them.
+func layoutDirs(root string, depth int) ([]string, error) {
+	gitignore := newGitignoreMatcher()
+	if err := gitignore.addStartPath(root); err != nil {
+		return nil, err
+	}
+	var dirs []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") || vendoredDirs[name] || gitignore.ignored(abs, true) {
+			return filepath.SkipDir
+		}
+		if err := gitignore.loadDir(abs); err != nil {
+			return err
+		}
+		dirs = append(dirs, rel)
+		if strings.Count(rel, "/")+1 >= depth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
+// skeletonRule is a rule in a generated CODEOWNERS file.
+type skeletonRule struct {
+	pattern string
+	owners  []string
+}
+
+// skeletonCodeowners renders the rules of a generated CODEOWNERS file, below
+// a comment explaining what to do with it, with their owners aligned.
+func skeletonCodeowners(rules []skeletonRule) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by codeowners init. Check the owners of each rule, and remove\n")
+	buf.WriteString("# the rules that aren't needed. Later rules take precedence.\n\n")
+	width := 0
+	for _, r := range rules {
+		if n := runewidth.StringWidth(r.pattern); n > width {
+			width = n
+		}
+	}
+	for _, r := range rules {
+		fmt.Fprintf(&buf, "%s %s\n", runewidth.FillRight(r.pattern, width), strings.Join(r.owners, " "))
+	}
+	return buf.Bytes()
+}
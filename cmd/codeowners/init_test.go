@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"api/v1", "api/v2/internal", "web", "web-static", ".github", "node_modules/x", "build", "docs/vendor/lib"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".gitignore"), []byte("/build/\n"), 0o644))
+
+	dirs, err := layoutDirs(root, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "docs", "web", "web-static"}, dirs)
+
+	dirs, err = layoutDirs(root, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "api/v1", "api/v2", "docs", "web", "web-static"}, dirs)
+}
+
+func TestSkeletonCodeowners(t *testing.T) {
+	contents := skeletonCodeowners([]skeletonRule{
+		{pattern: "*", owners: []string{"@org/everyone"}},
+		{pattern: "/api/", owners: []string{"@org/api", "alice@example.com"}},
+	})
+	assert.Equal(t, "# Generated by codeowners init. Check the owners of each rule, and remove\n"+
+		"# the rules that aren't needed. Later rules take precedence.\n\n"+
+		"*     @org/everyone\n"+
+		"/api/ @org/api alice@example.com\n", string(contents))
+}
@@ -3,234 +3,987 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
+	"regexp"
+	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
 
 	"github.com/hmarr/codeowners"
 	flag "github.com/spf13/pflag"
 )
 
+// commandHelp describes the subcommands in the usage message.
+const commandHelp = `  audit          look for unused and shadowed rules, and unused owners
+  completion     print a shell completion script (bash, zsh, fish)
+  convert        rewrite the CODEOWNERS file for GitHub or GitLab
+  coverage       show the percentage of files that have an owner
+  diff           show the owners of the files changed between git revisions
+  drift          compare owners with who has recently been changing their files
+  explain        show the rules that match a path, and which one wins
+  fmt            rewrite the CODEOWNERS file with consistent formatting
+  import-owners  generate CODEOWNERS rules from Kubernetes-style OWNERS files
+  init           generate a starter CODEOWNERS file
+  owners         list the owners declared in the CODEOWNERS file
+  reviewers      choose reviewers for changed files
+  serve          answer ownership lookups over HTTP
+  sort           reorder the rules in the CODEOWNERS file from least to most specific
+  stats          show how many files each owner owns
+  suggest        suggest owners for a path based on its commit history
+  verify         check the CODEOWNERS file for problems
+  version        print the version and build information
+`
+
+// commands holds the subcommands, which are run when their name is the first
+// argument. Without one, the paths provided are matched against the ruleset.
+var commands = map[string]func(args []string) int{
+	"audit":         runAudit,
+	"completion":    runCompletion,
+	"convert":       runConvert,
+	"coverage":      runCoverage,
+	"diff":          runDiff,
+	"drift":         runDrift,
+	"explain":       runExplain,
+	"fmt":           runFmt,
+	"import-owners": runImportOwners,
+	"init":          runInit,
+	"owners":        runOwners,
+	"reviewers":     runReviewers,
+	"serve":         runServe,
+	"sort":          runSort,
+	"stats":         runStats,
+	"suggest":       runSuggest,
+	"verify":        runVerify,
+	"version":       runVersion,
+}
+
+// parseCommandFlags parses a subcommand's flags. If the command shouldn't go
+// on to run, because the flags were invalid or help was requested, it returns
+// false along with the exit code.
+func parseCommandFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0, false
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fs.Usage()
+		return 2, false
+	}
+	if err := applyGitFlags(fs); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1, false
+	}
+	return 0, true
+}
+
 func main() {
-	var (
-		ownerFilters   []string
-		showUnowned    bool
-		codeownersPath string
-		trackedOnly    bool
-		helpFlag       bool
-	)
-	flag.StringSliceVarP(&ownerFilters, "owner", "o", nil, "filter results by owner")
-	flag.BoolVarP(&showUnowned, "unowned", "u", false, "only show unowned files (can be combined with -o)")
-	flag.StringVarP(&codeownersPath, "file", "f", "", "CODEOWNERS file path")
-	flag.BoolVarP(&trackedOnly, "tracked", "t", false, "only show files tracked by git")
-	flag.BoolVarP(&helpFlag, "help", "h", false, "show this help message")
+	if len(os.Args) > 1 {
+		// The completion scripts' hidden command isn't in commands, as it
+		// needs to look them up itself
+		if os.Args[1] == "__complete" {
+			os.Exit(runComplete(os.Args[2:]))
+		}
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+	os.Exit(run())
+}
+
+// rootFlags holds the flags accepted when matching paths without a command.
+type rootFlags struct {
+	filters        filters
+	rulesetFlags   rulesetFlags
+	walkFlags      walkFlags
+	ownerTypes     []string
+	ownerRegexps   []string
+	patternRegexps []string
+	readStdin      bool
+	staged         bool
+	nulInput       bool
+	prune          bool
+	errorOnUnowned bool
+	output         outputFlags
+	unownedLabel   string
+	annotation     string
+	colorMode      string
+	columnWidth    string
+	helpFlag       bool
+	versionFlag    bool
+	verbose        bool
+	watch          bool
+	rootRelative   bool
+	ref            string
+	patch          bool
+}
+
+func (f *rootFlags) register(fs *flag.FlagSet) {
+	fs.StringSliceVarP(&f.filters.owners, "owner", "o", nil, "filter results by owner")
+	fs.BoolVar(&f.filters.caseSensitive, "case-sensitive", false, "match --owner and --not-owner case-sensitively")
+	fs.StringArrayVar(&f.ownerRegexps, "owner-regex", nil, "filter results by owners matching a regular expression")
+	fs.StringSliceVarP(&f.filters.notOwners, "not-owner", "O", nil, "exclude files owned by owner")
+	fs.StringSliceVar(&f.ownerTypes, "owner-type", nil, "only show owners of this type (team, user, email, group)")
+	fs.StringArrayVar(&f.filters.patterns, "pattern", nil, "only show files matched by the rule with this pattern")
+	fs.StringArrayVar(&f.patternRegexps, "pattern-regex", nil, "only show files matched by rules with patterns matching a regular expression")
+	fs.IntVar(&f.filters.ruleLine, "rule-line", 0, "only show files matched by the rule on this line of the CODEOWNERS file")
+	fs.BoolVar(&f.readStdin, "stdin", false, "read the paths to check from standard input, one per line")
+	fs.StringVar(&f.ref, "ref", "", "check the files in this git revision, rather than walking the filesystem")
+	fs.BoolVar(&f.staged, "staged", false, "check the files staged for commit, rather than walking the filesystem")
+	fs.BoolVar(&f.patch, "patch", false, "check the files changed by a unified diff read from standard input, followed by all of their owners")
+	fs.BoolVarP(&f.nulInput, "null", "z", false, "paths read with --stdin are separated by NUL bytes rather than newlines")
+	fs.BoolVarP(&f.filters.unowned, "unowned", "u", false, "only show unowned files (can be combined with -o)")
+	fs.BoolVar(&f.filters.owned, "owned", false, "only show files that have an owner")
+	fs.IntVar(&f.filters.minOwners, "min-owners", 0, "only show files with fewer than this many owners, exiting with an error if there are any")
+	fs.BoolVar(&f.filters.optionalUnowned, "optional-unowned", false, "treat files only owned by optional GitLab sections as unowned")
+	fs.BoolVar(&f.errorOnUnowned, "error-on-unowned", false, "exit with an error if any unowned files are shown")
+	fs.BoolVarP(&f.watch, "watch", "w", false, "keep running, showing the results again whenever the CODEOWNERS file or the files being matched change")
+	fs.BoolVar(&f.prune, "prune", false, "show directories whose files all match the same rule as a single path, rather than listing every file")
+	fs.BoolVar(&f.rootRelative, "root-relative", false, "show paths relative to the repository root, rather than the current directory")
+	fs.StringVar(&f.output.format, "format", "text", "output format ("+strings.Join(formatNames(), ", ")+")")
+	fs.StringVar(&f.unownedLabel, "unowned-label", "", "label shown in place of the owners of unowned files")
+	fs.StringVar(&f.output.template, "template", "", "Go template to render for each file, instead of using --format")
+	fs.BoolVarP(&f.output.print0, "print0", "0", false, "only print paths, each followed by a NUL byte (for xargs -0)")
+	fs.BoolVarP(&f.output.pathsOnly, "quiet", "q", false, "only print paths, one per line")
+	fs.BoolVar(&f.output.ownersOnly, "owners-only", false, "only print the distinct owners of the matched files, with file counts")
+	fs.StringVar(&f.output.groupBy, "group-by", "file", "group results by file or by owner")
+	fs.BoolVar(&f.output.showRule, "show-rule", false, "show the rule that matched each file, and its line number")
+	fs.StringVar(&f.annotation, "annotation-level", "error", "severity of github-actions annotations (error, warning)")
+	fs.StringVar(&f.colorMode, "color", "auto", "colorize text output (auto, always, never)")
+	fs.StringVar(&f.columnWidth, "column-width", "auto", "width of the path column in text output (auto, or a number)")
+	fs.BoolVarP(&f.helpFlag, "help", "h", false, "show this help message")
+	fs.BoolVar(&f.versionFlag, "version", false, "show the version and build information")
+	fs.BoolVar(&f.verbose, "verbose", false, "with --version, show each part of the build information on its own line")
+	f.rulesetFlags.register(fs)
+	f.walkFlags.register(fs)
+}
+
+// run runs the command line tool, returning the process exit code. Keeping
+// this separate from main means deferred cleanup, such as flushing buffered
+// output, happens on every exit path.
+func run() int {
+	var root rootFlags
+	root.register(flag.CommandLine)
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "usage: codeowners <path>...\n")
+		fmt.Fprintf(os.Stderr, "       codeowners <command> [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "commands:\n")
+		fmt.Fprint(os.Stderr, commandHelp)
+		fmt.Fprintf(os.Stderr, "\nflags:\n")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
+	if err := applyGitFlags(flag.CommandLine); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
 
-	if helpFlag {
+	if root.helpFlag {
 		flag.Usage()
-		os.Exit(0)
+		return 0
+	}
+	if root.verbose && !root.versionFlag {
+		fmt.Fprintln(os.Stderr, "error: --verbose can only be used with --version")
+		return 1
+	}
+	if root.versionFlag {
+		if err := currentBuild().write(os.Stdout, root.verbose); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
 	}
 
-	var trackedFiles map[string]bool
-	if trackedOnly {
-		trackedFiles = getTrackedFiles()
+	if root.filters.owned && root.filters.unowned {
+		fmt.Fprintln(os.Stderr, "error: --owned and --unowned can't be combined")
+		flag.Usage()
+		return 2
+	}
+	if err := root.walkFlags.checkConflicts(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		flag.Usage()
+		return 2
 	}
 
-	ruleset, err := loadCodeowners(codeownersPath)
+	newFormatter, err := root.output.newFormatterFunc()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if root.annotation != "error" && root.annotation != "warning" {
+		fmt.Fprintf(os.Stderr, "error: unknown annotation level %q\n", root.annotation)
+		return 1
+	}
+	color, err := useColor(root.colorMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	width, err := parseColumnWidth(root.columnWidth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if root.filters.ruleLine < 0 || (flag.CommandLine.Changed("rule-line") && root.filters.ruleLine == 0) {
+		fmt.Fprintf(os.Stderr, "error: invalid --rule-line %d\n", root.filters.ruleLine)
+		return 1
+	}
+	if root.filters.ruleLine > 0 && len(root.rulesetFlags.paths) > 1 {
+		fmt.Fprintln(os.Stderr, "error: --rule-line can't be used with more than one CODEOWNERS file")
+		return 1
+	}
+	if root.filters.minOwners < 0 {
+		fmt.Fprintf(os.Stderr, "error: invalid --min-owners %d\n", root.filters.minOwners)
+		return 1
+	}
+
+	for _, expr := range root.ownerRegexps {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid --owner-regex: %v\n", err)
+			return 1
+		}
+		root.filters.ownerRegexps = append(root.filters.ownerRegexps, re)
+	}
+	for _, expr := range root.patternRegexps {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid --pattern-regex: %v\n", err)
+			return 1
+		}
+		root.filters.patternRegexps = append(root.filters.patternRegexps, re)
+	}
+	for _, t := range root.ownerTypes {
+		ownerType, err := parseOwnerType(t)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		root.filters.ownerTypes = append(root.filters.ownerTypes, ownerType)
+	}
+
+	// Make the @ optional for GitHub teams and usernames
+	for i := range root.filters.owners {
+		root.filters.owners[i] = strings.TrimLeft(root.filters.owners[i], "@")
+	}
+	for i := range root.filters.notOwners {
+		root.filters.notOwners[i] = strings.TrimLeft(root.filters.notOwners[i], "@")
 	}
 
 	paths := flag.Args()
+	// A lone - is shorthand for --stdin, like many other tools
+	if len(paths) == 1 && paths[0] == "-" {
+		root.readStdin = true
+		paths = nil
+	}
+	if root.readStdin && len(paths) > 0 {
+		fmt.Fprintln(os.Stderr, "error: paths can't be provided as arguments when reading them from stdin")
+		return 1
+	}
+	if root.staged && (root.readStdin || len(paths) > 0) {
+		fmt.Fprintln(os.Stderr, "error: --staged can't be combined with paths to check")
+		return 1
+	}
+	if root.prune && root.staged {
+		fmt.Fprintln(os.Stderr, "error: --prune can't be used with --staged")
+		return 1
+	}
+	if root.nulInput && !root.readStdin {
+		fmt.Fprintln(os.Stderr, "error: -z can only be used when reading paths from stdin")
+		return 1
+	}
+	if root.prune && root.walkFlags.sparse == "index" {
+		fmt.Fprintln(os.Stderr, "error: --prune can't be used with --sparse=index")
+		return 1
+	}
+	if root.prune && root.walkFlags.submodules == "recurse" {
+		fmt.Fprintln(os.Stderr, "error: --prune can't be used with --submodules=recurse")
+		return 1
+	}
+	if root.prune && root.readStdin {
+		fmt.Fprintln(os.Stderr, "error: --prune can't be used when reading paths from stdin")
+		return 1
+	}
+	if root.readStdin && stdinCount(root.rulesetFlags.paths) > 0 {
+		fmt.Fprintln(os.Stderr, "error: the CODEOWNERS file and the paths to check can't both be read from stdin")
+		return 1
+	}
+	if root.ref != "" {
+		switch {
+		case root.readStdin || root.staged:
+			fmt.Fprintln(os.Stderr, "error: --ref can't be combined with --stdin or --staged")
+			return 1
+		case root.prune || root.watch:
+			fmt.Fprintln(os.Stderr, "error: --ref can't be combined with --prune or --watch")
+			return 1
+		case root.walkFlags.trackedOnly() || root.walkFlags.untrackedOnly:
+			fmt.Fprintln(os.Stderr, "error: --ref can't be combined with --tracked or --untracked")
+			return 1
+		}
+		root.rulesetFlags.ref = root.ref
+	}
+	if root.patch {
+		switch {
+		case root.readStdin || root.staged || root.ref != "" || len(paths) > 0:
+			fmt.Fprintln(os.Stderr, "error: --patch can't be combined with paths to check, --stdin, --staged or --ref")
+			return 1
+		case root.prune || root.watch:
+			fmt.Fprintln(os.Stderr, "error: --patch can't be combined with --prune or --watch")
+			return 1
+		case stdinCount(root.rulesetFlags.paths) > 0:
+			fmt.Fprintln(os.Stderr, "error: the CODEOWNERS file and the patch can't both be read from stdin")
+			return 1
+		}
+	}
+	if root.watch && (root.readStdin || stdinCount(root.rulesetFlags.paths) > 0) {
+		fmt.Fprintln(os.Stderr, "error: --watch can't be used when reading from stdin")
+		return 1
+	}
 	if len(paths) == 0 {
 		paths = append(paths, ".")
 	}
 
-	// Make the @ optional for GitHub teams and usernames
-	for i := range ownerFilters {
-		ownerFilters[i] = strings.TrimLeft(ownerFilters[i], "@")
+	opts := formatOptions{
+		unownedLabel:    root.unownedLabel,
+		unownedOnly:     root.filters.unowned,
+		annotationLevel: root.annotation,
+		color:           color,
+		columnWidth:     width,
+		ownerSummary:    root.patch,
+		showRule:        root.output.showRule,
 	}
+	if root.watch {
+		return watch(watchedFiles(root.rulesetFlags.paths, codeowners.Dialect(root.rulesetFlags.dialect)), paths, func() int {
+			return root.match(paths, newFormatter, opts)
+		})
+	}
+	return root.match(paths, newFormatter, opts)
+}
 
-	// Gather the paths to match first so the matching work, which is CPU-bound,
-	// can be spread across a pool of worker goroutines below.
-	var filePaths []string
-	for _, startPath := range paths {
-		// godirwalk only accepts directories, so we need to handle files separately
-		if !isDir(startPath) {
-			filePaths = append(filePaths, startPath)
-			continue
-		}
+// match matches the paths the flags describe against the ruleset, printing
+// the results, and returns the process exit code.
+func (f *rootFlags) match(paths []string, newFormatter func(w io.Writer, opts formatOptions) formatter, opts formatOptions) int {
+	// The ruleset, and the files git tracks, are loaded each time so that
+	// --watch sees changes to them
+	walkOpts, err := f.walkFlags.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	ruleset, err := f.rulesetFlags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	walkOpts.useSettings(f.rulesetFlags.settings())
+	if err := f.filters.checkRules(ruleset); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if f.prune {
+		walkOpts.pruner = newPruner(ruleset, f.rulesetFlags.settings(), paths, walkOpts.repo)
+	}
+	compiled, err := ruleset.Compile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
 
-		err = filepath.WalkDir(startPath, func(path string, d os.DirEntry, err error) error {
-			if err != nil {
-				return err
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	formatter := newFormatter(out, opts)
+
+	// With --min-owners, any file that's shown breaks the policy
+	found := false
+	foundUnowned := false
+	err = matchPaths(
+		func(send func(string) error) error {
+			if f.readStdin {
+				return readPaths(os.Stdin, f.nulInput, send)
 			}
-			if path == ".git" {
-				return filepath.SkipDir
+			if f.patch {
+				return readPatch(os.Stdin, send)
 			}
-
-			// Only show code owners for files, not directories
-			if !d.IsDir() {
-				if trackedOnly {
-					if _, ok := trackedFiles[path]; !ok {
-						return nil
+			if f.staged || f.ref != "" {
+				// Staged paths, and paths in a revision, are relative to the
+				// repository root, which is what the rules are matched
+				// against, wherever we're run from
+				var files []string
+				var err error
+				if f.staged {
+					files, err = getStagedFiles()
+				} else {
+					files, err = getFilesAtRef(f.ref, paths)
+				}
+				if err != nil {
+					return err
+				}
+				for _, file := range files {
+					if err := send(file); err != nil {
+						return err
 					}
 				}
-				filePaths = append(filePaths, path)
+				return nil
 			}
-			return nil
-		})
+			return walkPaths(paths, walkOpts, send)
+		},
+		func(path string) (*result, error) {
+			// Walked paths are relative to the current directory, but the
+			// rules are relative to the repository root. Paths read from
+			// stdin are taken to be relative to the root already, as git
+			// commands list them that way, as are the paths in a patch,
+			// unless they're absolute.
+			repoPath := path
+			if !f.readStdin && !f.staged && f.ref == "" && !f.patch || f.readStdin && filepath.IsAbs(path) {
+				repoPath = walkOpts.repo.relativePath(path)
+			}
+			pruner := walkOpts.pruner
+			if pruner != nil && strings.HasSuffix(path, string(filepath.Separator)) {
+				return f.result(path, repoPath+"/", pruner.dirRule(path)), nil
+			}
+			sections, last, err := walkOpts.matchSections(compiled, repoPath)
+			if err != nil {
+				return nil, err
+			}
+			rule := combineSections(sections, last)
+			if pruner != nil && pruner.collapsed(path, sections) {
+				return nil, nil
+			}
+			res := f.result(path, repoPath, rule)
+			if res != nil && hasSections(sections) {
+				res.sections = sections
+			}
+			return res, nil
+		},
+		func(res result) error {
+			found = true
+			// Files only shown as unowned because of --owner-type still have
+			// owners, so they don't count
+			if res.unowned && (res.rule == nil || len(res.rule.Owners) == 0 || f.filters.optionalUnowned && res.rule.Optional) {
+				foundUnowned = true
+			}
+			return formatter.write(res)
+		},
+	)
+	if err == nil {
+		err = formatter.close()
+	}
+	if err != nil {
+		// Make sure whatever was matched before the error isn't lost
+		out.Flush()
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if f, ok := formatter.(failer); ok && f.failed() {
+		return 1
+	}
+	if f.filters.minOwners > 0 && found {
+		return 1
+	}
+	if f.errorOnUnowned && foundUnowned {
+		return 1
+	}
+	return 0
+}
 
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v", err)
-			os.Exit(1)
-		}
+// result applies the filters to the rule a path matched, given the path as
+// it was found and relative to the repository root.
+func (f *rootFlags) result(path, repoPath string, rule *codeowners.Rule) *result {
+	if f.rootRelative {
+		path = repoPath
 	}
+	res := f.filters.apply(path, rule)
+	if res != nil {
+		res.repoPath = repoPath
+	}
+	return res
+}
 
-	// Match each path against the ruleset in parallel, preserving input order
-	// for deterministic output. Matching is the dominant, CPU-bound cost.
-	results := make([]string, len(filePaths))
-	var matchErr error
-	var errOnce sync.Once
+// result is the outcome of matching a single path against the ruleset, after
+// the owner filters have been applied.
+type result struct {
+	path string
+	// repoPath is the path relative to the root of the repository, as it
+	// was matched, if that's different to the path shown.
+	repoPath string
+	// owners holds the owners that passed the --owner filters.
+	owners []codeowners.Owner
+	// unowned is set when no rule with owners matched the path.
+	unowned bool
+	// rule is the rule that matched the path, if any. It may be set even when
+	// the path is unowned, as rules aren't required to list owners. With
+	// GitLab-style sections, it combines the rules in sections.
+	rule *codeowners.Rule
+	// sections holds the rule that matched the path in each section of a
+	// CODEOWNERS file with GitLab-style sections.
+	sections []*codeowners.Rule
+}
 
-	workers := runtime.NumCPU()
-	if workers > len(filePaths) {
-		workers = len(filePaths)
+// outputFlags holds the flags that choose how results are rendered. Other than
+// --quiet and --print0, which combine, only one of them may be used at a time.
+type outputFlags struct {
+	format     string
+	template   string
+	pathsOnly  bool
+	print0     bool
+	ownersOnly bool
+	groupBy    string
+	showRule   bool
+}
+
+// newFormatterFunc returns the constructor for the formatter that the output
+// flags ask for.
+func (o outputFlags) newFormatterFunc() (func(w io.Writer, opts formatOptions) formatter, error) {
+	var used []string
+	if flag.CommandLine.Changed("format") {
+		used = append(used, "--format")
+	}
+	if o.template != "" {
+		used = append(used, "--template")
+	}
+	if o.pathsOnly || o.print0 {
+		used = append(used, "--quiet/--print0")
+	}
+	if o.ownersOnly {
+		used = append(used, "--owners-only")
+	}
+	switch o.groupBy {
+	case "file":
+	case "owner":
+		used = append(used, "--group-by owner")
+	default:
+		return nil, fmt.Errorf("can't group by %q", o.groupBy)
+	}
+	if len(used) > 1 {
+		return nil, fmt.Errorf("%s can't be combined", strings.Join(used, " and "))
+	}
+	if o.showRule {
+		if len(used) > 0 && used[0] != "--format" {
+			return nil, fmt.Errorf("--show-rule can't be used with %s", used[0])
+		}
+		if !showRuleFormats[o.format] {
+			return nil, fmt.Errorf("--show-rule can't be used with --format %s", o.format)
+		}
 	}
 
-	var wg sync.WaitGroup
-	var next int64 = -1
-	index := func() int { return int(atomic.AddInt64(&next, 1)) }
+	switch {
+	case o.template != "":
+		tmpl, err := parseTemplate(o.template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		return func(w io.Writer, opts formatOptions) formatter {
+			return newTemplateFormatter(w, tmpl)
+		}, nil
 
-	for w := 0; w < workers; w++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for {
-				i := index()
-				if i >= len(filePaths) {
-					return
-				}
-				line, err := fileOwnersLine(ruleset, filePaths[i], ownerFilters, showUnowned)
-				if err != nil {
-					errOnce.Do(func() { matchErr = err })
-					return
-				}
-				results[i] = line
-			}
-		}()
+	case o.pathsOnly || o.print0:
+		terminator := "\n"
+		if o.print0 {
+			terminator = "\x00"
+		}
+		return func(w io.Writer, opts formatOptions) formatter {
+			return newPathsFormatter(w, terminator)
+		}, nil
+
+	case o.ownersOnly:
+		return newOwnersFormatter, nil
+
+	case o.groupBy == "owner":
+		return newGroupByOwnerFormatter, nil
 	}
-	wg.Wait()
 
-	if matchErr != nil {
-		fmt.Fprintf(os.Stderr, "error: %v", matchErr)
-		os.Exit(1)
+	newFormatter, ok := formatters[o.format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", o.format)
 	}
+	return newFormatter, nil
+}
 
-	out := bufio.NewWriter(os.Stdout)
-	defer out.Flush()
-	for _, line := range results {
-		if line != "" {
-			out.WriteString(line)
-		}
+// parseColumnWidth parses the value of the --column-width flag, returning 0 for
+// automatic sizing.
+func parseColumnWidth(s string) (int, error) {
+	if s == "auto" {
+		return 0, nil
 	}
+	width, err := strconv.Atoi(s)
+	if err != nil || width <= 0 {
+		return 0, fmt.Errorf("invalid column width %q", s)
+	}
+	return width, nil
+}
+
+// rulesetFlags holds the flags that choose the rules to match paths against,
+// which are shared by the commands that load a ruleset.
+type rulesetFlags struct {
+	paths          []string
+	sections       []string
+	braceExpansion bool
+	parseFlags
+	ownerPolicyFlags
+	// ownerPolicy is read from the ownerPolicyFlags when the ruleset is
+	// loaded.
+	ownerPolicy ownerPolicy
+	// ref, if set, is a git revision to read the CODEOWNERS file from when
+	// no paths are given.
+	ref string
 }
 
-// fileOwnersLine returns the formatted output line for a single path (including
-// its trailing newline), or an empty string if the path should not be shown
-// given the current filters.
-func fileOwnersLine(
-	ruleset codeowners.Ruleset,
-	path string,
-	ownerFilters []string,
-	showUnowned bool,
-) (string, error) {
-	rule, err := ruleset.Match(path)
+func (f *rulesetFlags) register(fs *flag.FlagSet) {
+	fs.StringArrayVarP(&f.paths, "file", "f", nil, "CODEOWNERS file path (may be repeated, with rules in later files taking precedence)")
+	fs.StringArrayVar(&f.sections, "section", nil, "only consider rules in this GitLab-style section (may be repeated)")
+	fs.BoolVar(&f.braceExpansion, "brace-expansion", false, "expand braces in patterns, so *.{js,ts} matches *.js and *.ts")
+	f.parseFlags.register(fs)
+	f.ownerPolicyFlags.register(fs)
+	registerGitFlags(fs)
+}
+
+// load loads the ruleset the flags describe.
+func (f *rulesetFlags) load() (codeowners.Ruleset, error) {
+	if _, err := codeowners.ParseDialect(f.dialect); err != nil {
+		return nil, err
+	}
+	var err error
+	if f.ownerPolicy, err = f.ownerPolicyFlags.policy(); err != nil {
+		return nil, err
+	}
+	var ruleset codeowners.Ruleset
+	if f.ref != "" && len(f.paths) == 0 {
+		ruleset, err = loadCodeownersAtRef(f.ref, f.settings())
+	} else {
+		ruleset, err = loadCodeowners(f.paths, f.settings())
+	}
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if len(f.sections) > 0 {
+		ruleset = rulesInSections(ruleset, f.sections)
+	}
+	return ruleset, nil
+}
+
+// settings returns the settings the flags give for parsing CODEOWNERS files.
+// The dialect isn't checked, and the owner policy isn't read, until the ruleset
+// is loaded.
+func (f *rulesetFlags) settings() parseSettings {
+	settings := f.parseFlags.settings()
+	settings.braceExpansion = f.braceExpansion
+	settings.ownerPolicy = f.ownerPolicy
+	return settings
+}
+
+// parseFlags holds the flags that change how CODEOWNERS files are parsed,
+// which are shared by the commands that load a ruleset and those that
+// rewrite a file.
+type parseFlags struct {
+	dialect         string
+	negation        bool
+	lenientOwners   bool
+	lenientPatterns bool
+	ignoreCase      bool
+}
+
+func (f *parseFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.dialect, "dialect", string(codeowners.DialectGitHub), "CODEOWNERS dialect ("+strings.Join(dialectNames(), ", ")+")")
+	fs.BoolVar(&f.negation, "negation", false, "allow rules starting with ! to exclude paths from the rules before them")
+	fs.BoolVar(&f.lenientOwners, "lenient-owners", false, "ignore invalid owners, as GitHub does, rather than failing")
+	fs.BoolVar(&f.lenientPatterns, "lenient-patterns", false, "match invalid patterns, such as src/[abc, literally rather than failing")
+	fs.BoolVar(&f.ignoreCase, "ignore-case", false, "match patterns ignoring case, so *.md matches README.MD")
+}
+
+// settings returns the settings the flags give for parsing CODEOWNERS files,
+// without checking the dialect.
+func (f *parseFlags) settings() parseSettings {
+	return parseSettings{dialect: codeowners.Dialect(f.dialect), negation: f.negation, lenientOwners: f.lenientOwners, lenientPatterns: f.lenientPatterns, ignoreCase: f.ignoreCase}
+}
+
+// parseSettings holds the options CODEOWNERS files are parsed with.
+type parseSettings struct {
+	dialect codeowners.Dialect
+	// negation allows rules starting with ! (--negation).
+	negation bool
+	// lenientOwners ignores invalid owners (--lenient-owners).
+	lenientOwners bool
+	// lenientPatterns matches invalid patterns literally
+	// (--lenient-patterns).
+	lenientPatterns bool
+	// braceExpansion expands braces in patterns (--brace-expansion).
+	braceExpansion bool
+	// ignoreCase matches patterns ignoring case (--ignore-case).
+	ignoreCase bool
+	// allErrors reports every line that can't be parsed, as verify does.
+	allErrors bool
+	// rewriting keeps patterns as they're written and accepts the owners of
+	// every dialect, for the commands that rewrite CODEOWNERS files.
+	rewriting bool
+	// ownerPolicy restricts the owners that are allowed.
+	ownerPolicy ownerPolicy
+}
+
+// options returns the options to parse CODEOWNERS files with.
+func (s parseSettings) options() []codeowners.ParseOption {
+	opts := []codeowners.ParseOption{codeowners.WithDialect(s.dialect)}
+	if s.rewriting {
+		opts = append(opts, codeowners.WithOwnerMatchers(dialectOwnerMatchers))
+	} else {
+		// Paths are normalized, as they're read from the filesystem, where
+		// macOS decomposes accented letters, and from git, which doesn't
+		opts = append(opts, codeowners.WithUnicodeNormalization())
+	}
+	if s.negation {
+		opts = append(opts, codeowners.WithNegation())
+	}
+	if s.lenientOwners {
+		opts = append(opts, codeowners.WithLenientOwners())
+	}
+	if s.lenientPatterns {
+		opts = append(opts, codeowners.WithLenientPatterns())
+	}
+	if s.braceExpansion {
+		opts = append(opts, codeowners.WithBraceExpansion())
+	}
+	if s.ignoreCase {
+		opts = append(opts, codeowners.WithCaseInsensitive())
+	}
+	if s.allErrors {
+		opts = append(opts, codeowners.WithAllErrors())
+	}
+	if s.ownerPolicy.enabled() {
+		opts = append(opts, codeowners.WithOwnerValidator(s.ownerPolicy.validate))
 	}
-	// If we didn't get a match, the file is unowned
-	if rule == nil || rule.Owners == nil {
-		// Unless explicitly requested, don't show unowned files if we're filtering by owner
-		if len(ownerFilters) == 0 || showUnowned {
-			return fmt.Sprintf("%-70s  (unowned)\n", path), nil
+	return opts
+}
+
+// parse parses a CODEOWNERS file with the settings.
+func (s parseSettings) parse(r io.Reader) (codeowners.Ruleset, error) {
+	return codeowners.ParseFile(r, s.options()...)
+}
+
+// load loads and parses the CODEOWNERS file at a path with the settings.
+func (s parseSettings) load(path string) (codeowners.Ruleset, error) {
+	return codeowners.LoadFile(path, s.options()...)
+}
+
+// dialectNames returns the names of the dialects --dialect accepts.
+func dialectNames() []string {
+	names := make([]string, len(codeowners.Dialects))
+	for i, d := range codeowners.Dialects {
+		names[i] = string(d)
+	}
+	return names
+}
+
+// loadCodeowners loads the CODEOWNERS files at the paths provided, layering
+// them so that rules in later files take precedence. A path of - reads the file
+// from stdin. If no paths are provided, the file at the standard location is
+// loaded. The files are parsed with the given settings.
+func loadCodeowners(paths []string, settings parseSettings) (codeowners.Ruleset, error) {
+	if len(paths) == 0 {
+		path := settings.dialect.FindFile()
+		if path == "" {
+			return nil, errors.New("could not find CODEOWNERS file at any of the standard locations")
 		}
-		return "", nil
+		paths = []string{path}
+	}
+	if stdinCount(paths) > 1 {
+		return nil, errors.New("-f - can only be used once")
 	}
 
-	// Figure out which of the owners we need to show according to the --owner filters
-	ownersToShow := make([]string, 0, len(rule.Owners))
-	for _, o := range rule.Owners {
-		// If there are no filters, show all owners
-		filterMatch := len(ownerFilters) == 0 && !showUnowned
-		for _, filter := range ownerFilters {
-			if filter == o.Value {
-				filterMatch = true
+	rulesets := make([]codeowners.Ruleset, 0, len(paths))
+	for _, path := range paths {
+		if path == "-" {
+			ruleset, err := settings.parse(os.Stdin)
+			if err != nil {
+				return nil, fileError("<stdin>", err)
 			}
+			rulesets = append(rulesets, ruleset)
+			continue
 		}
-		if filterMatch {
-			ownersToShow = append(ownersToShow, o.String())
+
+		ruleset, err := settings.load(path)
+		if err != nil {
+			// Errors opening the file already mention the path, but parse
+			// errors only have a line number
+			var pathErr *os.PathError
+			if errors.As(err, &pathErr) {
+				return nil, err
+			}
+			return nil, fileError(path, err)
 		}
+		rulesets = append(rulesets, ruleset)
+	}
+	return codeowners.Concat(rulesets...), nil
+}
+
+// byteOrderMark is the UTF-8 encoding of U+FEFF, which some editors put at the
+// start of a file.
+const byteOrderMark = "\ufeff"
+
+// fileLines splits the contents of a CODEOWNERS file into lines without their
+// endings, as bufio.Scanner does, but for lines of any length, as ParseFile
+// reads them.
+func fileLines(contents []byte) []string {
+	if len(contents) == 0 {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// parseFileError is an error parsing a CODEOWNERS file, which is shown as
+// path:line:column: message, as compilers show errors, so that editors can
+// jump straight to the problem.
+type parseFileError struct {
+	path string
+	err  *codeowners.ParseError
+}
+
+func (e parseFileError) Error() string {
+	if e.err.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.path, e.err.Line, e.err.Column, e.err.Message)
 	}
+	return fmt.Sprintf("%s:%d: %s", e.path, e.err.Line, e.err.Message)
+}
+
+func (e parseFileError) Unwrap() error {
+	return e.err
+}
 
-	// If the owners slice is empty, no owners matched the filters so don't show anything
-	if len(ownersToShow) > 0 {
-		return fmt.Sprintf("%-70s  %s\n", path, strings.Join(ownersToShow, " ")), nil
+// fileError adds the path of a CODEOWNERS file to an error loading it.
+func fileError(path string, err error) error {
+	var parseErr *codeowners.ParseError
+	if errors.As(err, &parseErr) {
+		return parseFileError{path: path, err: parseErr}
 	}
-	return "", nil
+	return fmt.Errorf("%s: %w", path, err)
 }
 
-func loadCodeowners(path string) (codeowners.Ruleset, error) {
-	if path == "" {
-		return codeowners.LoadFileFromStandardLocation()
+// loadCodeownersAtRef loads the CODEOWNERS file at the first of the standard
+// locations that has one in a git revision, with the given settings.
+func loadCodeownersAtRef(ref string, settings parseSettings) (codeowners.Ruleset, error) {
+	for _, path := range settings.dialect.StandardLocations() {
+		contents, err := readFileAtRef(ref, path)
+		if err != nil {
+			continue
+		}
+		ruleset, err := settings.parse(bytes.NewReader(contents))
+		if err != nil {
+			return nil, fileError(ref+":"+path, err)
+		}
+		return ruleset, nil
+	}
+	return nil, fmt.Errorf("could not find CODEOWNERS file at any of the standard locations in %s", ref)
+}
+
+// rulesInSections returns the rules in the ruleset that belong to any of the
+// named sections, so that files not matched by a rule in those sections are
+// treated as unowned.
+func rulesInSections(ruleset codeowners.Ruleset, names []string) codeowners.Ruleset {
+	filtered := codeowners.Ruleset{}
+	for _, rule := range ruleset {
+		for _, name := range names {
+			if rule.InSection(name) {
+				filtered = append(filtered, rule)
+				break
+			}
+		}
 	}
-	return codeowners.LoadFile(path)
+	return filtered
 }
 
-// isDir checks if there's a directory at the path specified.
-func isDir(path string) bool {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return false
+// matchRule returns the rule that decides the owners of a path: the rule
+// that combines the rules it matched in each section of the ruleset.
+func matchRule(ruleset codeowners.Ruleset, path string) (*codeowners.Rule, error) {
+	rules, last, err := matchSections(ruleset, path)
+	if err != nil {
+		return nil, err
 	}
-	return info.IsDir()
+	return combineSections(rules, last), nil
 }
 
-func getTrackedFiles() map[string]bool {
-	// Ensure the script is run inside a Git repository
-	if _, err := os.Stat(".git"); os.IsNotExist(err) {
-		fmt.Fprintln(os.Stderr, "error: this is not a Git repository.")
-		os.Exit(1)
+// matchSections returns the rule a path matches in each section of a ruleset,
+// in the order MatchSections gives them, along with the last of them in the
+// ruleset. It can't be told from their line numbers, which start again in
+// each file the ruleset was loaded from.
+func matchSections(ruleset sectionMatcher, path string) ([]*codeowners.Rule, *codeowners.Rule, error) {
+	rules, err := ruleset.MatchSections(path)
+	if err != nil || len(rules) < 2 {
+		var last *codeowners.Rule
+		if len(rules) == 1 {
+			last = rules[0]
+		}
+		return rules, last, err
+	}
+	// The last rule in each section is the last of the rules it matches, so
+	// the last of all the matching rules is one of them
+	last, err := ruleset.Match(path)
+	if err != nil {
+		return nil, nil, err
 	}
+	return rules, last, nil
+}
 
-	cmd := exec.Command("git", "ls-files")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = os.Stderr
+// combineSections combines the rules a path matched in each section of a
+// ruleset into one, as on GitLab, where each section that has a rule matching
+// a file adds its owners to the file's owners. The combined rule is a copy of
+// last, the last of them in the ruleset, with the owners of them all listed as
+// its own, and is optional if all of its owners come from optional sections.
+// With only one section, it is the rule itself.
+func combineSections(rules []*codeowners.Rule, last *codeowners.Rule) *codeowners.Rule {
+	switch len(rules) {
+	case 0:
+		return nil
+	case 1:
+		return rules[0]
+	}
 
-	if err := cmd.Run(); err != nil {
-		fmt.Fprintln(os.Stderr, "Error running git ls-files:", err)
-		os.Exit(1)
+	var owners []codeowners.Owner
+	seen := make(map[string]bool)
+	optional := true
+	for _, rule := range rules {
+		if len(rule.Owners) > 0 && !rule.Optional {
+			optional = false
+		}
+		for _, o := range rule.Owners {
+			// As on GitHub, owners are compared case-insensitively
+			if key := strings.ToLower(o.String()); !seen[key] {
+				seen[key] = true
+				owners = append(owners, o)
+			}
+		}
 	}
+	combined := *last
+	combined.Owners = owners
+	combined.Optional = optional && len(owners) > 0
+	combined.InheritsOwners = false
+	combined.MinApprovals = 0
+	return &combined
+}
 
-	var trackedFiles = make(map[string]bool)
-	files := strings.Split(out.String(), "\n")
-	for _, file := range files {
-		if file != "" {
-			trackedFiles[file] = true
+// hasSections reports whether any of the rules a path matched belong to a
+// named section, so that their sections are worth showing.
+func hasSections(rules []*codeowners.Rule) bool {
+	for _, rule := range rules {
+		if rule.Section != "" {
+			return true
 		}
 	}
+	return false
+}
 
-	return trackedFiles
+// stdinCount returns the number of the paths that refer to stdin.
+func stdinCount(paths []string) int {
+	n := 0
+	for _, path := range paths {
+		if path == "-" {
+			n++
+		}
+	}
+	return n
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	"github.com/mattn/go-runewidth"
+	flag "github.com/spf13/pflag"
+)
+
+// runOwners runs the owners command, which lists the owners declared in the
+// CODEOWNERS file.
+func runOwners(args []string) int {
+	fs := flag.NewFlagSet("owners", flag.ContinueOnError)
+	var (
+		rulesetFlags rulesetFlags
+		sortBy       string
+	)
+	rulesetFlags.register(fs)
+	fs.StringVar(&sortBy, "sort", "name", "order owners by name, or by the number of rules that reference them (name, rules)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners owners [flags]\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+	if sortBy != "name" && sortBy != "rules" {
+		fmt.Fprintf(os.Stderr, "error: can't sort by %q\n", sortBy)
+		return 1
+	}
+
+	ruleset, err := rulesetFlags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if err := writeDeclaredOwners(out, declaredOwners(ruleset, sortBy == "rules")); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// declaredOwner is an owner referenced by the rules in a ruleset.
+type declaredOwner struct {
+	owner string
+	// rules holds the rules that reference the owner, in order.
+	rules []*codeowners.Rule
+}
+
+// declaredOwners returns each distinct owner referenced by the ruleset,
+// ordered by name or by the number of rules that reference them. Owners are
+// compared case-insensitively, as GitHub does, and are named as they're first
+// written.
+func declaredOwners(ruleset codeowners.Ruleset, byRules bool) []declaredOwner {
+	var owners []declaredOwner
+	index := make(map[string]int)
+	for i := range ruleset {
+		rule := &ruleset[i]
+		for _, o := range rule.Owners {
+			key := strings.ToLower(o.String())
+			j, ok := index[key]
+			if !ok {
+				j = len(owners)
+				index[key] = j
+				owners = append(owners, declaredOwner{owner: o.String()})
+			}
+			// Owners listed twice on a rule only count it once
+			if rules := owners[j].rules; len(rules) > 0 && rules[len(rules)-1] == rule {
+				continue
+			}
+			owners[j].rules = append(owners[j].rules, rule)
+		}
+	}
+
+	sort.SliceStable(owners, func(i, j int) bool {
+		if byRules && len(owners[i].rules) != len(owners[j].rules) {
+			return len(owners[i].rules) > len(owners[j].rules)
+		}
+		return strings.ToLower(owners[i].owner) < strings.ToLower(owners[j].owner)
+	})
+	return owners
+}
+
+func writeDeclaredOwners(w io.Writer, owners []declaredOwner) error {
+	width := 0
+	for _, o := range owners {
+		if n := runewidth.StringWidth(o.owner); n > width {
+			width = n
+		}
+	}
+
+	for _, o := range owners {
+		noun, lineNoun := "rules", "lines"
+		if len(o.rules) == 1 {
+			noun, lineNoun = "rule", "line"
+		}
+		lines := make([]string, 0, len(o.rules))
+		for _, r := range o.rules {
+			lines = append(lines, strconv.Itoa(r.LineNumber))
+		}
+		_, err := fmt.Fprintf(w, "%s  %d %s (%s %s)\n", runewidth.FillRight(o.owner, width), len(o.rules), noun, lineNoun, strings.Join(lines, ", "))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
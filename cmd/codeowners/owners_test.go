@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclaredOwners(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader(strings.Join([]string{
+		"* @org/platform",
+		"/docs/ @alice @org/docs @alice",
+		"/src/ @org/Platform bob@example.com",
+		"/ops/ @org/platform",
+	}, "\n")))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeDeclaredOwners(&buf, declaredOwners(ruleset, false)))
+	assert.Equal(t, strings.Join([]string{
+		"@alice           1 rule (line 2)",
+		"@org/docs        1 rule (line 2)",
+		"@org/platform    3 rules (lines 1, 3, 4)",
+		"bob@example.com  1 rule (line 3)",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeDeclaredOwners(&buf, declaredOwners(ruleset, true)))
+	assert.Equal(t, strings.Join([]string{
+		"@org/platform    3 rules (lines 1, 3, 4)",
+		"@alice           1 rule (line 2)",
+		"@org/docs        1 rule (line 2)",
+		"bob@example.com  1 rule (line 3)",
+		"",
+	}, "\n"), buf.String())
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// devNull is the path a unified diff gives for the missing side of a file that
+// was added or deleted.
+const devNull = "/dev/null"
+
+// readPatch reads a unified diff, as output by git diff or diff -u, and calls
+// send with the path of each file it changes, relative to the repository
+// root. Added and modified files are reported by their new path, deleted
+// files by the path they were deleted from, and renamed files by their new
+// path, as on GitHub. Each file is reported once, even if the patch changes
+// it several times.
+func readPatch(r io.Reader, send func(path string) error) error {
+	scanner := bufio.NewScanner(r)
+	// Lines in a patch can be as long as the lines of the files it changes
+	scanner.Buffer(nil, 16*1024*1024)
+
+	seen := make(map[string]bool)
+	var file patchFile
+	flush := func() error {
+		path := file.path()
+		file = patchFile{}
+		if path == "" || seen[path] {
+			return nil
+		}
+		seen[path] = true
+		return send(path)
+	}
+
+	// oldLines and newLines count the lines left in the current hunk from
+	// each side, so that removed lines starting with "--" aren't mistaken for
+	// the start of the next file
+	oldLines, newLines := 0, 0
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if oldLines > 0 || newLines > 0 {
+			switch {
+			case strings.HasPrefix(line, "\\"):
+				// "\ No newline at end of file"
+			case strings.HasPrefix(line, "-"):
+				oldLines--
+			case strings.HasPrefix(line, "+"):
+				newLines--
+			default:
+				// Context lines are in both sides. Some tools strip the
+				// space from empty ones.
+				oldLines--
+				newLines--
+			}
+			continue
+		}
+
+		var err error
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			if err := flush(); err != nil {
+				return err
+			}
+			if rest := strings.TrimPrefix(line, "diff --git "); rest != line {
+				file.oldPath, file.newPath, err = parseGitDiffHeader(rest)
+			}
+		case strings.HasPrefix(line, "--- "):
+			// Patches that aren't from git have no diff line between files
+			if file.sawNew {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			file.oldPath, err = parsePatchPath(line[len("--- "):], "a/")
+		case strings.HasPrefix(line, "+++ "):
+			file.newPath, err = parsePatchPath(line[len("+++ "):], "b/")
+			file.sawNew = true
+		case strings.HasPrefix(line, "@@ "):
+			oldLines, newLines, err = parseHunkHeader(line)
+		case strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "copy from "):
+			file.oldPath, err = unquotePatchPath(line[strings.Index(line, " from ")+len(" from "):])
+		case strings.HasPrefix(line, "rename to "), strings.HasPrefix(line, "copy to "):
+			file.newPath, err = unquotePatchPath(line[strings.Index(line, " to ")+len(" to "):])
+		case strings.HasPrefix(line, "new file mode "):
+			file.oldPath = devNull
+		case strings.HasPrefix(line, "deleted file mode "):
+			file.newPath = devNull
+		}
+		if err != nil {
+			return fmt.Errorf("patch line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// patchFile holds the paths of a file changed by a patch, as they're found.
+type patchFile struct {
+	oldPath string
+	newPath string
+	// sawNew is set once the +++ line has been seen.
+	sawNew bool
+}
+
+// path returns the path the file should be reported by.
+func (f patchFile) path() string {
+	if f.newPath != "" && f.newPath != devNull {
+		return f.newPath
+	}
+	if f.oldPath != devNull {
+		return f.oldPath
+	}
+	return ""
+}
+
+// parseGitDiffHeader parses the paths from the rest of a "diff --git" line,
+// such as "a/old.go b/new.go". Paths containing spaces are ambiguous unless
+// they're quoted, so if the file wasn't renamed, the split that gives the same
+// path on both sides is used. Renamed files are also described by rename
+// lines, which take precedence.
+func parseGitDiffHeader(rest string) (oldPath, newPath string, err error) {
+	if strings.HasPrefix(rest, `"`) {
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return "", "", err
+		}
+		if oldPath, err = parsePatchPath(quoted, "a/"); err != nil {
+			return "", "", err
+		}
+		newPath, err = parsePatchPath(strings.TrimPrefix(rest[len(quoted):], " "), "b/")
+		return oldPath, newPath, err
+	}
+	if strings.HasSuffix(rest, `"`) {
+		i := strings.LastIndex(rest[:len(rest)-1], ` "`)
+		if i < 0 {
+			return "", "", fmt.Errorf("invalid diff header %q", rest)
+		}
+		if oldPath, err = parsePatchPath(rest[:i], "a/"); err != nil {
+			return "", "", err
+		}
+		newPath, err = parsePatchPath(rest[i+1:], "b/")
+		return oldPath, newPath, err
+	}
+
+	// For an unchanged path p, rest is "a/p b/p", so the halves are the same
+	// length
+	if n := len(rest); n%2 == 1 {
+		if a, b := rest[:n/2], rest[n/2+1:]; rest[n/2] == ' ' && strings.TrimPrefix(a, "a/") == strings.TrimPrefix(b, "b/") {
+			return strings.TrimPrefix(a, "a/"), strings.TrimPrefix(b, "b/"), nil
+		}
+	}
+	i := strings.LastIndex(rest, " b/")
+	if i < 0 {
+		i = strings.LastIndex(rest, " ")
+	}
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid diff header %q", rest)
+	}
+	return strings.TrimPrefix(rest[:i], "a/"), strings.TrimPrefix(rest[i+1:], "b/"), nil
+}
+
+// parsePatchPath parses the path on a ---, +++ or diff --git line, stripping
+// the a/ or b/ prefix git adds, and any timestamp that diff -u adds.
+func parsePatchPath(s, prefix string) (string, error) {
+	if i := strings.IndexByte(s, '\t'); i >= 0 && !strings.HasPrefix(s, `"`) {
+		s = s[:i]
+	}
+	path, err := unquotePatchPath(s)
+	if err != nil || path == devNull {
+		return path, err
+	}
+	return strings.TrimPrefix(path, prefix), nil
+}
+
+// unquotePatchPath unquotes a path that git has quoted because it contains
+// unusual characters, which it escapes as in C, with octal escapes for bytes
+// outside ASCII.
+func unquotePatchPath(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted path %s", s)
+	}
+	return strconv.Unquote(quoted)
+}
+
+// parseHunkHeader returns the number of lines from each side of the file in a
+// hunk, given its header, such as "@@ -1,5 +1,6 @@".
+func parseHunkHeader(line string) (oldLines, newLines int, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+	if oldLines, err = hunkRangeLength(fields[1][1:]); err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+	if newLines, err = hunkRangeLength(fields[2][1:]); err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk header %q", line)
+	}
+	return oldLines, newLines, nil
+}
+
+// hunkRangeLength returns the length of a hunk range such as "12,3". A range
+// without a length covers a single line.
+func hunkRangeLength(r string) (int, error) {
+	start, length, ok := strings.Cut(r, ",")
+	if _, err := strconv.Atoi(start); err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 1, nil
+	}
+	return strconv.Atoi(length)
+}
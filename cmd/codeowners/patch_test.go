@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPatch(t *testing.T) {
+	patch := strings.Join([]string{
+		"diff --git a/src/main.go b/src/main.go",
+		"index 83db48f..bf269f4 100644",
+		"--- a/src/main.go",
+		"+++ b/src/main.go",
+		"@@ -1,4 +1,4 @@",
+		" package main",
+		"--- a/not/a/file.go",
+		"+++ b/not/a/file.go",
+		" ",
+		"\\ No newline at end of file",
+		"diff --git a/docs/new guide.md b/docs/new guide.md",
+		"new file mode 100644",
+		"index 0000000..e69de29",
+		"--- /dev/null",
+		"+++ b/docs/new guide.md",
+		"@@ -0,0 +1 @@",
+		"+# Guide",
+		"diff --git a/old.go b/old.go",
+		"deleted file mode 100644",
+		"index e69de29..0000000",
+		"--- a/old.go",
+		"+++ /dev/null",
+		"@@ -1 +0,0 @@",
+		"-package old",
+		"diff --git a/lib/a b.go b/pkg/a b.go",
+		"similarity index 100%",
+		"rename from lib/a b.go",
+		"rename to pkg/a b.go",
+		`diff --git "a/caf\303\251.md" "b/caf\303\251.md"`,
+		"index 83db48f..bf269f4 100644",
+		"Binary files \"a/caf\\303\\251.md\" and \"b/caf\\303\\251.md\" differ",
+		"diff --git a/src/main.go b/src/main.go",
+		"--- a/src/main.go",
+		"+++ b/src/main.go",
+		"@@ -10 +10 @@",
+		"-x",
+		"+y",
+		"",
+	}, "\n")
+
+	var paths []string
+	require.NoError(t, readPatch(strings.NewReader(patch), func(path string) error {
+		paths = append(paths, path)
+		return nil
+	}))
+	assert.Equal(t, []string{"src/main.go", "docs/new guide.md", "old.go", "pkg/a b.go", "café.md"}, paths)
+}
+
+func TestReadPatchWithoutGit(t *testing.T) {
+	// diff -u adds timestamps, and there's no diff line between files
+	patch := strings.Join([]string{
+		"--- a/README.md\t2024-01-01 00:00:00.000000000 +0000",
+		"+++ b/README.md\t2024-01-02 00:00:00.000000000 +0000",
+		"@@ -1,2 +1,2 @@",
+		"-old",
+		"+new",
+		"",
+		"--- src/app.go\t2024-01-01 00:00:00.000000000 +0000",
+		"+++ src/app.go\t2024-01-02 00:00:00.000000000 +0000",
+		"@@ -3 +3 @@",
+		"-a",
+		"+b",
+	}, "\r\n")
+
+	var paths []string
+	require.NoError(t, readPatch(strings.NewReader(patch), func(path string) error {
+		paths = append(paths, path)
+		return nil
+	}))
+	assert.Equal(t, []string{"README.md", "src/app.go"}, paths)
+
+	err := readPatch(strings.NewReader("--- a/x\n+++ b/x\n@@ -a +b @@\n"), func(string) error { return nil })
+	assert.EqualError(t, err, `patch line 3: invalid hunk header "@@ -a +b @@"`)
+}
+
+func TestParseGitDiffHeader(t *testing.T) {
+	tests := []struct {
+		header   string
+		old, new string
+	}{
+		{"a/main.go b/main.go", "main.go", "main.go"},
+		{"a/with space.go b/with space.go", "with space.go", "with space.go"},
+		{"a/old.go b/new.go", "old.go", "new.go"},
+		{`"a/tab\there.go" "b/tab\there.go"`, "tab\there.go", "tab\there.go"},
+		{`a/plain.go "b/quo\"te.go"`, "plain.go", `quo"te.go`},
+	}
+	for _, tt := range tests {
+		oldPath, newPath, err := parseGitDiffHeader(tt.header)
+		require.NoError(t, err, tt.header)
+		assert.Equal(t, tt.old, oldPath, tt.header)
+		assert.Equal(t, tt.new, newPath, tt.header)
+	}
+}
+
+func TestOwnerSummary(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("/src/ @org/backend @alice\n/docs/ @org/docs\n"))
+	require.NoError(t, err)
+	write := func(f formatter) {
+		for _, path := range []string{"src/main.go", "docs/guide.md", "README.md"} {
+			rule, err := ruleset.Match(path)
+			require.NoError(t, err)
+			require.NoError(t, f.write(*filters{}.apply(path, rule)))
+		}
+		require.NoError(t, f.close())
+	}
+
+	var buf bytes.Buffer
+	write(newTextFormatter(&buf, formatOptions{ownerSummary: true}))
+	assert.Equal(t, strings.Join([]string{
+		"src/main.go    @org/backend @alice",
+		"docs/guide.md  @org/docs",
+		"README.md      (unowned)",
+		"",
+		"owners: @alice @org/backend @org/docs",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	write(newJSONFormatter(&buf, formatOptions{ownerSummary: true}))
+	assert.JSONEq(t, `{
+		"files": [
+			{"path": "src/main.go", "owners": ["@org/backend", "@alice"], "unowned": false},
+			{"path": "docs/guide.md", "owners": ["@org/docs"], "unowned": false},
+			{"path": "README.md", "owners": [], "unowned": true}
+		],
+		"owners": ["@alice", "@org/backend", "@org/docs"]
+	}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, newJSONFormatter(&buf, formatOptions{ownerSummary: true}).close())
+	assert.JSONEq(t, `{"files": [], "owners": []}`, buf.String())
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+)
+
+// ownerPolicy restricts the owners a CODEOWNERS file may list, beyond what's
+// valid syntax.
+type ownerPolicy struct {
+	// requireTeams only allows teams (--require-teams).
+	requireTeams bool
+	// allowed, if not nil, holds the only owners allowed, lowercased
+	// (--allowed-owners-file).
+	allowed map[string]bool
+}
+
+// enabled reports whether the policy restricts owners at all.
+func (p ownerPolicy) enabled() bool {
+	return p.requireTeams || p.allowed != nil
+}
+
+// validate checks an owner against the policy. It's passed to
+// codeowners.WithOwnerValidator, so the errors it returns are reported at the
+// owner's position in the file.
+func (p ownerPolicy) validate(o codeowners.Owner) error {
+	if p.requireTeams && o.Type != codeowners.TeamOwner {
+		return fmt.Errorf("owner %s isn't a team", o)
+	}
+	// As on GitHub, owners are compared case-insensitively
+	if p.allowed != nil && !p.allowed[strings.ToLower(o.String())] {
+		return fmt.Errorf("owner %s isn't in the allowed owners file", o)
+	}
+	return nil
+}
+
+// ownerPolicyFlags holds the flags that set the owner policy, which are shared
+// by the commands that parse a CODEOWNERS file.
+type ownerPolicyFlags struct {
+	requireTeams      bool
+	allowedOwnersPath string
+}
+
+func (f *ownerPolicyFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.requireTeams, "require-teams", false, "only allow teams as owners, not users or emails")
+	fs.StringVar(&f.allowedOwnersPath, "allowed-owners-file", "", "only allow the owners listed in this file, one per line")
+}
+
+// policy returns the owner policy the flags give, reading the allowed owners
+// file if there is one.
+func (f *ownerPolicyFlags) policy() (ownerPolicy, error) {
+	policy := ownerPolicy{requireTeams: f.requireTeams}
+	if f.allowedOwnersPath == "" {
+		return policy, nil
+	}
+	file, err := os.Open(f.allowedOwnersPath)
+	if err != nil {
+		return policy, err
+	}
+	defer file.Close()
+	if policy.allowed, err = parseAllowedOwners(file); err != nil {
+		return policy, fmt.Errorf("%s: %w", f.allowedOwnersPath, err)
+	}
+	return policy, nil
+}
+
+// parseAllowedOwners parses a file listing the owners allowed, such as
+// @org/team or user@example.com, one per line, as they'd be written in a
+// CODEOWNERS file. Blank lines and lines starting with # are ignored. The
+// owners are lowercased.
+func parseAllowedOwners(r io.Reader) (map[string]bool, error) {
+	allowed := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if strings.ContainsAny(line, " \t") {
+			return nil, fmt.Errorf("line %d: expected a single owner", lineNo)
+		}
+		allowed[strings.ToLower(line)] = true
+	}
+	return allowed, scanner.Err()
+}
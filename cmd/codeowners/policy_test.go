@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllowedOwners(t *testing.T) {
+	allowed, err := parseAllowedOwners(strings.NewReader("# Synced from the IdP\n@Org/Docs\n\n  alice@example.com\n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"@org/docs": true, "alice@example.com": true}, allowed)
+
+	_, err = parseAllowedOwners(strings.NewReader("@org/docs\n@org/go @org/api\n"))
+	assert.EqualError(t, err, "line 2: expected a single owner")
+}
+
+func TestOwnerPolicy(t *testing.T) {
+	team := codeowners.Owner{Value: "org/docs", Type: codeowners.TeamOwner}
+	user := codeowners.Owner{Value: "alice", Type: codeowners.UsernameOwner}
+
+	assert.False(t, ownerPolicy{}.enabled())
+	assert.NoError(t, ownerPolicy{}.validate(user))
+
+	teamsOnly := ownerPolicy{requireTeams: true}
+	assert.True(t, teamsOnly.enabled())
+	assert.NoError(t, teamsOnly.validate(team))
+	assert.EqualError(t, teamsOnly.validate(user), "owner @alice isn't a team")
+
+	allowlist := ownerPolicy{allowed: map[string]bool{"@org/docs": true}}
+	assert.NoError(t, allowlist.validate(codeowners.Owner{Value: "Org/Docs", Type: codeowners.TeamOwner}))
+	assert.EqualError(t, allowlist.validate(user), "owner @alice isn't in the allowed owners file")
+
+	// An empty allowed owners file allows no one
+	assert.Error(t, ownerPolicy{allowed: map[string]bool{}}.validate(team))
+}
+
+func TestVerifyCodeownersOwnerPolicy(t *testing.T) {
+	settings := parseSettings{dialect: codeowners.DialectGitHub, ownerPolicy: ownerPolicy{requireTeams: true}}
+	findings, err := verifyCodeowners("CODEOWNERS", strings.NewReader("[Docs] @bob\n*.md @org/docs\n*.go @org/go @alice\n"), settings)
+	require.NoError(t, err)
+	assert.Equal(t, []finding{
+		{Path: "CODEOWNERS", Line: 1, Column: 8, Message: "owner @bob isn't a team", Code: parseErrorCode, Severity: "error"},
+		{Path: "CODEOWNERS", Line: 3, Column: 14, Message: "owner @alice isn't a team", Code: parseErrorCode, Severity: "error"},
+	}, findings)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hmarr/codeowners"
+)
+
+// probeNames are the file names used to test whether a rule covers everything
+// beneath a directory. They contain NUL bytes, which can't appear in real
+// paths, so only wildcards can match them, and between them they rule out
+// wildcards like ? that only match names of a particular length.
+var probeNames = []string{"\x00", "\x00\x00"}
+
+// probeDepth is how many levels of probe paths are checked beneath a
+// directory, which rules out patterns like docs/* that only match files
+// directly inside it.
+const probeDepth = 3
+
+// pruner collapses the output for directories whose files are all owned by
+// the same rules (--prune), so that a single line is shown for the directory
+// rather than one for each file inside it. It's safe for concurrent use.
+type pruner struct {
+	ruleset codeowners.Ruleset
+	// gitea is set for rulesets in Gitea's dialect, where every matching
+	// rule applies, so that each rule is a section of its own.
+	gitea bool
+	// foldCase is set for rulesets matching patterns ignoring case
+	// (--ignore-case).
+	foldCase bool
+	// index holds the position of each rule in the ruleset.
+	index map[*codeowners.Rule]int
+	// repo is used to make directories relative to the repository root
+	// before they're matched against the rules.
+	repo repository
+	// startPaths holds the paths the walk started from, which are shown as
+	// they are rather than being collapsed into their parent directory.
+	startPaths map[string]bool
+
+	mu sync.Mutex
+	// covering caches the indexes of the rules covering each directory, one
+	// for each section with a rule that matches, or nil if there aren't any.
+	covering map[string][]int
+}
+
+func newPruner(ruleset codeowners.Ruleset, settings parseSettings, startPaths []string, repo repository) *pruner {
+	p := &pruner{
+		ruleset:    ruleset,
+		gitea:      settings.dialect == codeowners.DialectGitea,
+		foldCase:   settings.ignoreCase,
+		index:      make(map[*codeowners.Rule]int, len(ruleset)),
+		repo:       repo,
+		startPaths: make(map[string]bool, len(startPaths)),
+		covering:   make(map[string][]int),
+	}
+	for i := range ruleset {
+		p.index[&ruleset[i]] = i
+	}
+	for _, path := range startPaths {
+		p.startPaths[filepath.Clean(path)] = true
+	}
+	return p
+}
+
+// enterDir is called as the walk enters a directory. It reports whether the
+// directory should be shown as a whole, and whether the walk needs to descend
+// into it to find files owned by other rules.
+func (p *pruner) enterDir(dir string) (show, descend bool) {
+	covering := p.coveringRules(dir)
+	if covering == nil {
+		return false, true
+	}
+	// Directories nested inside one that's already been shown only need a
+	// line of their own if other rules take over
+	show = p.startPaths[filepath.Clean(dir)] || !equalInts(p.coveringRules(filepath.Dir(dir)), covering)
+
+	// Only rules after the covering rule in their section, or in a section
+	// without one, can take precedence or add owners, so if none of them
+	// could match anything beneath the directory, every file inside it is
+	// owned by the covering rules and there's no need to look at them.
+	// Negated rules take precedence too, leaving the files they match
+	// unowned, so they're checked with the pattern after their !.
+	for i, rule := range p.ruleset {
+		if p.overridden(i, covering) {
+			continue
+		}
+		pattern := rule.RawPattern()
+		if rule.Negated {
+			pattern = pattern[1:]
+		}
+		if mayMatchBeneath(pattern, p.repo.relativePath(dir), p.foldCase) {
+			return show, true
+		}
+	}
+	return show, false
+}
+
+// overridden reports whether the rule at index i is one of the covering rules,
+// or comes before one in the same section, so it can't own any of the paths
+// they cover.
+func (p *pruner) overridden(i int, covering []int) bool {
+	for _, j := range covering {
+		if j == i || j > i && !p.gitea && strings.EqualFold(p.ruleset[i].Section, p.ruleset[j].Section) {
+			return true
+		}
+	}
+	return false
+}
+
+// dirRule returns the rule covering a directory shown by enterDir, combining
+// the covering rules as combineSections does.
+func (p *pruner) dirRule(dir string) *codeowners.Rule {
+	covering := p.coveringRules(dir)
+	if covering == nil {
+		return nil
+	}
+	rules := make([]*codeowners.Rule, len(covering))
+	last := covering[0]
+	for k, i := range covering {
+		rules[k] = &p.ruleset[i]
+		if i > last {
+			last = i
+		}
+	}
+	return combineSections(rules, &p.ruleset[last])
+}
+
+// collapsed reports whether a file has been collapsed into the line shown for
+// one of the directories containing it, given the rules it matched in each
+// section.
+func (p *pruner) collapsed(path string, sections []*codeowners.Rule) bool {
+	if len(sections) == 0 || p.startPaths[filepath.Clean(path)] {
+		return false
+	}
+	matched, ok := p.indexes(sections)
+	return ok && equalInts(p.coveringRules(filepath.Dir(path)), matched)
+}
+
+// coveringRules returns the indexes of the rules that every path beneath a
+// directory matches in each section, or nil if they don't all match the same
+// rules, or don't match any.
+func (p *pruner) coveringRules(dir string) []int {
+	dir = filepath.Clean(dir)
+	p.mu.Lock()
+	covering, ok := p.covering[dir]
+	p.mu.Unlock()
+	if ok {
+		return covering
+	}
+
+	covering = p.findCoveringRules(dir)
+	p.mu.Lock()
+	p.covering[dir] = covering
+	p.mu.Unlock()
+	return covering
+}
+
+func (p *pruner) findCoveringRules(dir string) []int {
+	var covering []int
+	for depth := 1; depth <= probeDepth; depth++ {
+		for _, name := range probeNames {
+			probe := dir
+			for d := 0; d < depth; d++ {
+				probe = filepath.Join(probe, name)
+			}
+			matched := p.matchSections(probe)
+			if matched == nil || (covering != nil && !equalInts(matched, covering)) {
+				return nil
+			}
+			covering = matched
+		}
+	}
+	return covering
+}
+
+// matchSections returns the indexes of the rules matching path in each
+// section, or nil if none match or the path can't be matched.
+func (p *pruner) matchSections(path string) []int {
+	sections, err := p.ruleset.MatchSections(p.repo.relativePath(path))
+	if err != nil || len(sections) == 0 {
+		return nil
+	}
+	matched, _ := p.indexes(sections)
+	return matched
+}
+
+// indexes returns the positions of rules in the ruleset, and false if any of
+// them aren't in it, as with the rules of a submodule's CODEOWNERS file.
+func (p *pruner) indexes(rules []*codeowners.Rule) ([]int, bool) {
+	indexes := make([]int, len(rules))
+	for k, rule := range rules {
+		i, ok := p.index[rule]
+		if !ok {
+			return nil, false
+		}
+		indexes[k] = i
+	}
+	return indexes, true
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mayMatchBeneath conservatively reports whether a pattern could match any
+// path beneath dir. Only patterns anchored to the root with a literal prefix
+// that rules out the directory are known not to. Segments are compared
+// ignoring case if foldCase is set, as the rules match them, and in NFC, as
+// the rules are parsed with codeowners.WithUnicodeNormalization.
+func mayMatchBeneath(pattern, dir string, foldCase bool) bool {
+	trimmed := strings.TrimSuffix(nfc(pattern), "/")
+	// Patterns without a slash (other than a trailing one) match at any depth
+	if !strings.HasPrefix(trimmed, "/") && !strings.Contains(trimmed, "/") {
+		return true
+	}
+
+	dir = nfc(filepath.ToSlash(filepath.Clean(dir)))
+	if dir == "." {
+		return true
+	}
+	dirSegs := strings.Split(dir, "/")
+	patternSegs := strings.Split(strings.TrimPrefix(trimmed, "/"), "/")
+	for j := 0; j < len(dirSegs) && j < len(patternSegs); j++ {
+		if strings.ContainsAny(patternSegs[j], "*?[\\") {
+			return true
+		}
+		if patternSegs[j] != dirSegs[j] && !(foldCase && strings.EqualFold(patternSegs[j], dirSegs[j])) {
+			return false
+		}
+	}
+	return true
+}
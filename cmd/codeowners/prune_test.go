@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruner(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader(strings.Join([]string{
+		"* @all",
+		"/docs/ @docs",
+		"/docs/build/ @build",
+		"/docs/index.md @index",
+		"/src/*.go @go",
+		"/src/api/ @api",
+	}, "\n")))
+	require.NoError(t, err)
+	p := newPruner(ruleset, parseSettings{dialect: codeowners.DialectGitHub}, []string{"."}, repository{})
+
+	examples := []struct {
+		dir     string
+		show    bool
+		descend bool
+	}{
+		{dir: ".", show: true, descend: true},
+		// Later rules match files beneath docs, so they have to be found
+		{dir: "docs", show: true, descend: true},
+		{dir: "docs/build", show: true, descend: false},
+		// Still covered by the line shown for docs
+		{dir: "docs/guides", show: false, descend: false},
+		// src/*.go only matches files directly inside src
+		{dir: "src", show: false, descend: true},
+		{dir: "src/api", show: true, descend: false},
+		{dir: "src/api/v1", show: false, descend: false},
+	}
+	for _, e := range examples {
+		show, descend := p.enterDir(e.dir)
+		assert.Equal(t, e.show, show, "show %s", e.dir)
+		assert.Equal(t, e.descend, descend, "descend %s", e.dir)
+	}
+
+	collapsed := func(path string) bool {
+		sections, err := ruleset.MatchSections(path)
+		require.NoError(t, err)
+		return p.collapsed(path, sections)
+	}
+	assert.True(t, collapsed("docs/guide.md"))
+	assert.False(t, collapsed("docs/index.md"))
+	assert.False(t, collapsed("src/main.go"))
+	assert.True(t, collapsed("src/Makefile"))
+}
+
+func TestPrunerNegation(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("* @a\n/docs/ @org/docs\n!/docs/internal/\n"), codeowners.WithNegation())
+	require.NoError(t, err)
+	p := newPruner(ruleset, parseSettings{dialect: codeowners.DialectGitHub}, []string{"."}, repository{})
+
+	// The negated rule leaves the files beneath docs/internal unowned, so
+	// docs has to be descended into to find them
+	show, descend := p.enterDir("docs")
+	assert.True(t, show)
+	assert.True(t, descend)
+
+	show, descend = p.enterDir("docs/internal")
+	assert.True(t, show)
+	assert.False(t, descend)
+	assert.Same(t, &ruleset[2], p.dirRule("docs/internal"))
+}
+
+func TestPrunerSections(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("[All]\n* @org/all\n[Docs]\n/docs/ @org/docs\n"))
+	require.NoError(t, err)
+	p := newPruner(ruleset, parseSettings{dialect: codeowners.DialectGitHub}, []string{"."}, repository{})
+
+	show, descend := p.enterDir(".")
+	assert.True(t, show)
+	assert.True(t, descend)
+	// The files in docs are owned by both sections' rules
+	show, descend = p.enterDir("docs")
+	assert.True(t, show)
+	assert.False(t, descend)
+	assert.Equal(t, []codeowners.Owner{{Value: "org/all", Type: codeowners.TeamOwner}, {Value: "org/docs", Type: codeowners.TeamOwner}}, p.dirRule("docs").Owners)
+
+	sections, err := ruleset.MatchSections("docs/guide.md")
+	require.NoError(t, err)
+	assert.True(t, p.collapsed("docs/guide.md", sections))
+	sections, err = ruleset.MatchSections("src/main.go")
+	require.NoError(t, err)
+	assert.True(t, p.collapsed("src/main.go", sections))
+	assert.Equal(t, []codeowners.Owner{{Value: "org/all", Type: codeowners.TeamOwner}}, p.dirRule(".").Owners)
+}
+
+func TestPrunerGitea(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("docs/.* @org/docs\n.* @org/all\n"), codeowners.WithDialect(codeowners.DialectGitea))
+	require.NoError(t, err)
+	p := newPruner(ruleset, parseSettings{dialect: codeowners.DialectGitea}, []string{"."}, repository{})
+
+	// Every matching rule applies, so the earlier rule for docs still adds
+	// its owners
+	show, descend := p.enterDir(".")
+	assert.True(t, show)
+	assert.True(t, descend)
+	show, _ = p.enterDir("docs")
+	assert.True(t, show)
+}
+
+func TestMayMatchBeneath(t *testing.T) {
+	examples := []struct {
+		pattern string
+		dir     string
+		match   bool
+	}{
+		{"*.go", "docs", true},
+		{"build/", "docs", true},
+		{"/docs/build/", "docs", true},
+		{"/docs/build/", "docs/build/out", true},
+		{"/docs/build/", "docs/guides", false},
+		{"/src/", "docs", false},
+		{"src/api", "docs", false},
+		{"/src/*/api", "src/v1", true},
+		{"/src/*/api", "docs/v1", false},
+		{"**/api", "docs", true},
+		{"/src/", ".", true},
+	}
+	for _, e := range examples {
+		assert.Equal(t, e.match, mayMatchBeneath(e.pattern, e.dir, false), "%s beneath %s", e.pattern, e.dir)
+	}
+	assert.False(t, mayMatchBeneath("/Src/api/", "src", false))
+	assert.True(t, mayMatchBeneath("/Src/api/", "src", true))
+	assert.False(t, mayMatchBeneath("/Src/api/", "docs", true))
+	// Directories named in NFD, as macOS names them, match patterns in NFC
+	assert.True(t, mayMatchBeneath("/docs/\u00fatil/sub/", "docs/u\u0301til", false))
+	assert.False(t, mayMatchBeneath("/docs/\u00fatil/sub/", "docs/otro", false))
+}
+
+func TestPrunerNFD(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("* @a\n/docs/\u00fatil/sub/ @b\n"), codeowners.WithUnicodeNormalization())
+	require.NoError(t, err)
+	p := newPruner(ruleset, parseSettings{dialect: codeowners.DialectGitHub}, []string{"."}, repository{})
+
+	show, descend := p.enterDir("docs")
+	assert.False(t, show)
+	assert.True(t, descend)
+	show, descend = p.enterDir("docs/u\u0301til")
+	assert.False(t, show)
+	assert.True(t, descend)
+	show, descend = p.enterDir("docs/u\u0301til/sub")
+	assert.True(t, show)
+	assert.False(t, descend)
+}
+
+func TestPrunerIgnoringCase(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader("* @a\n/Src/api/ @b\n"), codeowners.WithCaseInsensitive())
+	require.NoError(t, err)
+	p := newPruner(ruleset, parseSettings{dialect: codeowners.DialectGitHub, ignoreCase: true}, []string{"."}, repository{})
+
+	// The rule for Src/api also matches files beneath src/api
+	show, descend := p.enterDir("src")
+	assert.False(t, show)
+	assert.True(t, descend)
+	show, descend = p.enterDir("src/api")
+	assert.True(t, show)
+	assert.False(t, descend)
+	assert.Equal(t, []codeowners.Owner{{Value: "b", Type: codeowners.UsernameOwner}}, p.dirRule("src/api").Owners)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+)
+
+// runReviewers runs the reviewers command, which works out who needs to review
+// a set of changed files.
+func runReviewers(args []string) int {
+	fs := flag.NewFlagSet("reviewers", flag.ContinueOnError)
+	var (
+		rulesetFlags rulesetFlags
+		changedSince string
+		minimal      bool
+		preferTeams  bool
+		format       string
+	)
+	rulesetFlags.register(fs)
+	fs.StringVar(&changedSince, "changed-since", "", "check the files changed since this git revision, rather than the paths provided")
+	fs.BoolVar(&minimal, "minimal", false, "only show the smallest set of owners that covers every owned file")
+	fs.BoolVar(&preferTeams, "prefer-teams", false, "with --minimal, only choose individuals for files that no team owns")
+	fs.StringVar(&format, "format", "text", "output format (text, json)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners reviewers [flags] <path>...\n")
+		fmt.Fprintf(os.Stderr, "       codeowners reviewers [flags] --changed-since <revision>\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if (changedSince == "") == (fs.NArg() == 0) {
+		fmt.Fprintln(os.Stderr, "error: expected either paths or --changed-since")
+		fs.Usage()
+		return 2
+	}
+	if preferTeams && !minimal {
+		fmt.Fprintln(os.Stderr, "error: --prefer-teams can only be used with --minimal")
+		return 1
+	}
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "error: unknown format %q\n", format)
+		return 1
+	}
+
+	ruleset, err := rulesetFlags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	// Changed files are relative to the repository root already, as the
+	// rules are, but paths given as arguments are relative to the current
+	// directory
+	var paths []string
+	if changedSince != "" {
+		paths, err = getChangedFiles(changedSince)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	} else {
+		repo := findRepository()
+		for _, path := range fs.Args() {
+			paths = append(paths, repo.relativePath(path))
+		}
+	}
+
+	files := make([]ownedFile, 0, len(paths))
+	for _, path := range paths {
+		rule, err := matchRule(ruleset, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		file := ownedFile{path: path}
+		if rule != nil {
+			file.owners = rule.Owners
+		}
+		files = append(files, file)
+	}
+
+	var set reviewerSet
+	if minimal {
+		set = minimalReviewers(files, preferTeams)
+	} else {
+		set = allReviewers(files)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if format == "json" {
+		err = set.writeJSON(out)
+	} else {
+		err = set.write(out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// ownedFile is a changed file, along with the owners of the rule it matched.
+type ownedFile struct {
+	path   string
+	owners []codeowners.Owner
+}
+
+// reviewer is an owner chosen to review some of the changed files.
+type reviewer struct {
+	Owner string   `json:"owner"`
+	Files []string `json:"files"`
+}
+
+// reviewerSet holds the reviewers of a set of changed files, and the changed
+// files that nobody owns.
+type reviewerSet struct {
+	Reviewers []reviewer `json:"reviewers"`
+	Unowned   []string   `json:"unowned"`
+}
+
+// candidateReviewer is an owner of some of the changed files, identified by
+// the index of each file it owns.
+type candidateReviewer struct {
+	owner codeowners.Owner
+	files []int
+}
+
+// candidateReviewers returns every owner of the files, sorted by name, along
+// with the paths of the files without owners. As on GitHub, owners are
+// compared case-insensitively, and the first spelling seen is used.
+func candidateReviewers(files []ownedFile) ([]*candidateReviewer, []string) {
+	byName := make(map[string]*candidateReviewer)
+	unowned := []string{}
+	for i, file := range files {
+		if len(file.owners) == 0 {
+			unowned = append(unowned, file.path)
+			continue
+		}
+		for _, o := range file.owners {
+			key := strings.ToLower(o.String())
+			c := byName[key]
+			if c == nil {
+				c = &candidateReviewer{owner: o}
+				byName[key] = c
+			}
+			// Rules can list an owner twice
+			if n := len(c.files); n == 0 || c.files[n-1] != i {
+				c.files = append(c.files, i)
+			}
+		}
+	}
+
+	candidates := make([]*candidateReviewer, 0, len(byName))
+	for _, c := range byName {
+		candidates = append(candidates, c)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return strings.ToLower(candidates[i].owner.String()) < strings.ToLower(candidates[j].owner.String())
+	})
+	return candidates, unowned
+}
+
+// allReviewers returns every owner of the files, as GitHub would request, with
+// all the files each of them owns.
+func allReviewers(files []ownedFile) reviewerSet {
+	candidates, unowned := candidateReviewers(files)
+	set := reviewerSet{Reviewers: []reviewer{}, Unowned: unowned}
+	for _, c := range candidates {
+		r := reviewer{Owner: c.owner.String()}
+		for _, i := range c.files {
+			r.Files = append(r.Files, files[i].path)
+		}
+		set.Reviewers = append(set.Reviewers, r)
+	}
+	return set
+}
+
+// minimalReviewers returns a small set of owners that between them own every
+// file that has an owner, listing each file under the owner it was covered
+// by. Finding the smallest set is the set cover problem, so the owner who
+// owns the most files not yet covered is chosen until every file is covered,
+// which gets close without trying every combination. Ties are broken by name,
+// so the result is stable.
+//
+// With preferTeams, files owned by a team are covered by teams first, and
+// individuals are only chosen for the files left over.
+func minimalReviewers(files []ownedFile, preferTeams bool) reviewerSet {
+	candidates, unowned := candidateReviewers(files)
+	set := reviewerSet{Reviewers: []reviewer{}, Unowned: unowned}
+	covered := make([]bool, len(files))
+
+	cover := func(allowed func(codeowners.Owner) bool) {
+		for {
+			var best *candidateReviewer
+			bestCount := 0
+			for _, c := range candidates {
+				if !allowed(c.owner) {
+					continue
+				}
+				count := 0
+				for _, i := range c.files {
+					if !covered[i] {
+						count++
+					}
+				}
+				if count > bestCount {
+					best, bestCount = c, count
+				}
+			}
+			if best == nil {
+				return
+			}
+			r := reviewer{Owner: best.owner.String()}
+			for _, i := range best.files {
+				if !covered[i] {
+					covered[i] = true
+					r.Files = append(r.Files, files[i].path)
+				}
+			}
+			set.Reviewers = append(set.Reviewers, r)
+		}
+	}
+	if preferTeams {
+		cover(func(o codeowners.Owner) bool { return o.Type == codeowners.TeamOwner })
+	}
+	cover(func(codeowners.Owner) bool { return true })
+	return set
+}
+
+func (s reviewerSet) write(w io.Writer) error {
+	for _, r := range s.Reviewers {
+		if _, err := fmt.Fprintf(w, "%s (%s)\n", r.Owner, fileCount(len(r.Files))); err != nil {
+			return err
+		}
+		for _, path := range r.Files {
+			if _, err := fmt.Fprintf(w, "  %s\n", path); err != nil {
+				return err
+			}
+		}
+	}
+	if len(s.Unowned) > 0 {
+		if _, err := fmt.Fprintf(w, "%s (%s)\n", unownedRow, fileCount(len(s.Unowned))); err != nil {
+			return err
+		}
+		for _, path := range s.Unowned {
+			if _, err := fmt.Fprintf(w, "  %s\n", path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fileCount describes a number of files.
+func fileCount(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
+
+func (s reviewerSet) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reviewerFiles(t *testing.T, contents string, paths ...string) []ownedFile {
+	ruleset, err := codeowners.ParseFile(strings.NewReader(contents))
+	require.NoError(t, err)
+	var files []ownedFile
+	for _, path := range paths {
+		rule, err := ruleset.Match(path)
+		require.NoError(t, err)
+		file := ownedFile{path: path}
+		if rule != nil {
+			file.owners = rule.Owners
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+func TestMinimalReviewers(t *testing.T) {
+	files := reviewerFiles(t,
+		"/api/ @org/api @alice\n/web/ @org/web @alice\n/db/ @org/db\n/docs/ @bob\n/tmp/\n",
+		"api/server.go", "web/app.js", "db/schema.sql", "docs/index.md", "tmp/scratch", "api/client.go",
+	)
+
+	set := minimalReviewers(files, false)
+	assert.Equal(t, reviewerSet{
+		Reviewers: []reviewer{
+			{Owner: "@alice", Files: []string{"api/server.go", "web/app.js", "api/client.go"}},
+			{Owner: "@bob", Files: []string{"docs/index.md"}},
+			{Owner: "@org/db", Files: []string{"db/schema.sql"}},
+		},
+		Unowned: []string{"tmp/scratch"},
+	}, set)
+
+	// Teams are chosen where there's one, even though @alice covers more
+	set = minimalReviewers(files, true)
+	assert.Equal(t, reviewerSet{
+		Reviewers: []reviewer{
+			{Owner: "@org/api", Files: []string{"api/server.go", "api/client.go"}},
+			{Owner: "@org/db", Files: []string{"db/schema.sql"}},
+			{Owner: "@org/web", Files: []string{"web/app.js"}},
+			{Owner: "@bob", Files: []string{"docs/index.md"}},
+		},
+		Unowned: []string{"tmp/scratch"},
+	}, set)
+}
+
+func TestAllReviewers(t *testing.T) {
+	files := reviewerFiles(t, "*.go @org/go @Alice\n/api/ @org/api @alice\n", "api/server.go", "main.go", "README.md")
+
+	set := allReviewers(files)
+	assert.Equal(t, reviewerSet{
+		Reviewers: []reviewer{
+			{Owner: "@alice", Files: []string{"api/server.go", "main.go"}},
+			{Owner: "@org/api", Files: []string{"api/server.go"}},
+			{Owner: "@org/go", Files: []string{"main.go"}},
+		},
+		Unowned: []string{"README.md"},
+	}, set)
+
+	var buf bytes.Buffer
+	require.NoError(t, minimalReviewers(files, false).write(&buf))
+	assert.Equal(t, "@alice (2 files)\n  api/server.go\n  main.go\n(unowned) (1 file)\n  README.md\n", buf.String())
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// The SARIF rule ID and message used for unowned files.
+const (
+	sarifUnownedRuleID  = "unowned-file"
+	sarifUnownedMessage = "File has no code owner"
+)
+
+// The subset of the SARIF 2.1.0 object model needed to report unowned files.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// sarifFormatter writes a SARIF document in which each unowned file is a
+// result, for upload to code scanning tools. Owned files produce no output.
+// SARIF is a single JSON document, so results are held until close is called.
+type sarifFormatter struct {
+	w       io.Writer
+	results []sarifResult
+}
+
+func newSARIFFormatter(w io.Writer, opts formatOptions) formatter {
+	return &sarifFormatter{w: w}
+}
+
+func (f *sarifFormatter) write(res result) error {
+	if !res.unowned {
+		return nil
+	}
+	// Code scanning resolves artifacts relative to the root of the
+	// repository, wherever the command was run from
+	path := res.path
+	if res.repoPath != "" {
+		path = res.repoPath
+	}
+	f.results = append(f.results, sarifResult{
+		RuleID:  sarifUnownedRuleID,
+		Level:   "warning",
+		Message: sarifMessage{Text: sarifUnownedMessage},
+		Locations: []sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: sarifURI(path)},
+			},
+		}},
+	})
+	return nil
+}
+
+func (f *sarifFormatter) close() error {
+	results := f.results
+	if results == nil {
+		// An empty run is still expected to have a results array
+		results = []sarifResult{}
+	}
+	doc := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "codeowners",
+				Version:        toolVersion(),
+				InformationURI: "https://github.com/hmarr/codeowners",
+				Rules: []sarifRule{{
+					ID:               sarifUnownedRuleID,
+					ShortDescription: sarifMessage{Text: sarifUnownedMessage},
+				}},
+			}},
+			Results: results,
+		}},
+	}
+
+	enc := json.NewEncoder(f.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// sarifURI converts a file path into the relative URI reference SARIF uses to
+// identify artifacts.
+func sarifURI(path string) string {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "./")
+	return (&url.URL{Path: path}).String()
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+)
+
+const (
+	// reloadInterval is how often the CODEOWNERS files are checked for
+	// changes with --reload.
+	reloadInterval = 2 * time.Second
+	// maxRequestSize limits the size of the body of batch lookups.
+	maxRequestSize = 10 << 20
+)
+
+// runServe runs the serve command, which answers ownership lookups over HTTP.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	var (
+		rulesetFlags rulesetFlags
+		listen       string
+		reload       bool
+	)
+	rulesetFlags.register(fs)
+	fs.StringVar(&listen, "listen", ":8080", "address to listen on")
+	fs.BoolVar(&reload, "reload", false, "load the CODEOWNERS file again on SIGHUP, or when it's modified")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners serve [flags]\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	if reload && stdinCount(rulesetFlags.paths) > 0 {
+		fmt.Fprintln(os.Stderr, "error: --reload can't be used when reading the CODEOWNERS file from stdin")
+		return 1
+	}
+
+	s := &ownersServer{load: rulesetFlags.load}
+	if err := s.reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if reload {
+		go s.reloadOnChange(rulesetFlags.paths, codeowners.Dialect(rulesetFlags.dialect))
+	}
+
+	fmt.Fprintf(os.Stderr, "listening on %s\n", listen)
+	if err := http.ListenAndServe(listen, s.handler()); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// ownersServer answers ownership lookups against a ruleset that can be
+// replaced while requests are being handled.
+type ownersServer struct {
+	load func() (codeowners.Ruleset, error)
+
+	mu      sync.RWMutex
+	ruleset codeowners.Ruleset
+}
+
+// reload loads the ruleset again. If it can't be loaded, the current ruleset
+// is kept.
+func (s *ownersServer) reload() error {
+	ruleset, err := s.load()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ruleset = ruleset
+	return nil
+}
+
+// reloadOnChange reloads the ruleset whenever the process receives SIGHUP, or
+// the CODEOWNERS files given with -f (or the one at the standard location)
+// are modified. It never returns.
+func (s *ownersServer) reloadOnChange(paths []string, dialect codeowners.Dialect) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	stamp := fileStamp(watchedFiles(paths, dialect))
+	for {
+		select {
+		case <-hup:
+		case <-ticker.C:
+			// The file at the standard location can move, so look for it
+			// each time
+			current := fileStamp(watchedFiles(paths, dialect))
+			if current == stamp {
+				continue
+			}
+			stamp = current
+		}
+		if err := s.reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: keeping the current rules, as reloading failed: %v\n", err)
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "reloaded CODEOWNERS")
+	}
+}
+
+// fileStamp summarizes the paths, modification times and sizes of the files
+// provided, so that changes to any of them can be spotted.
+func fileStamp(paths []string) string {
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "%s:", path)
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%d:%d", info.ModTime().UnixNano(), info.Size())
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (s *ownersServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/owners", s.handleOwners)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// handleOwners looks up the owners of a single path passed in the path query
+// parameter with GET, or of each path in a JSON array with POST.
+func (s *ownersServer) handleOwners(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			writeJSONError(w, http.StatusBadRequest, errors.New("missing path parameter"))
+			return
+		}
+		results, err := s.lookup([]string{path})
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, results[0])
+
+	case http.MethodPost:
+		var paths []string
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err := dec.Decode(&paths); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("expected a JSON array of paths: %w", err))
+			return
+		}
+		results, err := s.lookup(paths)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, results)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+// lookup matches each path against the current ruleset.
+func (s *ownersServer) lookup(paths []string) ([]jsonResult, error) {
+	s.mu.RLock()
+	ruleset := s.ruleset
+	s.mu.RUnlock()
+
+	results := make([]jsonResult, 0, len(paths))
+	for _, path := range paths {
+		// Paths are relative to the repository root, as they are on GitHub,
+		// but a leading slash is harmless
+		rule, err := matchRule(ruleset, strings.TrimPrefix(path, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		res := result{path: path, unowned: rule == nil || len(rule.Owners) == 0, rule: rule}
+		jr := jsonResult{Path: path, Unowned: res.unowned}
+		if rule != nil {
+			res.owners = rule.Owners
+			jr.Pattern = rule.RawPattern()
+		}
+		jr.Owners = res.ownerStrings()
+		results = append(results, jr)
+	}
+	return results, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeOwners(t *testing.T) {
+	contents := "* @org/everyone\n/docs/ @org/docs\n/generated/\n"
+	s := &ownersServer{load: func() (codeowners.Ruleset, error) {
+		return codeowners.ParseFile(strings.NewReader(contents))
+	}}
+	require.NoError(t, s.reload())
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/owners?path=docs/index.md")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var single jsonResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&single))
+	assert.Equal(t, jsonResult{Path: "docs/index.md", Owners: []string{"@org/docs"}, Pattern: "/docs/"}, single)
+
+	resp, err = http.Post(srv.URL+"/owners", "application/json", strings.NewReader(`["main.go", "/generated/api.go"]`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var batch []jsonResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&batch))
+	assert.Equal(t, []jsonResult{
+		{Path: "main.go", Owners: []string{"@org/everyone"}, Pattern: "*"},
+		{Path: "/generated/api.go", Owners: []string{}, Unowned: true, Pattern: "/generated/"},
+	}, batch)
+
+	// Reloading picks up the new rules
+	contents = "* @org/new\n"
+	require.NoError(t, s.reload())
+	results, err := s.lookup([]string{"docs/index.md"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@org/new"}, results[0].Owners)
+
+	// A failed reload keeps the current rules
+	contents = "[invalid\n"
+	assert.Error(t, s.reload())
+	results, err = s.lookup([]string{"docs/index.md"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"@org/new"}, results[0].Owners)
+}
+
+func TestServeErrors(t *testing.T) {
+	s := &ownersServer{load: func() (codeowners.Ruleset, error) { return codeowners.Ruleset{}, nil }}
+	require.NoError(t, s.reload())
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"missing path", http.MethodGet, "/owners", "", http.StatusBadRequest},
+		{"invalid batch", http.MethodPost, "/owners", `{"path": "x"}`, http.StatusBadRequest},
+		{"wrong method", http.MethodDelete, "/owners", "", http.StatusMethodNotAllowed},
+		{"health check", http.MethodGet, "/healthz", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+)
+
+// runSort runs the sort command, which reorders the rules in a CODEOWNERS file
+// from least to most specific.
+func runSort(args []string) int {
+	fs := flag.NewFlagSet("sort", flag.ContinueOnError)
+	var (
+		path  string
+		check bool
+	)
+	fs.StringVarP(&path, "file", "f", "", "CODEOWNERS file path (- to read from stdin and write to stdout)")
+	fs.BoolVar(&check, "check", false, "exit with an error if the rules aren't sorted, rather than rewriting the file")
+	var parse parseFlags
+	parse.register(fs)
+	registerGitFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners sort [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Warns about rules whose reordering changes the owners of some paths.\n\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	d, err := codeowners.ParseDialect(parse.dialect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if path == "" {
+		path = d.FindFile()
+		if path == "" {
+			fmt.Fprintln(os.Stderr, "error: could not find CODEOWNERS file at any of the standard locations")
+			return 1
+		}
+	}
+	name := path
+	var contents []byte
+	if path == "-" {
+		name = "<stdin>"
+		contents, err = io.ReadAll(os.Stdin)
+	} else {
+		contents, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	sorted, warnings, err := sortCodeowners(contents, parse.settings())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", fileError(name, err))
+		return 1
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s:%d: %s\n", name, w.Line, w.Message)
+	}
+
+	switch {
+	case check:
+		if !bytes.Equal(contents, sorted) {
+			fmt.Fprintf(os.Stderr, "%s isn't sorted\n", name)
+			return 1
+		}
+	case path == "-":
+		if _, err := os.Stdout.Write(sorted); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	case !bytes.Equal(contents, sorted):
+		info, err := os.Stat(path)
+		if err == nil {
+			err = os.WriteFile(path, sorted, info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// sortedRule is a rule being sorted, along with the comments that precede it,
+// which move with it.
+type sortedRule struct {
+	rule     codeowners.Rule
+	comments []string
+	text     string
+	// index is the rule's position in its section before sorting.
+	index int
+}
+
+// sortCodeowners reorders the rules in a CODEOWNERS file, parsed with the
+// given settings, from least to most specific, so that narrower rules take
+// precedence over broader ones. Rules are only reordered within their section,
+// and rules that are equally specific keep their relative order. Comments move with the rule that
+// follows them, while anything before the first rule of a section, such as
+// the section header, stays where it is.
+//
+// Moving a rule past another that overlaps with it changes the owners of the
+// paths they both match, which means the original order had a rule shadowing
+// another. A warning is returned for each such pair that's found.
+func sortCodeowners(contents []byte, settings parseSettings) ([]byte, []finding, error) {
+	// A byte order mark stays at the start of the file, rather than moving
+	// with the first line
+	var buf bytes.Buffer
+	if bytes.HasPrefix(contents, []byte(byteOrderMark)) {
+		contents = contents[len(byteOrderMark):]
+		buf.WriteString(byteOrderMark)
+	}
+	ruleset, err := parseForRewriting(contents, settings, codeowners.WithComments())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		warnings []finding
+		// section holds the rules of the current section, and pending the
+		// lines since its last rule
+		section []sortedRule
+		pending []string
+	)
+	flush := func() {
+		warnings = append(warnings, writeSortedRules(&buf, section)...)
+		// Comments at the end of a section stay there
+		for _, line := range pending {
+			buf.WriteString(line + "\n")
+		}
+		section, pending = nil, nil
+	}
+	// Lines that aren't rules are comments, blank lines and section headers
+	addLine := func(line string) {
+		line = trimTrailingSpace(line)
+		if trimmed := strings.TrimSpace(line); trimmed != "" && trimmed[0] != '#' {
+			flush()
+			buf.WriteString(line + "\n")
+			return
+		}
+		pending = append(pending, line)
+	}
+
+	for _, rule := range ruleset {
+		for _, line := range rule.Leading {
+			addLine(line)
+		}
+		var comments []string
+		for _, l := range pending {
+			if strings.TrimSpace(l) != "" {
+				comments = append(comments, l)
+			}
+		}
+		if len(section) == 0 {
+			// Lines before the first rule, such as a header comment, stay at
+			// the top
+			for _, l := range pending {
+				buf.WriteString(l + "\n")
+			}
+			comments = nil
+		}
+		section = append(section, sortedRule{rule: rule, comments: comments, text: ruleLine(rule), index: len(section)})
+		pending = nil
+	}
+	// The lines after the last rule are kept along with it, unless there
+	// aren't any rules
+	trailing := fileLines(contents)
+	if len(ruleset) > 0 {
+		trailing = ruleset[len(ruleset)-1].Trailing
+	}
+	for _, line := range trailing {
+		addLine(line)
+	}
+	flush()
+	return buf.Bytes(), warnings, nil
+}
+
+// ruleLine returns the line a rule parsed with codeowners.WithComments was
+// written as, without its line ending or unescaped trailing whitespace.
+func ruleLine(rule codeowners.Rule) string {
+	rule.Leading, rule.Trailing = nil, nil
+	return trimTrailingSpace(strings.TrimSuffix(codeowners.Ruleset{rule}.String(), "\n"))
+}
+
+// trimTrailingSpace trims the whitespace at the end of a line, other than
+// whitespace escaped by a backslash, which is part of a rule's pattern, as in
+// docs/notes\ .
+func trimTrailingSpace(line string) string {
+	trimmed := strings.TrimRight(line, " \t\r")
+	if len(trimmed) < len(line) && strings.HasSuffix(trimmed, `\`) {
+		// Only an odd number of backslashes escapes what follows
+		if n := len(trimmed) - len(strings.TrimRight(trimmed, `\`)); n%2 == 1 {
+			trimmed = line[:len(trimmed)+1]
+		}
+	}
+	return trimmed
+}
+
+// writeSortedRules sorts a section's rules by specificity and writes them,
+// returning warnings for overlapping rules whose order was swapped.
+func writeSortedRules(w *bytes.Buffer, section []sortedRule) []finding {
+	sorted := make([]sortedRule, len(section))
+	copy(sorted, section)
+	keys := make([]specificity, len(section))
+	for i, r := range section {
+		keys[i] = newSpecificity(r.rule.RawPattern())
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return keys[sorted[i].index].less(keys[sorted[j].index])
+	})
+
+	var warnings []finding
+	position := make([]int, len(section))
+	for i, r := range sorted {
+		position[r.index] = i
+	}
+	for i := range section {
+		for j := i + 1; j < len(section); j++ {
+			if position[j] > position[i] {
+				continue
+			}
+			if p, ok := overlap(section[i].rule, section[j].rule); ok {
+				warnings = append(warnings, finding{
+					Line:    section[i].rule.LineNumber,
+					Message: fmt.Sprintf("moving this rule after line %d changes the owners of paths such as %s", section[j].rule.LineNumber, p),
+				})
+			}
+		}
+	}
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].Line < warnings[j].Line })
+
+	for i, r := range sorted {
+		if len(r.comments) > 0 && i > 0 {
+			// Keep commented rules visually separate from the one before
+			w.WriteString("\n")
+		}
+		for _, c := range r.comments {
+			w.WriteString(c + "\n")
+		}
+		w.WriteString(r.text + "\n")
+	}
+	return warnings
+}
+
+// specificity describes how specific a pattern is, for ordering rules.
+type specificity struct {
+	// class is 0 for patterns that match everything, 1 for patterns that
+	// match at any depth, and 2 for patterns anchored to the root.
+	class int
+	// depth is the number of path segments in the pattern.
+	depth int
+	// literals counts the segments without wildcards.
+	literals int
+}
+
+func newSpecificity(pattern string) specificity {
+	if pattern == "*" || pattern == "**" {
+		return specificity{}
+	}
+	s := specificity{class: 2}
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(pattern, "**"), "/")
+	switch {
+	case strings.HasPrefix(trimmed, "/"):
+		trimmed = trimmed[1:]
+	case strings.HasPrefix(trimmed, "**/"):
+		s.class = 1
+		trimmed = trimmed[3:]
+	case !strings.Contains(trimmed, "/"):
+		s.class = 1
+	}
+	for _, seg := range strings.Split(trimmed, "/") {
+		if seg == "" {
+			continue
+		}
+		s.depth++
+		if !strings.ContainsAny(seg, "*?[") {
+			s.literals++
+		}
+	}
+	return s
+}
+
+func (s specificity) less(other specificity) bool {
+	if s.class != other.class {
+		return s.class < other.class
+	}
+	if s.depth != other.depth {
+		return s.depth < other.depth
+	}
+	return s.literals < other.literals
+}
+
+// overlap looks for a path matched by both rules, returning it if one is
+// found. It tries paths built from each pattern, so it can miss overlaps, but
+// any path it returns really is matched by both.
+func overlap(a, b codeowners.Rule) (string, bool) {
+	ea, eb := examplePath(a.RawPattern()), examplePath(b.RawPattern())
+	candidates := []string{
+		ea,
+		eb,
+		path.Join(ea, path.Base(eb)),
+		path.Join(eb, path.Base(ea)),
+	}
+	for _, p := range candidates {
+		matchA, errA := a.Match(p)
+		matchB, errB := b.Match(p)
+		if errA == nil && errB == nil && matchA && matchB {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// examplePath returns a path matched by a pattern, with its wildcards filled
+// in. Patterns that only match the contents of a directory have a file added.
+func examplePath(pattern string) string {
+	var b strings.Builder
+	escaped := false
+	for _, ch := range strings.TrimPrefix(pattern, "/") {
+		switch {
+		case escaped:
+			b.WriteRune(ch)
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == '*' || ch == '?':
+			b.WriteRune('x')
+		default:
+			b.WriteRune(ch)
+		}
+	}
+	p := strings.ReplaceAll(b.String(), "xx", "x")
+	if strings.HasSuffix(p, "/") {
+		p += "x"
+	}
+	return p
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortCodeowners(t *testing.T) {
+	contents := strings.Join([]string{
+		"# Header",
+		"",
+		"/src/api/ @api",
+		"# Go code",
+		"*.go @go",
+		"/src/*.go @go",
+		"/src/main.go @main",
+		"* @everyone",
+		"[Docs]",
+		"/docs/guides/ @guides",
+		"/docs/ @docs",
+		"",
+	}, "\n")
+
+	sorted, warnings, err := sortCodeowners([]byte(contents), parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"# Header",
+		"",
+		"* @everyone",
+		"",
+		"# Go code",
+		"*.go @go",
+		"/src/*.go @go",
+		"/src/api/ @api",
+		"/src/main.go @main",
+		"[Docs]",
+		"/docs/ @docs",
+		"/docs/guides/ @guides",
+		"",
+	}, "\n"), string(sorted))
+	assert.Equal(t, []finding{
+		{Line: 3, Message: "moving this rule after line 5 changes the owners of paths such as src/api/x/x.go"},
+		{Line: 3, Message: "moving this rule after line 8 changes the owners of paths such as src/api/x"},
+		{Line: 5, Message: "moving this rule after line 8 changes the owners of paths such as x.go"},
+		{Line: 6, Message: "moving this rule after line 8 changes the owners of paths such as src/x.go"},
+		{Line: 7, Message: "moving this rule after line 8 changes the owners of paths such as src/main.go"},
+		{Line: 10, Message: "moving this rule after line 11 changes the owners of paths such as docs/guides/x"},
+	}, warnings)
+
+	// Sorting is idempotent, and sorted files don't produce warnings
+	again, warnings, err := sortCodeowners(sorted, parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Equal(t, string(sorted), string(again))
+	assert.Empty(t, warnings)
+
+	// A byte order mark stays at the start of the file
+	sorted, _, err = sortCodeowners([]byte("\ufeff/src/ @src\r\n* @everyone\r\n"), parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Equal(t, "\ufeff* @everyone\n/src/ @src\n", string(sorted))
+
+	// Escaped trailing whitespace is part of a rule's pattern, so it's kept
+	sorted, _, err = sortCodeowners([]byte("/docs/foo\\ \n/docs/ @docs  \n# Notes \t\n"), parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Equal(t, "/docs/ @docs\n/docs/foo\\ \n# Notes\n", string(sorted))
+	sorted, _, err = sortCodeowners([]byte("# No rules yet  \n\n"), parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Equal(t, "# No rules yet\n\n", string(sorted))
+
+	// Negated rules are sorted along with the rest, with --negation
+	sorted, _, err = sortCodeowners([]byte("/docs/internal/ @internal\n!/docs/internal/\n* @everyone\n"), parseSettings{dialect: codeowners.DialectGitHub, negation: true})
+	require.NoError(t, err)
+	assert.Equal(t, "* @everyone\n/docs/internal/ @internal\n!/docs/internal/\n", string(sorted))
+}
+
+func TestSpecificity(t *testing.T) {
+	inOrder := []string{"*", "*.go", "node_modules/", "**/docs/*.md", "/src/", "/*/api/", "/src/api/**", "/src/*/main.go", "/src/api/main.go"}
+	for i := 0; i+1 < len(inOrder); i++ {
+		a, b := newSpecificity(inOrder[i]), newSpecificity(inOrder[i+1])
+		assert.False(t, b.less(a), "%s should sort before %s", inOrder[i], inOrder[i+1])
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/hmarr/codeowners"
+	"github.com/mattn/go-runewidth"
+	flag "github.com/spf13/pflag"
+)
+
+// unownedRow is the name of the stats row that counts files without owners.
+const unownedRow = "(unowned)"
+
+// runStats runs the stats command, which summarises how many files each owner
+// is responsible for.
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	var (
+		rulesetFlags rulesetFlags
+		walkFlags    walkFlags
+		format       string
+	)
+	rulesetFlags.register(fs)
+	walkFlags.register(fs)
+	fs.StringVar(&format, "format", "text", "output format (text, json)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners stats [flags] [<path>...]\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if err := walkFlags.checkConflicts(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fs.Usage()
+		return 2
+	}
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "error: unknown format %q\n", format)
+		return 1
+	}
+
+	walkOpts, err := walkFlags.options()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	ruleset, err := rulesetFlags.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	walkOpts.useSettings(rulesetFlags.settings())
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	stats := newOwnerStats()
+	compiled, err := ruleset.Compile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	err = matchPaths(
+		func(send func(string) error) error {
+			return walkPaths(paths, walkOpts, send)
+		},
+		func(path string) (*result, error) {
+			rule, err := walkOpts.match(compiled, walkOpts.repo.relativePath(path))
+			if err != nil {
+				return nil, err
+			}
+			stats.record(rule)
+			return nil, nil
+		},
+		func(res result) error { return nil },
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if format == "json" {
+		err = stats.writeJSON(out)
+	} else {
+		err = stats.write(out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// ownerStat is the stats command's summary for a single owner.
+type ownerStat struct {
+	Owner string `json:"owner"`
+	// Files counts the files the owner owns, alone or with other owners.
+	Files int `json:"files"`
+	// Exclusive counts the files the owner is the sole owner of.
+	Exclusive int `json:"exclusive"`
+	// Share is the percentage of all files that the owner owns.
+	Share float64 `json:"share"`
+}
+
+// ownerStats counts the files each owner owns. It's safe for concurrent use.
+type ownerStats struct {
+	mu     sync.Mutex
+	total  int
+	owners map[string]*ownerStat
+}
+
+func newOwnerStats() *ownerStats {
+	return &ownerStats{owners: make(map[string]*ownerStat)}
+}
+
+// record counts a file, given the rule it matched, which may be nil. Files
+// without owners are counted under the unowned row.
+func (s *ownerStats) record(rule *codeowners.Rule) {
+	var owners []string
+	seen := make(map[string]bool)
+	if rule != nil {
+		for _, o := range rule.Owners {
+			// An owner listed twice on the same rule still only owns the file
+			// once
+			if owner := o.String(); !seen[owner] {
+				seen[owner] = true
+				owners = append(owners, owner)
+			}
+		}
+	}
+	if len(owners) == 0 {
+		owners = []string{unownedRow}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total++
+	for _, owner := range owners {
+		stat := s.owners[owner]
+		if stat == nil {
+			stat = &ownerStat{Owner: owner}
+			s.owners[owner] = stat
+		}
+		stat.Files++
+		if len(owners) == 1 {
+			stat.Exclusive++
+		}
+	}
+}
+
+// summary returns a row for each owner, ordered by the number of files they
+// own, with the unowned row last.
+func (s *ownerStats) summary() []ownerStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := make([]ownerStat, 0, len(s.owners))
+	for _, stat := range s.owners {
+		row := *stat
+		row.Share = float64(row.Files) / float64(s.total) * 100
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if (a.Owner == unownedRow) != (b.Owner == unownedRow) {
+			return b.Owner == unownedRow
+		}
+		if a.Files != b.Files {
+			return a.Files > b.Files
+		}
+		return a.Owner < b.Owner
+	})
+	return rows
+}
+
+func (s *ownerStats) write(w io.Writer) error {
+	rows := s.summary()
+	width := runewidth.StringWidth("OWNER")
+	for _, row := range rows {
+		if n := runewidth.StringWidth(row.Owner); n > width {
+			width = n
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "%s  %6s  %9s  %6s\n", runewidth.FillRight("OWNER", width), "FILES", "EXCLUSIVE", "SHARE"); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		padded := runewidth.FillRight(row.Owner, width)
+		if _, err := fmt.Fprintf(w, "%s  %6d  %9d  %5.1f%%\n", padded, row.Files, row.Exclusive, row.Share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ownerStats) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s.summary())
+}
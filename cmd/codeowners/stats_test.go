@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnerStats(t *testing.T) {
+	ruleset, err := codeowners.ParseFile(strings.NewReader(strings.Join([]string{
+		"/src/ @team @alice @team",
+		"/src/api/ @team",
+		"/docs/",
+	}, "\n")))
+	require.NoError(t, err)
+
+	stats := newOwnerStats()
+	for _, path := range []string{"src/main.go", "src/util.go", "src/api/api.go", "docs/index.md"} {
+		rule, err := ruleset.Match(path)
+		require.NoError(t, err)
+		stats.record(rule)
+	}
+	stats.record(nil)
+
+	assert.Equal(t, []ownerStat{
+		{Owner: "@team", Files: 3, Exclusive: 1, Share: 60},
+		{Owner: "@alice", Files: 2, Exclusive: 0, Share: 40},
+		{Owner: unownedRow, Files: 2, Exclusive: 2, Share: 40},
+	}, stats.summary())
+}
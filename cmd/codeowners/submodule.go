@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hmarr/codeowners"
+)
+
+// submodules identifies the submodules of a repository, whose files belong to
+// another repository with a CODEOWNERS file of its own.
+type submodules struct {
+	repo repository
+	// paths holds the paths of the submodules listed in .gitmodules,
+	// relative to the root of repo. Submodules that haven't been initialized
+	// are only known from here, as they're empty directories.
+	paths map[string]bool
+}
+
+// findSubmodules reads the submodules listed in the .gitmodules file at the
+// root of the repository, if there is one.
+func findSubmodules(repo repository) (*submodules, error) {
+	s := &submodules{repo: repo, paths: make(map[string]bool)}
+	if repo.root == "" {
+		return s, nil
+	}
+	f, err := os.Open(filepath.Join(repo.root, ".gitmodules"))
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// .gitmodules uses git's config format, but the path of each submodule
+	// is all that's needed from it
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.TrimSpace(key) != "path" {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if value != "" {
+			s.paths[path.Clean(value)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf(".gitmodules: %w", err)
+	}
+	return s, nil
+}
+
+// contains reports whether a directory, relative to the root of the
+// repository, is a submodule. Directories with a .git file (or directory)
+// inside them count, even if they aren't listed in .gitmodules, as they're
+// repositories of their own.
+func (s *submodules) contains(dir string) bool {
+	if s.repo.root == "" || dir == "." || path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+		return false
+	}
+	if s.paths[dir] {
+		return true
+	}
+	_, err := os.Lstat(filepath.Join(s.repo.root, filepath.FromSlash(dir), ".git"))
+	return err == nil
+}
+
+// submoduleRulesets matches files against the CODEOWNERS file of the
+// submodule containing them (--submodules=recurse). It's safe for concurrent
+// use.
+type submoduleRulesets struct {
+	submodules *submodules
+	// settings are the settings the CODEOWNERS files are parsed with, the
+	// same as the repository's own.
+	settings parseSettings
+
+	mu sync.Mutex
+	// rulesets caches the ruleset of each directory that's been checked,
+	// keyed by its path relative to the root of the repository, or nil if
+	// it isn't a submodule.
+	rulesets map[string]codeowners.Ruleset
+	// checked records which directories have been checked.
+	checked map[string]bool
+}
+
+func newSubmoduleRulesets(s *submodules) *submoduleRulesets {
+	return &submoduleRulesets{
+		submodules: s,
+		settings:   parseSettings{dialect: codeowners.DialectGitHub},
+		rulesets:   make(map[string]codeowners.Ruleset),
+		checked:    make(map[string]bool),
+	}
+}
+
+// matchSections returns the rule matching a path relative to the root of the
+// repository in each section. Files in a submodule are matched against the
+// submodule's CODEOWNERS file, relative to its root, rather than against
+// ruleset. For nested submodules, the innermost one wins. The last of the
+// rules in their ruleset is returned too, as matchSections does.
+func (r *submoduleRulesets) matchSections(ruleset sectionMatcher, repoPath string) ([]*codeowners.Rule, *codeowners.Rule, error) {
+	for dir := path.Dir(repoPath); dir != "." && dir != "/" && dir != ".."; dir = path.Dir(dir) {
+		sub, ok, err := r.ruleset(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			return matchSections(sub, repoPath[len(dir)+1:])
+		}
+	}
+	return matchSections(ruleset, repoPath)
+}
+
+// ruleset returns the ruleset of the submodule at dir, and whether dir is a
+// submodule. Submodules without a CODEOWNERS file have an empty ruleset, so
+// their files are unowned.
+func (r *submoduleRulesets) ruleset(dir string) (codeowners.Ruleset, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.checked[dir] {
+		ruleset, ok := r.rulesets[dir]
+		return ruleset, ok, nil
+	}
+
+	if !r.submodules.contains(dir) {
+		r.checked[dir] = true
+		return nil, false, nil
+	}
+	ruleset := codeowners.Ruleset{}
+	for _, loc := range r.settings.dialect.StandardLocations() {
+		p := filepath.Join(r.submodules.repo.root, filepath.FromSlash(dir), filepath.FromSlash(loc))
+		if !fileExists(p) {
+			continue
+		}
+		var err error
+		ruleset, err = r.settings.load(p)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", path.Join(dir, loc), err)
+		}
+		break
+	}
+	r.checked[dir] = true
+	r.rulesets[dir] = ruleset
+	return ruleset, true, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSubmodules(t *testing.T) {
+	root := t.TempDir()
+	gitmodules := `[submodule "lib"]
+	path = vendor/lib
+	url = https://example.com/lib.git
+[submodule "quoted"]
+	path = "third_party/quoted/"
+	url = https://example.com/quoted.git
+`
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".gitmodules"), []byte(gitmodules), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "nested", ".git"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "src"), 0o755))
+
+	s, err := findSubmodules(repository{root: root})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"vendor/lib": true, "third_party/quoted": true}, s.paths)
+	assert.True(t, s.contains("vendor/lib"))
+	assert.True(t, s.contains("nested"))
+	assert.False(t, s.contains("src"))
+	assert.False(t, s.contains("vendor"))
+	assert.False(t, s.contains("."))
+	assert.False(t, s.contains("../elsewhere"))
+
+	s, err = findSubmodules(repository{})
+	require.NoError(t, err)
+	assert.False(t, s.contains("vendor/lib"))
+}
+
+func TestWalkSubmodules(t *testing.T) {
+	sub := gitRepository(t, map[string]string{
+		"CODEOWNERS": "* @sub\n",
+		"lib.go":     "",
+	})
+	super := gitRepository(t, map[string]string{
+		"CODEOWNERS": "* @super\n",
+		"main.go":    "",
+	})
+	runGit(t, "-c", "protocol.file.allow=always", "submodule", "add", "-q", sub, "vendor/lib")
+	runGit(t, "commit", "-q", "-m", "add submodule")
+	require.NoError(t, os.Chdir(super))
+	ruleset, err := loadCodeowners(nil, parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+
+	// walk returns the owner of each file found with --submodules=mode
+	walk := func(f walkFlags) map[string]string {
+		f.maxDepth = -1
+		opts, err := f.options()
+		require.NoError(t, err)
+		owners := make(map[string]string)
+		err = walkPaths([]string{"."}, opts, func(path string) error {
+			rule, err := opts.match(ruleset, opts.repo.relativePath(path))
+			require.NoError(t, err)
+			owners[filepath.ToSlash(path)] = rule.Owners[0].String()
+			return nil
+		})
+		require.NoError(t, err)
+		return owners
+	}
+
+	files := map[string]string{
+		".gitmodules": "@super",
+		"CODEOWNERS":  "@super",
+		"main.go":     "@super",
+	}
+	assert.Equal(t, files, walk(walkFlags{submodules: "skip"}))
+	assert.Equal(t, files, walk(walkFlags{submodules: "skip", tracked: "true"}))
+
+	files["vendor/lib"] = "@super"
+	assert.Equal(t, files, walk(walkFlags{submodules: "include"}))
+	assert.Equal(t, files, walk(walkFlags{submodules: "include", tracked: "true"}))
+	assert.Equal(t, files, walk(walkFlags{submodules: "include", sparse: "index"}))
+
+	// The .git file pointing at the submodule's repository isn't reported
+	delete(files, "vendor/lib")
+	files["vendor/lib/CODEOWNERS"] = "@sub"
+	files["vendor/lib/lib.go"] = "@sub"
+	assert.Equal(t, files, walk(walkFlags{submodules: "recurse"}))
+
+	_, err = (&walkFlags{submodules: "everything"}).options()
+	assert.Error(t, err)
+	assert.Error(t, (&walkFlags{submodules: "recurse", tracked: "true"}).checkConflicts())
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	flag "github.com/spf13/pflag"
+)
+
+// runSuggest runs the suggest command, which suggests owners for a path based
+// on who has committed to it.
+func runSuggest(args []string) int {
+	fs := flag.NewFlagSet("suggest", flag.ContinueOnError)
+	var (
+		since      string
+		maxCommits int
+		top        int
+		mapPath    string
+	)
+	fs.StringVar(&since, "since", "", "only consider commits more recent than this date (e.g. 1.year)")
+	fs.IntVar(&maxCommits, "max-commits", 1000, "maximum number of recent commits to consider")
+	fs.IntVarP(&top, "top", "n", 3, "number of candidates to suggest")
+	fs.StringVar(&mapPath, "map", "", "file mapping commit author emails to owners, one \"<email> <owner>\" pair per line")
+	registerGitFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners suggest [flags] <path>\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "error: expected a single path")
+		fs.Usage()
+		return 2
+	}
+	if maxCommits <= 0 {
+		fmt.Fprintf(os.Stderr, "error: invalid --max-commits %d\n", maxCommits)
+		return 1
+	}
+	if top <= 0 {
+		fmt.Fprintf(os.Stderr, "error: invalid --top %d\n", top)
+		return 1
+	}
+
+	authorMap, err := readAuthorMap(mapPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	target := fs.Arg(0)
+	info, err := os.Stat(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	emails, err := commitAuthors(target, since, maxCommits)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if len(emails) == 0 {
+		fmt.Fprintf(os.Stderr, "error: no commits found for %s\n", target)
+		return 1
+	}
+
+	candidates := rankAuthors(emails, authorMap)
+	if len(candidates) > top {
+		candidates = candidates[:top]
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	writeCandidates(w, candidates, len(emails))
+
+	owners := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		owners = append(owners, c.owner)
+	}
+	fmt.Fprintf(w, "\n%s %s\n", suggestedPattern(target, info.IsDir()), strings.Join(owners, " "))
+	return 0
+}
+
+// commitAuthors returns the author email of each of the most recent commits
+// that touched a path, optionally limited to commits since a date.
+func commitAuthors(path, since string, maxCommits int) ([]string, error) {
+	gitArgs := []string{"log", "--format=%ae", fmt.Sprintf("--max-count=%d", maxCommits)}
+	if since != "" {
+		gitArgs = append(gitArgs, "--since="+since)
+	}
+	out, err := gitOutput(append(gitArgs, "--", path)...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// suggestedPattern returns a pattern for a rule matching the path, anchored
+// at its location in the repository.
+func suggestedPattern(p string, isDir bool) string {
+	rel := findRepository().relativePath(p)
+	if rel == "." || rel == "" {
+		return "*"
+	}
+	pattern := "/" + rel
+	if isDir {
+		pattern += "/"
+	}
+	return pattern
+}
+
+// candidate is an owner suggested by the suggest command, along with the
+// number of commits they authored.
+type candidate struct {
+	owner   string
+	commits int
+}
+
+// rankAuthors counts the commits by each author, given the author email of
+// each commit, ordered by commit count. Emails in the author map, which must
+// be lowercase, are replaced by the owner they map to, which also combines
+// authors who commit with several addresses.
+func rankAuthors(emails []string, authorMap map[string]string) []candidate {
+	counts := make(map[string]int)
+	for _, email := range emails {
+		// Email addresses aren't case-sensitive in practice
+		email = strings.ToLower(email)
+		owner, ok := authorMap[email]
+		if !ok {
+			owner = email
+		}
+		counts[owner]++
+	}
+
+	candidates := make([]candidate, 0, len(counts))
+	for owner, commits := range counts {
+		candidates = append(candidates, candidate{owner: owner, commits: commits})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].commits != candidates[j].commits {
+			return candidates[i].commits > candidates[j].commits
+		}
+		return candidates[i].owner < candidates[j].owner
+	})
+	return candidates
+}
+
+func writeCandidates(w io.Writer, candidates []candidate, total int) {
+	width := 0
+	for _, c := range candidates {
+		if n := runewidth.StringWidth(c.owner); n > width {
+			width = n
+		}
+	}
+	for _, c := range candidates {
+		share := float64(c.commits) / float64(total) * 100
+		noun := "commits"
+		if c.commits == 1 {
+			noun = "commit"
+		}
+		fmt.Fprintf(w, "%s  %d %s  %.1f%%\n", runewidth.FillRight(c.owner, width), c.commits, noun, share)
+	}
+}
+
+// readAuthorMap reads the author map file at path, as passed with --map. An
+// empty path gives an empty map.
+func readAuthorMap(path string) (map[string]string, error) {
+	if path == "" {
+		return map[string]string{}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	authorMap, err := parseAuthorMap(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return authorMap, nil
+}
+
+// parseAuthorMap parses a file mapping commit author emails to owners. Each
+// line holds an email address and the owner it maps to, separated by
+// whitespace. Blank lines and lines starting with # are ignored. The emails
+// are lowercased.
+func parseAuthorMap(r io.Reader) (map[string]string, error) {
+	authorMap := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected an email address and an owner", lineNo)
+		}
+		authorMap[strings.ToLower(fields[0])] = fields[1]
+	}
+	return authorMap, scanner.Err()
+}
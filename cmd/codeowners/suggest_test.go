@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankAuthors(t *testing.T) {
+	authorMap, err := parseAuthorMap(strings.NewReader(strings.Join([]string{
+		"# Alice commits from two addresses",
+		"alice@example.com @alice",
+		"Alice@Personal.example  @alice",
+		"",
+	}, "\n")))
+	require.NoError(t, err)
+
+	emails := []string{
+		"bob@example.com",
+		"alice@example.com",
+		"Bob@example.com",
+		"alice@personal.example",
+		"carol@example.com",
+		"alice@example.com",
+	}
+	assert.Equal(t, []candidate{
+		{owner: "@alice", commits: 3},
+		{owner: "bob@example.com", commits: 2},
+		{owner: "carol@example.com", commits: 1},
+	}, rankAuthors(emails, authorMap))
+}
+
+func TestParseAuthorMapInvalid(t *testing.T) {
+	_, err := parseAuthorMap(strings.NewReader("alice@example.com @alice\nbob@example.com\n"))
+	assert.EqualError(t, err, "line 2: expected an email address and an owner")
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+)
+
+// finding is a problem found in a CODEOWNERS file, such as by the verify
+// command.
+type finding struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	// Column is the position of the problem in the line, starting at 1, or
+	// 0 if it applies to the whole line.
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+	// Code and Severity are set for findings from verify, with the code and
+	// severity of the codeowners.ValidationIssue, or parseErrorCode and
+	// error for lines that can't be parsed.
+	Code     string `json:"code,omitempty"`
+	Severity string `json:"severity,omitempty"`
+}
+
+// parseErrorCode is the code of verify's findings for lines that can't be
+// parsed.
+const parseErrorCode = "parse-error"
+
+// String formats the finding as compilers do, so that editors can jump to it.
+// Warnings are marked as such.
+func (f finding) String() string {
+	msg := f.Message
+	if f.Severity == string(codeowners.SeverityWarning) {
+		msg = "warning: " + msg
+	}
+	if f.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", f.Path, f.Line, f.Column, msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", f.Path, f.Line, msg)
+}
+
+// runVerify runs the verify command, which checks a CODEOWNERS file for
+// problems, exiting with a non-zero status if any are found.
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	var path, format, dialect string
+	var negation, strictOwners, braceExpansion bool
+	var policyFlags ownerPolicyFlags
+	fs.StringVarP(&path, "file", "f", "", "CODEOWNERS file path (- for stdin)")
+	fs.StringVar(&format, "format", "text", "output format (text, json)")
+	fs.StringVar(&dialect, "dialect", string(codeowners.DialectGitHub), "CODEOWNERS dialect ("+strings.Join(dialectNames(), ", ")+")")
+	fs.BoolVar(&negation, "negation", false, "allow rules starting with ! to exclude paths from the rules before them")
+	fs.BoolVar(&strictOwners, "strict-owners", true, "report owners that aren't a valid user, team or email (--strict-owners=false ignores them, as GitHub does)")
+	fs.BoolVar(&braceExpansion, "brace-expansion", false, "expand braces in patterns, so *.{js,ts} matches *.js and *.ts")
+	policyFlags.register(fs)
+	registerGitFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners verify [flags]\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "error: unknown format %q\n", format)
+		return 1
+	}
+	d, err := codeowners.ParseDialect(dialect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	policy, err := policyFlags.policy()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if path == "" {
+		path = d.FindFile()
+		if path == "" {
+			fmt.Fprintln(os.Stderr, "error: could not find CODEOWNERS file at any of the standard locations")
+			return 1
+		}
+	}
+	var r io.Reader = os.Stdin
+	if path == "-" {
+		path = "<stdin>"
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+
+	findings, err := verifyCodeowners(path, r, parseSettings{dialect: d, negation: negation, lenientOwners: !strictOwners, braceExpansion: braceExpansion, ownerPolicy: policy})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if format == "json" {
+		if findings == nil {
+			findings = []finding{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(findings)
+	} else {
+		for _, f := range findings {
+			if _, err = fmt.Fprintln(out, f); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if len(findings) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// verifyCodeowners checks a CODEOWNERS file, returning every problem found
+// rather than stopping at the first, in the order of their lines. As well as
+// the lines that can't be parsed, the problems Ruleset.Validate finds are
+// reported.
+func verifyCodeowners(path string, r io.Reader, settings parseSettings) ([]finding, error) {
+	settings.allErrors = true
+	ruleset, err := settings.parse(r)
+	var parseErrs codeowners.ParseErrors
+	if err != nil && !errors.As(err, &parseErrs) {
+		return nil, err
+	}
+
+	var findings []finding
+	for _, e := range parseErrs {
+		findings = append(findings, finding{Path: path, Line: e.Line, Column: e.Column, Message: e.Message, Code: parseErrorCode, Severity: string(codeowners.SeverityError)})
+	}
+	for _, issue := range ruleset.Validate() {
+		findings = append(findings, finding{Path: path, Line: issue.Line, Message: issue.Message, Code: string(issue.Code), Severity: string(issue.Severity)})
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+	return findings, nil
+}
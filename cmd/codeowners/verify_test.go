@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCodeowners(t *testing.T) {
+	contents := strings.Join([]string{
+		"# Comment",
+		"*.go @org/team",
+		"",
+		"bad owner",
+		"[Docs]",
+		"*.md",
+		"docs/ @user !",
+		"a***b @user",
+		"*.txt user@example.com",
+	}, "\n")
+	findings, err := verifyCodeowners("CODEOWNERS", strings.NewReader(contents), parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Equal(t, []finding{
+		{Path: "CODEOWNERS", Line: 4, Column: 5, Message: "invalid owner format 'owner'", Code: parseErrorCode, Severity: "error"},
+		{Path: "CODEOWNERS", Line: 6, Message: "rule has no owners", Code: "no-owners", Severity: "warning"},
+		{Path: "CODEOWNERS", Line: 7, Column: 13, Message: "unexpected character '!'", Code: parseErrorCode, Severity: "error"},
+		{Path: "CODEOWNERS", Line: 8, Message: "pattern cannot contain three consecutive asterisks", Code: parseErrorCode, Severity: "error"},
+	}, findings)
+
+	assert.Equal(t, "CODEOWNERS:4:5: invalid owner format 'owner'", findings[0].String())
+	assert.Equal(t, "CODEOWNERS:6: warning: rule has no owners", findings[1].String())
+
+	// Columns count the indentation
+	findings, err = verifyCodeowners("CODEOWNERS", strings.NewReader("  *.go bad\n"), parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Equal(t, []finding{{Path: "CODEOWNERS", Line: 1, Column: 8, Message: "invalid owner format 'bad'", Code: parseErrorCode, Severity: "error"}}, findings)
+
+	findings, err = verifyCodeowners("CODEOWNERS", strings.NewReader("*.go @org/team\n"), parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	// Sections can't require more approvals than a rule has owners
+	findings, err = verifyCodeowners("CODEOWNERS", strings.NewReader("[Docs][2] @org/docs @alice\n*.md\ndocs/ @bob\n"), parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Equal(t, []finding{{Path: "CODEOWNERS", Line: 3, Message: `section "Docs" requires 2 approvals, but the rule only has 1 owner`, Code: "too-few-owners", Severity: "error"}}, findings)
+
+	// Negated rules don't need owners
+	findings, err = verifyCodeowners("CODEOWNERS", strings.NewReader("* @org/team\n!docs/\n"), parseSettings{dialect: codeowners.DialectGitHub, negation: true})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	// Invalid owners are reported by default, and otherwise only when a rule
+	// is left without any valid owners
+	input := "*.go backend-team @org/go\n/api/ api-team\n"
+	findings, err = verifyCodeowners("CODEOWNERS", strings.NewReader(input), parseSettings{dialect: codeowners.DialectGitHub})
+	require.NoError(t, err)
+	assert.Equal(t, []finding{
+		{Path: "CODEOWNERS", Line: 1, Column: 6, Message: "invalid owner format 'backend-team'", Code: parseErrorCode, Severity: "error"},
+		{Path: "CODEOWNERS", Line: 2, Column: 7, Message: "invalid owner format 'api-team'", Code: parseErrorCode, Severity: "error"},
+	}, findings)
+	findings, err = verifyCodeowners("CODEOWNERS", strings.NewReader(input), parseSettings{dialect: codeowners.DialectGitHub, lenientOwners: true})
+	require.NoError(t, err)
+	assert.Equal(t, []finding{{Path: "CODEOWNERS", Line: 2, Message: "rule has no valid owners", Code: "no-owners", Severity: "warning"}}, findings)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	flag "github.com/spf13/pflag"
+)
+
+// version, commit and date describe the release, and are set at build time
+// via -ldflags "-X main.version=...". GoReleaser sets them by default.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// toolVersion returns the version of this build of the tool: the version set
+// at build time if there is one, otherwise the module version recorded by the
+// go command.
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// buildInfo describes how this build of the tool was made.
+type buildInfo struct {
+	version   string
+	commit    string
+	date      string
+	modified  bool
+	goVersion string
+	platform  string
+}
+
+// currentBuild returns the build information, preferring what was set at build
+// time, and falling back to what the go command recorded from version control,
+// in which case the date is that of the commit.
+func currentBuild() buildInfo {
+	b := buildInfo{
+		version:   toolVersion(),
+		commit:    commit,
+		date:      date,
+		goVersion: runtime.Version(),
+		platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if b.commit == "" {
+					b.commit = s.Value
+				}
+			case "vcs.time":
+				if b.date == "" {
+					b.date = s.Value
+				}
+			case "vcs.modified":
+				b.modified = s.Value == "true"
+			}
+		}
+	}
+	if b.commit == "" {
+		b.commit = "unknown"
+	}
+	if b.date == "" {
+		b.date = "unknown"
+	}
+	return b
+}
+
+// write writes the build information as a single line of key=value pairs, or
+// one field per line if verbose is set.
+func (b buildInfo) write(w io.Writer, verbose bool) error {
+	if !verbose {
+		_, err := fmt.Fprintf(w, "codeowners %s commit=%s date=%s go=%s\n", b.version, b.commit, b.date, b.goVersion)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "version:   %s\ncommit:    %s\nmodified:  %t\ndate:      %s\ngo:        %s\nplatform:  %s\n",
+		b.version, b.commit, b.modified, b.date, b.goVersion, b.platform)
+	return err
+}
+
+// runVersion runs the version command, which prints the build information.
+func runVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	var verbose bool
+	fs.BoolVarP(&verbose, "verbose", "v", false, "show each part of the build information on its own line")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: codeowners version [flags]\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseCommandFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "error: unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+	if err := currentBuild().write(os.Stdout, verbose); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
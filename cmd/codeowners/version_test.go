@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInfoWrite(t *testing.T) {
+	b := buildInfo{
+		version:   "v1.2.3",
+		commit:    "0123abc",
+		date:      "2024-05-01T12:00:00Z",
+		goVersion: "go1.22.3",
+		platform:  "linux/amd64",
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, b.write(&buf, false))
+	assert.Equal(t, "codeowners v1.2.3 commit=0123abc date=2024-05-01T12:00:00Z go=go1.22.3\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, b.write(&buf, true))
+	assert.Equal(t, "version:   v1.2.3\ncommit:    0123abc\nmodified:  false\ndate:      2024-05-01T12:00:00Z\ngo:        go1.22.3\nplatform:  linux/amd64\n", buf.String())
+}
@@ -0,0 +1,711 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hmarr/codeowners"
+	flag "github.com/spf13/pflag"
+	"golang.org/x/text/unicode/norm"
+)
+
+// errStopped is returned to path producers when matching has been abandoned
+// and no more paths are wanted.
+var errStopped = errors.New("stopped")
+
+// matchPaths matches every path produced by produce, passing the results to
+// emit in the order the paths were produced. produce is run in its own
+// goroutine and calls send for each path; matching, which is CPU-bound, is
+// spread across a pool of workers while it runs, so results are written as
+// soon as they're ready rather than once every path has been gathered.
+//
+// Results for which match returns nil are skipped. The first error returned by
+// produce, match, or emit stops the pipeline and is returned, after the results
+// that preceded it have been emitted.
+func matchPaths(
+	produce func(send func(path string) error) error,
+	match func(path string) (*result, error),
+	emit func(res result) error,
+) error {
+	type job struct {
+		seq  int
+		path string
+	}
+	type outcome struct {
+		seq int
+		res *result
+		err error
+	}
+
+	workers := runtime.NumCPU()
+	jobs := make(chan job, workers*4)
+	outcomes := make(chan outcome, workers*4)
+	stop := make(chan struct{})
+
+	var produceErr error
+	go func() {
+		defer close(jobs)
+		seq := 0
+		produceErr = produce(func(path string) error {
+			select {
+			case jobs <- job{seq: seq, path: path}:
+				seq++
+				return nil
+			case <-stop:
+				return errStopped
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res, err := match(j.path)
+				outcomes <- outcome{seq: j.seq, res: res, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// Outcomes arrive in whatever order the workers finish them, so hold on to
+	// any that arrive early until everything before them has been emitted.
+	var firstErr error
+	pending := make(map[int]outcome)
+	next := 0
+	for o := range outcomes {
+		if firstErr != nil {
+			// Keep draining so the workers and producer can exit
+			continue
+		}
+		pending[o.seq] = o
+		for {
+			o, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			err := o.err
+			if err == nil && o.res != nil {
+				err = emit(*o.res)
+			}
+			if err != nil {
+				firstErr = err
+				close(stop)
+				break
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	// The jobs channel is closed once produce returns, so by the time every
+	// outcome has been received produceErr is safe to read.
+	return produceErr
+}
+
+// walkOptions controls which files walkPaths reports.
+type walkOptions struct {
+	// repo is the repository containing the current directory, which walked
+	// paths need to be made relative to before they're matched.
+	repo repository
+	// onlyFiles, if non-nil, limits the walk to the files it contains, which
+	// are relative to the root of repo. It's used to only show files that
+	// are tracked, or untracked, by git.
+	onlyFiles map[string]bool
+	// fromIndex reports the files in onlyFiles beneath each start path,
+	// rather than walking the working tree, so that files outside a
+	// sparse-checkout cone are included even though they aren't there.
+	fromIndex bool
+	// ignore holds globs for files and directories to skip. They're matched
+	// against paths relative to the start path being walked.
+	ignore []glob
+	// gitignore, if non-nil, skips files and directories ignored by
+	// .gitignore files.
+	gitignore *gitignoreMatcher
+	// noCheck allows start paths that don't exist, which are matched as if
+	// they were files.
+	noCheck bool
+	// maxDepth limits how many levels of directories below each start path
+	// are descended into, if it's not negative. At depth 0, only the files
+	// directly inside the start path are reported.
+	maxDepth int
+	// skipHidden skips files and directories whose names begin with a dot,
+	// other than .github unless skipGitHub is also set.
+	skipHidden bool
+	skipGitHub bool
+	// followSymlinks descends into symlinked directories, rather than
+	// reporting the links as files.
+	followSymlinks bool
+	// pruner, if non-nil, reports directories whose files all share an owner
+	// as a single path with a trailing separator.
+	pruner *pruner
+	// submodules identifies the submodules of repo, which are skipped, or
+	// reported as a single path if includeSubmodules is set. If
+	// submoduleRulesets is set, they're walked like any other directory,
+	// and their files are matched against their own CODEOWNERS files.
+	submodules        *submodules
+	includeSubmodules bool
+	submoduleRulesets *submoduleRulesets
+}
+
+// sectionMatcher finds the rules matching a path in a ruleset. Commands that
+// walk the tree compile their ruleset, as they match lots of paths against
+// it.
+type sectionMatcher interface {
+	Match(path string) (*codeowners.Rule, error)
+	MatchSections(path string) ([]*codeowners.Rule, error)
+}
+
+// match returns the rule that decides the owners of a path relative to the
+// root of the repository, combining the rules it matched in each section.
+func (opts walkOptions) match(ruleset sectionMatcher, repoPath string) (*codeowners.Rule, error) {
+	rules, last, err := opts.matchSections(ruleset, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return combineSections(rules, last), nil
+}
+
+// useSettings parses the CODEOWNERS files of submodules with the given
+// settings, which should be those of the repository's own.
+func (opts walkOptions) useSettings(settings parseSettings) {
+	if opts.submoduleRulesets != nil {
+		opts.submoduleRulesets.settings = settings
+	}
+}
+
+// matchSections returns the rule matching a path relative to the root of the
+// repository in each section of the ruleset, using the CODEOWNERS file of the
+// submodule containing it with --submodules=recurse, along with the last of
+// them in the ruleset, as matchSections does.
+func (opts walkOptions) matchSections(ruleset sectionMatcher, repoPath string) ([]*codeowners.Rule, *codeowners.Rule, error) {
+	if opts.submoduleRulesets != nil {
+		return opts.submoduleRulesets.matchSections(ruleset, repoPath)
+	}
+	return matchSections(ruleset, repoPath)
+}
+
+// walkFlags holds the flags that control which files are walked, which are
+// shared by the commands that walk the tree.
+type walkFlags struct {
+	noCheck        bool
+	tracked        string
+	untrackedOnly  bool
+	ignoreGlobs    []string
+	maxDepth       int
+	skipHidden     string
+	followSymlinks bool
+	useGitignore   bool
+	sparse         string
+	submodules     string
+}
+
+func (f *walkFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.noCheck, "no-check", false, "match paths that don't exist, rather than reporting an error")
+	fs.StringVarP(&f.tracked, "tracked", "t", "", "only show files tracked by git (or with --tracked=with-untracked, files that are tracked or aren't ignored)")
+	fs.Lookup("tracked").NoOptDefVal = "true"
+	fs.BoolVar(&f.untrackedOnly, "untracked", false, "only show files that aren't tracked by git, and aren't ignored")
+	fs.StringArrayVar(&f.ignoreGlobs, "ignore", nil, "skip files and directories matching a glob while walking")
+	fs.IntVar(&f.maxDepth, "max-depth", -1, "only descend this many directory levels below each path (0 for just the files directly inside them)")
+	fs.StringVar(&f.skipHidden, "skip-hidden", "", "skip files and directories whose names begin with a dot, other than .github (or all of them with --skip-hidden=all)")
+	fs.Lookup("skip-hidden").NoOptDefVal = "true"
+	fs.BoolVar(&f.followSymlinks, "follow-symlinks", false, "descend into symlinked directories while walking")
+	fs.BoolVar(&f.useGitignore, "respect-gitignore", false, "skip files and directories ignored by .gitignore files while walking")
+	fs.StringVar(&f.sparse, "sparse", "worktree", "in a sparse checkout, check the files in the working tree, or every file in the index, including those outside the cone (worktree, index)")
+	fs.StringVar(&f.submodules, "submodules", "skip", "skip submodules, include each as a single path, or recurse into them and match their files against their own CODEOWNERS files (skip, include, recurse)")
+}
+
+// trackedOnly reports whether the walk is limited to files git knows about,
+// with --tracked.
+func (f *walkFlags) trackedOnly() bool {
+	return f.tracked != "" && f.tracked != "false"
+}
+
+// checkConflicts returns an error if flags that can't be combined were used
+// together.
+func (f *walkFlags) checkConflicts() error {
+	if f.trackedOnly() && f.untrackedOnly {
+		return errors.New("--tracked and --untracked can't be combined")
+	}
+	if f.sparse == "index" && f.untrackedOnly {
+		return errors.New("--sparse=index and --untracked can't be combined")
+	}
+	// Files in submodules aren't in the index, so they'd never be shown
+	if f.submodules == "recurse" && (f.trackedOnly() || f.untrackedOnly || f.sparse == "index") {
+		return errors.New("--submodules=recurse can't be combined with --tracked, --untracked or --sparse=index")
+	}
+	return nil
+}
+
+// options returns the walk options the flags describe.
+func (f *walkFlags) options() (walkOptions, error) {
+	opts := walkOptions{
+		repo:           findRepository(),
+		noCheck:        f.noCheck,
+		maxDepth:       f.maxDepth,
+		followSymlinks: f.followSymlinks,
+	}
+	for _, pattern := range f.ignoreGlobs {
+		g, err := compileGlob(pattern)
+		if err != nil {
+			return opts, fmt.Errorf("invalid --ignore glob %q: %w", pattern, err)
+		}
+		opts.ignore = append(opts.ignore, g)
+	}
+	switch f.skipHidden {
+	case "", "false":
+	case "true":
+		opts.skipHidden = true
+	case "all":
+		opts.skipHidden = true
+		opts.skipGitHub = true
+	default:
+		return opts, fmt.Errorf("invalid --skip-hidden %q", f.skipHidden)
+	}
+	if f.useGitignore {
+		opts.gitignore = newGitignoreMatcher()
+	}
+	var err error
+	if opts.submodules, err = findSubmodules(opts.repo); err != nil {
+		return opts, err
+	}
+	switch f.submodules {
+	case "", "skip":
+	case "include":
+		opts.includeSubmodules = true
+	case "recurse":
+		opts.submoduleRulesets = newSubmoduleRulesets(opts.submodules)
+	default:
+		return opts, fmt.Errorf("invalid --submodules %q", f.submodules)
+	}
+	switch f.tracked {
+	case "", "false", "true", "with-untracked":
+	default:
+		return opts, fmt.Errorf("invalid --tracked %q", f.tracked)
+	}
+	switch f.sparse {
+	case "", "worktree":
+		if f.trackedOnly() {
+			opts.onlyFiles, err = getTrackedFiles(opts.repo, false)
+		}
+	case "index":
+		opts.onlyFiles, err = getTrackedFiles(opts.repo, true)
+		opts.fromIndex = true
+	default:
+		return opts, fmt.Errorf("invalid --sparse %q", f.sparse)
+	}
+	if err != nil {
+		return opts, err
+	}
+	if f.untrackedOnly {
+		opts.onlyFiles, err = getUntrackedFiles(opts.repo)
+	}
+	// Files that have been added, but not committed, are still worth
+	// showing, unlike build output and other ignored files
+	if f.tracked == "with-untracked" {
+		var untracked map[string]bool
+		if untracked, err = getUntrackedFiles(opts.repo); err == nil {
+			for file := range untracked {
+				opts.onlyFiles[file] = true
+			}
+		}
+	}
+	if err == nil && opts.onlyFiles != nil {
+		opts.onlyFiles = nfcFiles(opts.onlyFiles)
+	}
+	return opts, err
+}
+
+// nfc returns the NFC form of a path. macOS stores file names in NFD, where
+// accented letters are decomposed into a letter and a combining mark, while
+// git records them in NFC, so paths from the walk and from git are both
+// converted to NFC before they're compared.
+func nfc(path string) string {
+	return norm.NFC.String(path)
+}
+
+// nfcFiles returns a set of files with each path converted to NFC.
+func nfcFiles(files map[string]bool) map[string]bool {
+	normalized := make(map[string]bool, len(files))
+	for file := range files {
+		normalized[nfc(file)] = true
+	}
+	return normalized
+}
+
+// walkPaths walks each of the start paths, calling send for every file found.
+// Start paths that aren't directories are passed to send as-is.
+func walkPaths(startPaths []string, opts walkOptions, send func(path string) error) error {
+	if opts.fromIndex {
+		return walkIndex(startPaths, opts, send)
+	}
+	var onlyDirs map[string]bool
+	if opts.onlyFiles != nil {
+		onlyDirs = parentDirs(opts.onlyFiles)
+	}
+
+	for _, startPath := range startPaths {
+		// WalkDir would report a lone file too, but it fails for paths that
+		// don't exist, so handle anything that isn't a directory separately
+		info, err := os.Stat(startPath)
+		if err != nil && !(os.IsNotExist(err) && opts.noCheck) {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("%s: no such file or directory (pass --no-check to match paths that don't exist)", startPath)
+			}
+			return err
+		}
+		if info == nil || !info.IsDir() {
+			if err := send(startPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		absStartPath, err := filepath.Abs(startPath)
+		if err != nil {
+			return err
+		}
+		if opts.gitignore != nil {
+			if err := opts.gitignore.addStartPath(startPath); err != nil {
+				return err
+			}
+		}
+
+		w := &walker{
+			opts:         opts,
+			send:         send,
+			startPath:    startPath,
+			absStartPath: absStartPath,
+			onlyDirs:     onlyDirs,
+		}
+		if opts.followSymlinks {
+			realStartPath, err := filepath.EvalSymlinks(absStartPath)
+			if err != nil {
+				return err
+			}
+			w.realRoots = []string{realStartPath}
+		}
+		if err := w.walk(startPath, startPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkIndex calls send for every file in opts.onlyFiles beneath each of the
+// start paths, in order, without looking at the working tree. Files are
+// skipped as they would be by the walk, other than for .gitignore files,
+// which don't apply to tracked files anyway.
+func walkIndex(startPaths []string, opts walkOptions, send func(path string) error) error {
+	files := make([]string, 0, len(opts.onlyFiles))
+	for file := range opts.onlyFiles {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	opts.gitignore = nil
+
+	for _, startPath := range startPaths {
+		start := opts.repo.relativePath(startPath)
+		found := false
+		for _, file := range files {
+			var rel string
+			switch {
+			case file == start:
+				rel = "."
+			case start == ".":
+				rel = file
+			case strings.HasPrefix(file, start+"/"):
+				rel = file[len(start)+1:]
+			default:
+				continue
+			}
+			found = true
+			if rel == "." {
+				if err := send(startPath); err != nil {
+					return err
+				}
+				continue
+			}
+			if opts.skipIndexed(rel) {
+				continue
+			}
+			// Submodules have a single entry in the index, for the commit
+			// that's checked out
+			if opts.submodules != nil && opts.submodules.contains(file) && !opts.includeSubmodules {
+				continue
+			}
+			if err := send(filepath.Join(startPath, filepath.FromSlash(rel))); err != nil {
+				return err
+			}
+		}
+		if !found && !opts.noCheck {
+			return fmt.Errorf("%s: no such file or directory in the index (pass --no-check to match paths that don't exist)", startPath)
+		}
+	}
+	return nil
+}
+
+// skipIndexed reports whether a file from the index should be skipped, given
+// its path relative to the start path, because it or one of the directories
+// containing it would be skipped by the walk.
+func (opts walkOptions) skipIndexed(rel string) bool {
+	if opts.skip(rel, "", false) {
+		return true
+	}
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if opts.skip(dir, "", true) {
+			return true
+		}
+	}
+	return false
+}
+
+// walker walks the tree beneath a single start path.
+type walker struct {
+	opts         walkOptions
+	send         func(path string) error
+	startPath    string
+	absStartPath string
+	onlyDirs     map[string]bool
+	// realRoots holds the resolved paths of the start path and of each of
+	// the symlinked directories currently being walked, which are used to
+	// detect symlink cycles.
+	realRoots []string
+}
+
+// walk walks the directory root, reporting the paths inside it as if they
+// were inside dir. They differ when walking the target of a symlink, as paths
+// are shown as they appear in the repository, beneath the link.
+func (w *walker) walk(root, dir string) error {
+	return filepath.WalkDir(root, func(fsPath string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		path := fsPath
+		if root != w.startPath {
+			if fsPath == root {
+				// The symlink has already been visited as a directory
+				return nil
+			}
+			rel, err := filepath.Rel(root, fsPath)
+			if err != nil {
+				return err
+			}
+			path = filepath.Join(dir, rel)
+		}
+		// Walking up out of a subdirectory can lead into the repository's
+		// .git directory, as well as starting in its root. In worktrees and
+		// submodules, .git is a file pointing at the repository instead.
+		if d.Name() == ".git" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		isDir := d.IsDir()
+		if !isDir && d.Type()&os.ModeSymlink != 0 && w.opts.followSymlinks {
+			// Links to files are matched using the link's path, so it's only
+			// links to directories that need to be handled differently
+			if info, err := os.Stat(fsPath); err == nil && info.IsDir() {
+				return w.walkSymlink(fsPath, path)
+			}
+		}
+		if err := w.visit(path, isDir); err != nil {
+			if err == filepath.SkipDir && !isDir {
+				// Returning SkipDir for a file would skip the rest of the
+				// directory it's in
+				return nil
+			}
+			return err
+		}
+		if isDir {
+			return nil
+		}
+		if w.opts.onlyFiles != nil {
+			if _, ok := w.opts.onlyFiles[nfc(w.opts.repo.relativePath(path))]; !ok {
+				return nil
+			}
+		}
+		return w.send(path)
+	})
+}
+
+// walkSymlink walks the target of a symlink to a directory, unless doing so
+// would lead to a cycle.
+func (w *walker) walkSymlink(fsPath, path string) error {
+	if err := w.visit(path, true); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	target, err := filepath.EvalSymlinks(fsPath)
+	if err != nil {
+		return err
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	realParent, err := filepath.EvalSymlinks(filepath.Dir(fsPath))
+	if err != nil {
+		return err
+	}
+	realParent, err = filepath.Abs(realParent)
+	if err != nil {
+		return err
+	}
+	// Following a link to a directory containing the link, or containing
+	// any directory we're already inside, would never end
+	for _, dir := range append([]string{realParent}, w.realRoots...) {
+		if isWithin(dir, target) {
+			return nil
+		}
+	}
+
+	w.realRoots = append(w.realRoots, target)
+	defer func() { w.realRoots = w.realRoots[:len(w.realRoots)-1] }()
+	return w.walk(target, path)
+}
+
+// visit applies the walk options to a file or directory before it's reported
+// or descended into, returning filepath.SkipDir if it should be skipped.
+func (w *walker) visit(path string, isDir bool) error {
+	rel, err := filepath.Rel(w.startPath, path)
+	if err != nil {
+		return err
+	}
+	abs := filepath.Join(w.absStartPath, rel)
+
+	if path != w.startPath && w.opts.skip(filepath.ToSlash(rel), abs, isDir) {
+		return filepath.SkipDir
+	}
+	if !isDir {
+		return nil
+	}
+	if path != w.startPath && w.opts.submoduleRulesets == nil && w.opts.submodules != nil {
+		if repoPath := w.opts.repo.relativePath(path); w.opts.submodules.contains(repoPath) {
+			if w.opts.includeSubmodules && (w.opts.onlyFiles == nil || w.opts.onlyFiles[nfc(repoPath)]) {
+				if err := w.send(path); err != nil {
+					return err
+				}
+			}
+			return filepath.SkipDir
+		}
+	}
+	if path != w.startPath && w.onlyDirs != nil && !w.onlyDirs[nfc(w.opts.repo.relativePath(path))] {
+		// There's no point looking for files in here
+		return filepath.SkipDir
+	}
+	if w.opts.gitignore != nil {
+		// Patterns for the directory's contents need to be loaded before
+		// WalkDir visits them
+		if err := w.opts.gitignore.loadDir(abs); err != nil {
+			return err
+		}
+	}
+	if w.opts.pruner != nil {
+		show, descend := w.opts.pruner.enterDir(path)
+		if show {
+			if err := w.send(path + string(filepath.Separator)); err != nil {
+				return err
+			}
+		}
+		if !descend {
+			return filepath.SkipDir
+		}
+	}
+	return nil
+}
+
+// isWithin reports whether path is dir or is inside it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// parentDirs returns the set of directories containing any of the files,
+// including all of their ancestors. The paths use forward slashes.
+func parentDirs(files map[string]bool) map[string]bool {
+	dirs := make(map[string]bool)
+	for file := range files {
+		for dir := path.Dir(file); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	return dirs
+}
+
+// skip reports whether the walk should skip a file or directory, given its
+// path relative to the start path and its absolute path.
+func (opts walkOptions) skip(rel, abs string, isDir bool) bool {
+	if isDir && opts.maxDepth >= 0 && strings.Count(rel, "/")+1 > opts.maxDepth {
+		return true
+	}
+	if opts.skipHidden {
+		name := path.Base(rel)
+		if strings.HasPrefix(name, ".") && (name != ".github" || opts.skipGitHub) {
+			return true
+		}
+	}
+	if matchAnyGlob(opts.ignore, rel, isDir) {
+		return true
+	}
+	return opts.gitignore != nil && opts.gitignore.ignored(abs, isDir)
+}
+
+// readPaths reads a list of paths from r, calling send for each one. Paths are
+// separated by newlines (with any carriage returns removed), or by NUL bytes
+// if nulSeparated is set. Empty entries are skipped.
+func readPaths(r io.Reader, nulSeparated bool, send func(path string) error) error {
+	scanner := bufio.NewScanner(r)
+	// Allow for paths much longer than the default token size limit
+	scanner.Buffer(nil, 1024*1024)
+	if nulSeparated {
+		scanner.Split(scanNUL)
+	}
+	for scanner.Scan() {
+		path := scanner.Text()
+		if !nulSeparated {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path == "" {
+			continue
+		}
+		if err := send(path); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// scanNUL is a bufio.SplitFunc that splits its input on NUL bytes.
+func scanNUL(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hmarr/codeowners"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkPathsFollowSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, path := range []string{
+		filepath.Join(root, "src", "main.go"),
+		filepath.Join(outside, "lib", "lib.go"),
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+	links := map[string]string{
+		filepath.Join(root, "main.go"):         filepath.Join(root, "src", "main.go"),
+		filepath.Join(root, "vendor"):          filepath.Join(outside, "lib"),
+		filepath.Join(root, "src", "parent"):   root,
+		filepath.Join(outside, "lib", "back"):  root,
+		filepath.Join(root, "src", "missing"):  filepath.Join(root, "nope"),
+		filepath.Join(root, "src", "self"):     filepath.Join(root, "src"),
+		filepath.Join(outside, "lib", "outer"): outside,
+	}
+	for link, target := range links {
+		require.NoError(t, os.Symlink(target, link))
+	}
+
+	walk := func(opts walkOptions) []string {
+		var paths []string
+		err := walkPaths([]string{root}, opts, func(path string) error {
+			rel, err := filepath.Rel(root, path)
+			require.NoError(t, err)
+			paths = append(paths, filepath.ToSlash(rel))
+			return nil
+		})
+		require.NoError(t, err)
+		return paths
+	}
+
+	assert.ElementsMatch(t, []string{
+		"main.go",
+		"src/main.go",
+		"src/missing",
+		"src/parent",
+		"src/self",
+		"vendor",
+	}, walk(walkOptions{maxDepth: -1}))
+
+	// Links back to directories being walked are skipped, while links to
+	// files and broken links are reported using the link's path
+	assert.ElementsMatch(t, []string{
+		"main.go",
+		"src/main.go",
+		"src/missing",
+		"vendor/lib.go",
+	}, walk(walkOptions{maxDepth: -1, followSymlinks: true}))
+}
+
+func TestWalkPathsNormalizesUnicode(t *testing.T) {
+	root := t.TempDir()
+	// On macOS, file names come back from the filesystem decomposed (NFD),
+	// but git lists them composed (NFC)
+	decomposed := filepath.Join(root, "docs", "e\u0301te\u0301.md")
+	cjk := filepath.Join(root, "docs", "\u65e5\u672c\u8a9e.md")
+	for _, path := range []string{decomposed, cjk, filepath.Join(root, "docs", "untracked.md")} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+
+	opts := walkOptions{
+		repo:      repository{root: root},
+		maxDepth:  -1,
+		onlyFiles: nfcFiles(map[string]bool{"docs/\u00e9t\u00e9.md": true, "docs/\u65e5\u672c\u8a9e.md": true}),
+	}
+	var paths []string
+	require.NoError(t, walkPaths([]string{root}, opts, func(path string) error {
+		paths = append(paths, path)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{decomposed, cjk}, paths)
+}
+
+func TestMatchWalkedPathsNormalizesUnicode(t *testing.T) {
+	root := t.TempDir()
+	decomposed := filepath.Join(root, "docs", "u\u0301til", "index.md")
+	require.NoError(t, os.MkdirAll(filepath.Dir(decomposed), 0o755))
+	require.NoError(t, os.WriteFile(decomposed, nil, 0o644))
+
+	// The rule is written composed, but still matches the decomposed path,
+	// which is reported as it was found
+	settings := parseSettings{dialect: codeowners.DialectGitHub}
+	ruleset, err := settings.parse(strings.NewReader("/docs/\u00fatil/ @org/docs\n"))
+	require.NoError(t, err)
+	opts := walkOptions{repo: repository{root: root}, maxDepth: -1}
+	var paths []string
+	require.NoError(t, walkPaths([]string{root}, opts, func(path string) error {
+		rule, err := opts.match(ruleset, opts.repo.relativePath(path))
+		require.NoError(t, err)
+		if assert.NotNil(t, rule, path) {
+			assert.Equal(t, "org/docs", rule.Owners[0].Value)
+		}
+		paths = append(paths, path)
+		return nil
+	}))
+	assert.Equal(t, []string{decomposed}, paths)
+}
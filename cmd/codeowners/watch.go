@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hmarr/codeowners"
+)
+
+// watchDebounce is how long to wait for changes to settle before showing the
+// results again. Editors and version control tools tend to touch several
+// files at once, and there's no point matching after each of them.
+const watchDebounce = 200 * time.Millisecond
+
+// clearScreen moves the cursor to the top left and clears the terminal.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// watchedFiles returns the CODEOWNERS files to watch for changes, given the
+// paths passed with -f, and otherwise the dialect's standard locations.
+func watchedFiles(paths []string, dialect codeowners.Dialect) []string {
+	if len(paths) > 0 {
+		return paths
+	}
+	if path := dialect.FindFile(); path != "" {
+		return []string{path}
+	}
+	return nil
+}
+
+// watch calls evaluate, then calls it again whenever one of the CODEOWNERS
+// files changes, or something is added, removed or changed beneath one of the
+// start paths. It only returns if the watcher fails.
+func watch(codeownersPaths, startPaths []string, evaluate func() int) int {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer watcher.Close()
+
+	w := newWatchFilter(codeownersPaths, startPaths)
+	for _, dir := range w.dirs() {
+		if err := addWatches(watcher, dir); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+
+	// The output's cleared between runs on a terminal, so that it reads like
+	// a live view, and separated by a blank line otherwise
+	separator := "\n"
+	if isTerminal(os.Stdout) {
+		separator = clearScreen
+		fmt.Print(separator)
+	}
+	evaluate()
+
+	var settled <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return 0
+			}
+			if !w.relevant(event) {
+				continue
+			}
+			// New directories need watching too, as watches aren't recursive
+			if event.Op&fsnotify.Create != 0 && w.inStartPath(event.Name) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addWatches(watcher, event.Name); err != nil {
+						fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+					}
+				}
+			}
+			settled = time.After(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return 0
+			}
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+
+		case <-settled:
+			settled = nil
+			fmt.Print(separator)
+			evaluate()
+		}
+	}
+}
+
+// addWatches watches dir and every directory beneath it, other than .git
+// directories, whose contents change all the time without affecting what's
+// matched.
+func addWatches(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories can disappear while they're being walked
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// watchFilter decides which filesystem events are worth matching the paths
+// again for.
+type watchFilter struct {
+	// files holds the absolute paths of the CODEOWNERS files.
+	files map[string]bool
+	// startDirs holds the absolute paths of the start paths that are
+	// directories.
+	startDirs []string
+	// startFiles holds the absolute paths of the start paths that aren't.
+	startFiles map[string]bool
+}
+
+func newWatchFilter(codeownersPaths, startPaths []string) *watchFilter {
+	w := &watchFilter{files: make(map[string]bool), startFiles: make(map[string]bool)}
+	for _, path := range codeownersPaths {
+		w.files[absPath(path)] = true
+	}
+	for _, path := range startPaths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			w.startDirs = append(w.startDirs, absPath(path))
+		} else {
+			w.startFiles[absPath(path)] = true
+		}
+	}
+	return w
+}
+
+// dirs returns the directories to watch. Files are watched through the
+// directories containing them, rather than directly, as editors often save
+// files by replacing them, which would leave a watch on the file itself
+// pointing at the old copy.
+func (w *watchFilter) dirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for path := range w.files {
+		add(filepath.Dir(path))
+	}
+	for path := range w.startFiles {
+		add(filepath.Dir(path))
+	}
+	for _, dir := range w.startDirs {
+		add(dir)
+	}
+	return dirs
+}
+
+// relevant reports whether an event could change the results.
+func (w *watchFilter) relevant(event fsnotify.Event) bool {
+	// Permission changes don't affect ownership
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	path := absPath(event.Name)
+	return w.files[path] || w.startFiles[path] || w.inStartPath(path)
+}
+
+// inStartPath reports whether path is beneath one of the start paths that's a
+// directory, outside of any .git directory.
+func (w *watchFilter) inStartPath(path string) bool {
+	path = absPath(path)
+	for _, dir := range w.startDirs {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if !insideGitDir(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// insideGitDir reports whether a relative path is, or is beneath, a .git
+// directory.
+func insideGitDir(rel string) bool {
+	for dir := rel; dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == ".git" {
+			return true
+		}
+	}
+	return false
+}
+
+// absPath returns the absolute form of path, or path itself if that can't be
+// determined.
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchFilter(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{".github", "src", "docs"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, d), 0o755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "index.md"), nil, 0o644))
+
+	codeownersPath := filepath.Join(dir, ".github", "CODEOWNERS")
+	w := newWatchFilter([]string{codeownersPath}, []string{filepath.Join(dir, "src"), filepath.Join(dir, "docs", "index.md")})
+	assert.ElementsMatch(t, []string{filepath.Join(dir, ".github"), filepath.Join(dir, "src"), filepath.Join(dir, "docs")}, w.dirs())
+
+	tests := []struct {
+		name     string
+		path     string
+		op       fsnotify.Op
+		relevant bool
+	}{
+		{"codeowners replaced", codeownersPath, fsnotify.Create, true},
+		{"codeowners written", codeownersPath, fsnotify.Write, true},
+		{"codeowners chmod", codeownersPath, fsnotify.Chmod, false},
+		{"editor temp file beside codeowners", filepath.Join(dir, ".github", ".CODEOWNERS.swp"), fsnotify.Create, false},
+		{"file in start dir", filepath.Join(dir, "src", "main.go"), fsnotify.Create, true},
+		{"nested file removed", filepath.Join(dir, "src", "api", "api.go"), fsnotify.Remove, true},
+		{"git internals", filepath.Join(dir, "src", ".git", "index"), fsnotify.Write, false},
+		{"start file", filepath.Join(dir, "docs", "index.md"), fsnotify.Write, true},
+		{"sibling of start file", filepath.Join(dir, "docs", "other.md"), fsnotify.Create, false},
+		{"outside start paths", filepath.Join(dir, "README.md"), fsnotify.Create, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.relevant, w.relevant(fsnotify.Event{Name: tt.path, Op: tt.op}))
+		})
+	}
+}
@@ -33,42 +33,47 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 // LoadFileFromStandardLocation loads and parses a CODEOWNERS file at one of the
-// standard locations for CODEOWNERS files (./, .github/, docs/). If run from a
-// git repository, all paths are relative to the repository root.
-func LoadFileFromStandardLocation() (Ruleset, error) {
-	path := findFileAtStandardLocation()
+// standard locations for CODEOWNERS files (./, .github/, docs/), or those of
+// the dialect passed with WithDialect. If run from a git repository, all paths
+// are relative to the repository root.
+func LoadFileFromStandardLocation(options ...ParseOption) (Ruleset, error) {
+	path := newParseOptions(options).dialect.FindFile()
 	if path == "" {
 		return nil, fmt.Errorf("could not find CODEOWNERS file at any of the standard locations")
 	}
-	return LoadFile(path)
+	return LoadFile(path, options...)
 }
 
-// LoadFile loads and parses a CODEOWNERS file at the path specified.
-func LoadFile(path string) (Ruleset, error) {
+// LoadFile loads and parses a CODEOWNERS file at the path specified, with the
+// same options as ParseFile.
+func LoadFile(path string, options ...ParseOption) (Ruleset, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	return ParseFile(f)
+	defer f.Close()
+	return ParseFile(f, options...)
 }
 
-// findFileAtStandardLocation loops through the standard locations for
+// FindFileAtStandardLocation loops through the standard locations for
 // CODEOWNERS files (./, .github/, docs/), and returns the first place a
-// CODEOWNERS file is found. If run from a git repository, all paths are
-// relative to the repository root.
-func findFileAtStandardLocation() string {
-	pathPrefix := ""
-	repoRoot, inRepo := findRepositoryRoot()
-	if inRepo {
-		pathPrefix = repoRoot
-	}
+// CODEOWNERS file is found, or "" if there isn't one. If run from a git
+// repository, all paths are relative to the repository root.
+func FindFileAtStandardLocation() string {
+	return DialectGitHub.FindFile()
+}
 
-	for _, path := range []string{"CODEOWNERS", ".github/CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"} {
-		fullPath := filepath.Join(pathPrefix, path)
+// findFileAt returns the first of the paths, relative to a directory, where a
+// CODEOWNERS file is found, or "" if there isn't one.
+func findFileAt(dir string, paths []string) string {
+	for _, path := range paths {
+		fullPath := filepath.Join(dir, path)
 		if fileExists(fullPath) {
 			return fullPath
 		}
@@ -96,16 +101,42 @@ func findRepositoryRoot() (string, bool) {
 	return strings.TrimSpace(string(output)), true
 }
 
-// Ruleset is a collection of CODEOWNERS rules.
+// Ruleset is a collection of CODEOWNERS rules. Rulesets aren't modified by
+// matching, so a ruleset can be shared by goroutines matching paths at once.
 type Ruleset []Rule
 
+// Concat combines rulesets into a single ruleset containing each of their rules
+// in order. As the last matching rule takes precedence, rules from later
+// rulesets override those from earlier ones, which allows a base ruleset to be
+// layered with overrides. Line numbers are left as they are, so they refer to
+// the file each rule was originally parsed from.
+func Concat(rulesets ...Ruleset) Ruleset {
+	combined := Ruleset{}
+	for _, r := range rulesets {
+		combined = append(combined, r...)
+	}
+	return combined
+}
+
 // Match finds the last rule in the ruleset that matches the path provided. When
 // determining the ownership of a file using CODEOWNERS, order matters, and the
-// last matching rule takes precedence.
+// last matching rule takes precedence. A negated rule can be the one returned,
+// as it takes precedence like any other, so callers should check Negated and
+// treat the path as unowned if it's set.
 func (r Ruleset) Match(path string) (*Rule, error) {
+	i, err := r.MatchIndex(path)
+	if i < 0 {
+		return nil, err
+	}
+	return &r[i], err
+}
+
+// MatchDir finds the last rule in the ruleset that matches the directory
+// provided, as Rule.MatchDir decides, much as Match does for files.
+func (r Ruleset) MatchDir(path string) (*Rule, error) {
 	for i := len(r) - 1; i >= 0; i-- {
 		rule := &r[i]
-		match, err := rule.Match(path)
+		match, err := rule.MatchDir(path)
 		if match || err != nil {
 			return rule, err
 		}
@@ -113,25 +144,202 @@ func (r Ruleset) Match(path string) (*Rule, error) {
 	return nil, nil
 }
 
+// MatchIndex finds the last rule in the ruleset that matches the path
+// provided, as Match does, returning its index in the ruleset, or -1 if no
+// rule matches. Unlike the rule's line number, the index tells apart rules
+// from different files combined with Concat, and rules expanded from a single
+// line with WithBraceExpansion. If the path can't be matched, the index is
+// that of the rule it was being matched against.
+func (r Ruleset) MatchIndex(path string) (int, error) {
+	for i := len(r) - 1; i >= 0; i-- {
+		match, err := r[i].Match(path)
+		if match || err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// MatchAll finds every rule in the ruleset that matches the path provided, in
+// the order they appear. The last of them, if there are any, is the rule that
+// Match returns.
+func (r Ruleset) MatchAll(path string) ([]Rule, error) {
+	var matches []Rule
+	for _, rule := range r {
+		match, err := rule.Match(path)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			matches = append(matches, rule)
+		}
+	}
+	return matches, nil
+}
+
+// MatchSections finds the last rule matching the path provided in each
+// GitLab-style section of the ruleset, as with GitLab, where a file is owned
+// by the owners of every section with a rule matching it. The rules are
+// returned in the order their sections first appear in the ruleset. Rules
+// that come before the first section header form a section of their own, with
+// no name, and sections with the same name, compared case-insensitively, are
+// treated as one. For rulesets without sections, the result is the rule that
+// Match returns, if there is one. Rules parsed in the Gitea dialect each count
+// as a section, so every matching rule is returned, as every one applies.
+func (r Ruleset) MatchSections(path string) ([]*Rule, error) {
+	// Working backwards, only the rules of sections that haven't matched yet
+	// need to be tried. first records where each section first appears.
+	matches := make(map[string]*Rule)
+	first := make(map[string]int)
+	var keys []string
+	for i := len(r) - 1; i >= 0; i-- {
+		rule := &r[i]
+		key := r.sectionKey(i)
+		if _, ok := first[key]; !ok {
+			keys = append(keys, key)
+		}
+		first[key] = i
+		if matches[key] != nil {
+			continue
+		}
+		match, err := rule.Match(path)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			matches[key] = rule
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return first[keys[i]] < first[keys[j]] })
+	var rules []*Rule
+	for _, key := range keys {
+		if rule := matches[key]; rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// sectionKey returns the key that identifies the section of the rule at index
+// i, which is the same for all the rules in a section.
+func (r Ruleset) sectionKey(i int) string {
+	// Gitea gives files the owners of every rule matching them, so each of
+	// its rules is treated as a section of its own
+	if r[i].pattern.gitea {
+		return "\x00" + strconv.Itoa(i)
+	}
+	return strings.ToLower(r[i].Section)
+}
+
 // Rule is a CODEOWNERS rule that maps a gitignore-style path pattern to a set
 // of owners.
 type Rule struct {
 	Owners     []Owner
 	Comment    string
 	LineNumber int
-	pattern    pattern
+	// Section is the name of the GitLab-style section the rule belongs to, as
+	// written in the section header. It's empty for rules that aren't in a
+	// section.
+	Section string
+	// Optional is set for rules in a GitLab-style optional section, whose
+	// header starts with a caret, as in ^[Docs]. Approval from the owners of
+	// an optional section isn't required.
+	Optional bool
+	// MinApprovals is the number of approvals required from the owners of the
+	// rule's section, as in [Docs][2]. It's zero if the section header doesn't
+	// say, in which case GitLab requires one.
+	MinApprovals int
+	// SectionOwners holds the default owners of the rule's section, listed
+	// after its header, as in [Docs] @org/docs. Rules in the section that
+	// don't list any owners of their own inherit them, and have
+	// InheritsOwners set.
+	SectionOwners  []Owner
+	InheritsOwners bool
+	// InvalidOwners holds the owners listed on the rule that aren't valid,
+	// which are only accepted when parsing with WithLenientOwners.
+	InvalidOwners []string
+	// Negated is set for rules whose pattern starts with !, which are only
+	// parsed with WithNegation. They have no owners, so a path whose last
+	// matching rule is negated is unowned.
+	Negated bool
+	// Leading holds the lines between the previous rule, or the start of
+	// the file, and this one that aren't rules, such as comments, blank lines
+	// and section headers, as they appear in the file. Trailing holds the
+	// ones after the last rule of the file. They're only set when parsing
+	// with WithComments.
+	Leading  []string
+	Trailing []string
+	pattern  pattern
+
+	// source is the line the rule was parsed from, and parsedText is the
+	// rule as text returns it when it was parsed, which shows whether it's
+	// been modified since. newline is the line ending used by the file,
+	// noFinalNewline is set on the last rule of a file that doesn't end with
+	// one, and bom on the first rule of a file that starts with a byte order
+	// mark. They're only set when parsing with WithComments.
+	source         string
+	parsedText     string
+	newline        string
+	noFinalNewline bool
+	bom            bool
+	// brace is set on rules expanded from a pattern with braces, which is
+	// shared by all of them.
+	brace *braceGroup
+}
+
+// NewRule returns a rule with the given gitignore-style pattern and owners,
+// such as to add to a ruleset before writing it out with WriteTo. Whitespace
+// and # characters in the pattern don't need escaping.
+func NewRule(pattern string, owners []Owner) (Rule, error) {
+	if pattern == "" {
+		return Rule{}, fmt.Errorf("empty pattern")
+	}
+	escaped := escapePattern(pattern)
+	if err := checkPatternSyntax(escaped); err != nil {
+		return Rule{}, err
+	}
+	p, err := newPattern(escaped, false)
+	if err != nil {
+		return Rule{}, err
+	}
+	return Rule{pattern: p, Owners: owners}, nil
+}
+
+// InSection reports whether the rule belongs to the named section. As with
+// GitLab, section names are compared case-insensitively.
+func (r Rule) InSection(name string) bool {
+	return r.Section != "" && strings.EqualFold(r.Section, name)
 }
 
-// RawPattern returns the rule's gitignore-style path pattern.
+// RawPattern returns the rule's gitignore-style path pattern, starting with !
+// if the rule is negated.
 func (r Rule) RawPattern() string {
+	if r.Negated {
+		return "!" + r.pattern.pattern
+	}
 	return r.pattern.pattern
 }
 
-// Match tests whether the provided matches the rule's pattern.
+// Match tests whether the provided matches the rule's pattern. Negated rules
+// match the paths their pattern would match without the !.
 func (r Rule) Match(path string) (bool, error) {
 	return r.pattern.match(path)
 }
 
+// MatchDir tests whether the rule's pattern matches a directory, given its
+// path, with or without a trailing slash. It does if the pattern matches the
+// directory itself, or every path beneath it, whether or not the directory
+// exists. So docs/ and docs/** match the directory docs, and any directory
+// beneath it, while docs/* only matches the directories directly inside docs,
+// as it doesn't match the paths beneath them. An empty path is the root of the
+// repository, which only patterns matching every path, such as *, match.
+// Gitea's expressions match a directory if they match its path with a
+// trailing slash.
+func (r Rule) MatchDir(path string) (bool, error) {
+	return r.pattern.matchDir(path)
+}
+
 const (
 	// EmailOwner is the owner type for email addresses.
 	EmailOwner string = "email"
@@ -139,22 +347,29 @@ const (
 	TeamOwner string = "team"
 	// UsernameOwner is the owner type for GitHub usernames.
 	UsernameOwner string = "username"
+	// GroupOwner is the owner type for Bitbucket groups, written as
+	// @@@group.
+	GroupOwner string = "group"
 )
 
 // Owner represents an owner found in a rule.
 type Owner struct {
 	// Value is the name of the owner: the email addres, team name, or username.
 	Value string
-	// Type will be one of 'email', 'team', or 'username'.
+	// Type will be one of 'email', 'team', 'username', or for Bitbucket,
+	// 'group'.
 	Type string
 }
 
 // String returns a string representation of the owner. For email owners, it
 // simply returns the email address. For user and team owners it prepends an '@'
-// to the owner.
+// to the owner, and for groups, '@@@'.
 func (o Owner) String() string {
-	if o.Type == EmailOwner {
+	switch o.Type {
+	case EmailOwner:
 		return o.Value
+	case GroupOwner:
+		return "@@@" + o.Value
 	}
 	return "@" + o.Value
 }
@@ -0,0 +1,414 @@
+package codeowners
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcat(t *testing.T) {
+	base, err := ParseFile(strings.NewReader("* @org/everyone\n/docs/ @org/docs\n/src/ @org/src\n"))
+	require.NoError(t, err)
+	override, err := ParseFile(strings.NewReader("/src/api/ @org/api\n/docs/ @org/writers\n"))
+	require.NoError(t, err)
+
+	combined := Concat(base, override)
+	require.Len(t, combined, 5)
+
+	examples := []struct {
+		path       string
+		owner      string
+		lineNumber int
+	}{
+		// Only matched by the base ruleset
+		{"README.md", "org/everyone", 1},
+		{"src/main.go", "org/src", 3},
+		// Only matched by the override ruleset, which is more specific
+		{"src/api/main.go", "org/api", 1},
+		// Matched by the same pattern in both, where the override wins
+		{"docs/index.md", "org/writers", 2},
+	}
+
+	for _, e := range examples {
+		t.Run(e.path, func(t *testing.T) {
+			rule, err := combined.Match(e.path)
+			require.NoError(t, err)
+			require.NotNil(t, rule)
+			assert.Equal(t, e.owner, rule.Owners[0].Value)
+			assert.Equal(t, e.lineNumber, rule.LineNumber)
+		})
+	}
+
+	// Order matters: reversing the layers lets the base ruleset win again
+	rule, err := Concat(override, base).Match("docs/index.md")
+	require.NoError(t, err)
+	assert.Equal(t, "org/docs", rule.Owners[0].Value)
+}
+
+func TestConcatEmpty(t *testing.T) {
+	assert.Equal(t, Ruleset{}, Concat())
+	assert.Equal(t, Ruleset{}, Concat(nil, Ruleset{}))
+}
+
+func TestMatchAll(t *testing.T) {
+	ruleset, err := ParseFile(strings.NewReader("* @org/everyone\n*.go @org/go\n/docs/ @org/docs\n/src/ @org/src\n"))
+	require.NoError(t, err)
+
+	matches, err := ruleset.MatchAll("src/main.go")
+	require.NoError(t, err)
+	require.Len(t, matches, 3)
+	assert.Equal(t, []int{1, 2, 4}, []int{matches[0].LineNumber, matches[1].LineNumber, matches[2].LineNumber})
+
+	// The last match is the rule that Match returns
+	rule, err := ruleset.Match("src/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, *rule, matches[len(matches)-1])
+
+	matches, err = Ruleset{}.MatchAll("src/main.go")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestRuleMatchDir(t *testing.T) {
+	dirs := []string{"", "docs", "docs/a", "docs/a/b", "src/docs", "notes.md"}
+	examples := []struct {
+		pattern string
+		matches []string
+	}{
+		// A trailing slash or ** matches the directory and everything in it
+		{"docs/", []string{"docs", "docs/a", "docs/a/b", "src/docs"}},
+		{"/docs/", []string{"docs", "docs/a", "docs/a/b"}},
+		{"docs/**", []string{"docs", "docs/a", "docs/a/b"}},
+		{"docs/**/*", []string{"docs", "docs/a", "docs/a/b"}},
+		// Without one, the pattern matches the directory itself as well
+		{"docs", []string{"docs", "docs/a", "docs/a/b", "src/docs"}},
+		{"/docs", []string{"docs", "docs/a", "docs/a/b"}},
+		{"*.md", []string{"notes.md"}},
+		// A trailing * only matches the paths directly inside the directory,
+		// so only matches those directories, and not the paths beneath them
+		{"docs/*", []string{"docs/a"}},
+		{"/*", []string{"docs", "notes.md"}},
+		{"docs/*/", []string{"docs/a", "docs/a/b"}},
+		{"docs/**/a", []string{"docs/a", "docs/a/b"}},
+		{"/docs/*.md", nil},
+		// Only patterns matching everything match the root
+		{"*", dirs},
+		{"**", dirs},
+		{"/**", dirs},
+	}
+	for _, e := range examples {
+		t.Run(e.pattern, func(t *testing.T) {
+			rule, err := NewRule(e.pattern, nil)
+			require.NoError(t, err)
+			var matches []string
+			for _, dir := range dirs {
+				match, err := rule.MatchDir(dir)
+				require.NoError(t, err)
+				if match {
+					matches = append(matches, dir)
+				}
+
+				// Trailing slashes make no difference
+				if dir != "" {
+					withSlash, err := rule.MatchDir(dir + "/")
+					require.NoError(t, err)
+					assert.Equal(t, match, withSlash, dir)
+				}
+
+				// A directory the pattern doesn't match itself is only
+				// matched if everything beneath it is
+				if self, _ := rule.Match(dir); match && (dir == "" || !self) {
+					for _, beneath := range []string{"x", "README.md", "a/b", "a/b/c.go"} {
+						m, err := rule.Match(strings.TrimPrefix(dir+"/"+beneath, "/"))
+						require.NoError(t, err)
+						assert.True(t, m, "%s beneath %s", beneath, dir)
+					}
+				}
+			}
+			assert.Equal(t, e.matches, matches)
+		})
+	}
+}
+
+func TestRulesetMatchDir(t *testing.T) {
+	ruleset, err := ParseFile(strings.NewReader("* @org/everyone\ndocs/ @org/docs\ndocs/*.md @org/writers\n"))
+	require.NoError(t, err)
+
+	// As a file, docs doesn't match docs/, but as a directory it does
+	rule, err := ruleset.Match("docs")
+	require.NoError(t, err)
+	assert.Equal(t, 1, rule.LineNumber)
+	rule, err = ruleset.MatchDir("docs")
+	require.NoError(t, err)
+	assert.Equal(t, 2, rule.LineNumber)
+
+	// docs/*.md doesn't match every path in docs/guides
+	rule, err = ruleset.MatchDir("docs/guides/")
+	require.NoError(t, err)
+	assert.Equal(t, 2, rule.LineNumber)
+
+	rule, err = ruleset[1:].MatchDir("src")
+	require.NoError(t, err)
+	assert.Nil(t, rule)
+}
+
+func TestMatchIndex(t *testing.T) {
+	base, err := ParseFile(strings.NewReader("* @org/everyone\n*.go @org/go\n"))
+	require.NoError(t, err)
+	override, err := ParseFile(strings.NewReader("/docs/ @org/docs\n*.go @org/go-reviewers\n"))
+	require.NoError(t, err)
+	expanded, err := ParseFile(strings.NewReader("*.{md,txt} @org/docs\n"), WithBraceExpansion())
+	require.NoError(t, err)
+	ruleset := Concat(base, override, expanded)
+
+	examples := []struct {
+		path  string
+		index int
+	}{
+		// The two *.go rules are both on line 2 of their files
+		{"main.go", 3},
+		{"docs/guide.go", 3},
+		{"docs/index.html", 2},
+		{"README.md", 4},
+		{"notes.txt", 5},
+		{"Makefile", 0},
+	}
+	for _, e := range examples {
+		t.Run(e.path, func(t *testing.T) {
+			i, err := ruleset.MatchIndex(e.path)
+			require.NoError(t, err)
+			assert.Equal(t, e.index, i)
+
+			rule, err := ruleset.Match(e.path)
+			require.NoError(t, err)
+			assert.Same(t, &ruleset[i], rule)
+		})
+	}
+
+	i, err := Ruleset{}.MatchIndex("main.go")
+	require.NoError(t, err)
+	assert.Equal(t, -1, i)
+	i, err = base[1:].MatchIndex("Makefile")
+	require.NoError(t, err)
+	assert.Equal(t, -1, i)
+}
+
+func TestMatchAllConsistentWithMatch(t *testing.T) {
+	examples := []struct {
+		name    string
+		file    string
+		options []ParseOption
+	}{
+		{
+			name: "overlapping patterns",
+			file: "* @org/everyone\n*.go @org/go\n/src/ @org/src\nsrc/**/*_test.go @org/qa\n/src/vendor/** @org/deps\n",
+		},
+		{
+			name: "repeated patterns",
+			file: "*.go @alice\n/docs/ @org/docs\n*.go @bob\n",
+		},
+		{
+			name: "escapes and character classes",
+			file: "my\\ docs/ @alice\n\\#notes.md @bob\n*.[ch] @carol\nv[[:digit:]]*/ @dave\n*.\\* @erin\n",
+		},
+		{
+			name:    "negation",
+			file:    "* @org/everyone\n!/docs/internal/\n/docs/internal/public/ @org/docs\n",
+			options: []ParseOption{WithNegation()},
+		},
+		{
+			name: "sections",
+			file: "* @org/everyone\n[Docs] @org/docs\n*.md\n[Backend][2]\n*.go @org/go @org/platform\n",
+		},
+		{
+			name:    "brace expansion",
+			file:    "*.{go,md} @alice\n{src,docs}/ @bob\n",
+			options: []ParseOption{WithBraceExpansion()},
+		},
+		{
+			name:    "invalid patterns matched literally",
+			file:    "* @org/everyone\nsrc/[abc @alice\n",
+			options: []ParseOption{WithLenientPatterns()},
+		},
+		{
+			name:    "gitea expressions",
+			file:    ".* @org/everyone\n.*\\.go @org/go\n!docs/.* @org/code\n",
+			options: []ParseOption{WithDialect(DialectGitea)},
+		},
+	}
+	paths := []string{
+		"README.md", "main.go", "src/main.go", "src/lib/util_test.go", "src/vendor/x/y.go",
+		"my docs/index.md", "#notes.md", "lib/a.h", "v1/a.txt", "vx/a.txt", "file.*", "file.go",
+		"docs/internal/a.md", "docs/internal/public/b.md", "docs/guide.md", "src/[abc", "src/a",
+	}
+	for _, e := range examples {
+		t.Run(e.name, func(t *testing.T) {
+			ruleset, err := ParseFile(strings.NewReader(e.file), e.options...)
+			require.NoError(t, err)
+			for _, path := range paths {
+				matches, err := ruleset.MatchAll(path)
+				require.NoError(t, err)
+				rule, err := ruleset.Match(path)
+				require.NoError(t, err)
+				if rule == nil {
+					assert.Empty(t, matches, path)
+					continue
+				}
+				if assert.NotEmpty(t, matches, path) {
+					assert.Equal(t, *rule, matches[len(matches)-1], path)
+				}
+
+				// Every rule that matches is returned, in order
+				var want []Rule
+				for _, r := range ruleset {
+					if ok, _ := r.Match(path); ok {
+						want = append(want, r)
+					}
+				}
+				assert.Equal(t, want, matches, path)
+			}
+		})
+	}
+}
+
+func TestMatchSections(t *testing.T) {
+	file := `* @org/everyone
+[Backend]
+*.go @org/go
+/src/ @org/src
+[Docs]
+*.md @org/docs
+[backend]
+/src/legacy/ @org/legacy
+`
+	ruleset, err := ParseFile(strings.NewReader(file))
+	require.NoError(t, err)
+
+	lines := func(rules []*Rule) []int {
+		var lines []int
+		for _, rule := range rules {
+			lines = append(lines, rule.LineNumber)
+		}
+		return lines
+	}
+
+	// Each section's last match wins, with sections named the same treated as
+	// one, in the order they first appear
+	matches, err := ruleset.MatchSections("src/main.go")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 4}, lines(matches))
+
+	matches, err = ruleset.MatchSections("src/legacy/README.md")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 8, 6}, lines(matches))
+	assert.Equal(t, []string{"", "backend", "Docs"}, []string{matches[0].Section, matches[1].Section, matches[2].Section})
+
+	matches, err = ruleset.MatchSections("LICENSE")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, lines(matches))
+
+	// Without sections, it's the rule Match returns
+	ruleset, err = ParseFile(strings.NewReader("* @org/everyone\n*.go @org/go\n"))
+	require.NoError(t, err)
+	matches, err = ruleset.MatchSections("main.go")
+	require.NoError(t, err)
+	assert.Equal(t, []int{2}, lines(matches))
+
+	matches, err = Ruleset{}.MatchSections("main.go")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestMatchNegation(t *testing.T) {
+	file := `* @org/everyone
+!docs/
+docs/api/ @org/api
+[Backend]
+*.go @org/go
+!vendor/
+`
+	ruleset, err := ParseFile(strings.NewReader(file), WithNegation())
+	require.NoError(t, err)
+
+	owners := func(path string) []string {
+		rules, err := ruleset.MatchSections(path)
+		require.NoError(t, err)
+		var owners []string
+		for _, rule := range rules {
+			for _, o := range rule.Owners {
+				owners = append(owners, o.String())
+			}
+		}
+		return owners
+	}
+
+	// A negated rule clears the owners given by the rules before it, and a
+	// later rule can give them back
+	rule, err := ruleset.Match("docs/README.md")
+	require.NoError(t, err)
+	assert.True(t, rule.Negated)
+	assert.Empty(t, rule.Owners)
+	assert.Equal(t, "!docs/", rule.RawPattern())
+	assert.Empty(t, owners("docs/README.md"))
+	assert.Equal(t, []string{"@org/api"}, owners("docs/api/README.md"))
+	assert.Equal(t, []string{"@org/everyone"}, owners("README.md"))
+
+	// Negation only applies within its section
+	assert.Equal(t, []string{"@org/everyone", "@org/go"}, owners("main.go"))
+	assert.Equal(t, []string{"@org/everyone"}, owners("vendor/lib.go"))
+	assert.Equal(t, []string{"@org/go"}, owners("docs/main.go"))
+
+	// Without WithNegation, ! isn't allowed
+	_, err = ParseFile(strings.NewReader(file))
+	assert.EqualError(t, err, "line 2: unexpected character '!' at position 1")
+}
+
+func TestMatchConcurrent(t *testing.T) {
+	ruleset, err := ParseFile(strings.NewReader("* @org/everyone\n*.go @org/go\n/docs/**/*.md @org/docs\n/src/ @org/src\n"))
+	require.NoError(t, err)
+
+	// Run with -race to check that matching doesn't write to shared state
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				rule, err := ruleset.Match(fmt.Sprintf("docs/%d/%d.md", i, j))
+				assert.NoError(t, err)
+				if assert.NotNil(t, rule) {
+					assert.Equal(t, 3, rule.LineNumber)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestLoadFileOptions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "CODEOWNERS")
+	require.NoError(t, os.WriteFile(path, []byte("* @org/team\n!docs/\n/api/ api-team\n"), 0o644))
+
+	// LoadFile passes its options on to ParseFile
+	_, err := LoadFile(path)
+	assert.EqualError(t, err, "line 2: unexpected character '!' at position 1")
+	ruleset, err := LoadFile(path, WithNegation(), WithLenientOwners(), WithComments())
+	require.NoError(t, err)
+	require.Len(t, ruleset, 3)
+	assert.True(t, ruleset[1].Negated)
+	assert.Equal(t, []string{"api-team"}, ruleset[2].InvalidOwners)
+
+	// Options can be built up in a slice
+	opts := []ParseOption{WithNegation()}
+	opts = append(opts, WithLenientOwners())
+	ruleset, err = LoadFile(path, opts...)
+	require.NoError(t, err)
+	assert.Len(t, ruleset, 3)
+}
@@ -0,0 +1,262 @@
+package codeowners
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// CompiledRuleset is a ruleset prepared by Ruleset.Compile for matching a
+// large number of paths, such as every file in a repository. It matches paths
+// exactly as the ruleset does, and is safe for concurrent use.
+type CompiledRuleset struct {
+	rules Ruleset
+	// unindexed holds the indexes of the rules whose patterns can match paths
+	// beginning with any segment, in descending order, as rules are tried
+	// from the last.
+	unindexed []int
+	// index holds the indexes of the rest of the rules, in descending order,
+	// by the leading segments every path their patterns match begins with,
+	// up to indexDepth of them, in the form they match paths in.
+	index [numPathForms]map[string][]int
+	// forms records the forms the rules' patterns need paths converted to.
+	forms [numPathForms]bool
+	// sections holds the position of each rule's section among the
+	// sections, in the order they first appear, and numSections their
+	// number.
+	sections    []int
+	numSections int
+}
+
+// indexDepth is the most leading segments of paths that rules are indexed by,
+// so that rules for services/api/ and services/web/ are told apart.
+const indexDepth = 2
+
+// Compile prepares the ruleset for matching a large number of paths.
+// Patterns are already compiled when they're parsed, but the ruleset's Match
+// tries each rule in turn, converting the path for each one. The compiled
+// ruleset converts each path once, and indexes the rules by the leading
+// segments of the paths their patterns can match, such as docs/api for
+// /docs/api/*.md, so that most rules aren't tried against paths they can't
+// match. Match and MatchIndex don't allocate, at least for paths that don't
+// need converting, such as ASCII paths with forward slashes.
+//
+// The compiled ruleset shares its rules with the ruleset, which mustn't be
+// changed while it's in use. Compile returns an error for rules without a
+// pattern, such as a Rule{} that wasn't made by ParseFile or NewRule, which
+// can't match anything.
+func (r Ruleset) Compile() (*CompiledRuleset, error) {
+	c := &CompiledRuleset{rules: r, sections: make([]int, len(r))}
+	positions := make(map[string]int)
+	for i := range r {
+		if p := r[i].pattern; p.regex == nil && !p.leftAnchoredLiteral {
+			return nil, fmt.Errorf("rule %d has no pattern", i+1)
+		}
+		key := r.sectionKey(i)
+		position, ok := positions[key]
+		if !ok {
+			position = len(positions)
+			positions[key] = position
+		}
+		c.sections[i] = position
+	}
+	c.numSections = len(positions)
+
+	for i := len(r) - 1; i >= 0; i-- {
+		p := r[i].pattern
+		form := p.form()
+		c.forms[form] = true
+		key, ok := p.indexKey()
+		if !ok {
+			c.unindexed = append(c.unindexed, i)
+			continue
+		}
+		if c.index[form] == nil {
+			c.index[form] = make(map[string][]int)
+		}
+		c.index[form][key] = append(c.index[form][key], i)
+	}
+	return c, nil
+}
+
+// Match finds the last rule in the ruleset that matches the path provided, as
+// Ruleset.Match does.
+func (c *CompiledRuleset) Match(path string) (*Rule, error) {
+	i, err := c.MatchIndex(path)
+	if i < 0 {
+		return nil, err
+	}
+	return &c.rules[i], nil
+}
+
+// MatchIndex returns the index in the ruleset of the last rule that matches
+// the path provided, as Ruleset.MatchIndex does, or -1 if none do or the path
+// isn't valid.
+func (c *CompiledRuleset) MatchIndex(path string) (int, error) {
+	paths, err := c.convert(path)
+	if err != nil {
+		return -1, err
+	}
+	rules := c.candidates(&paths)
+	for i := rules.next(); i >= 0; i = rules.next() {
+		p := c.rules[i].pattern
+		if p.matchConverted(paths[p.form()]) {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// MatchSections finds the last rule matching the path provided in each
+// GitLab-style section of the ruleset, in the order their sections first
+// appear, as Ruleset.MatchSections does.
+func (c *CompiledRuleset) MatchSections(path string) ([]*Rule, error) {
+	paths, err := c.convert(path)
+	if err != nil {
+		return nil, err
+	}
+	var matches []*Rule
+	// Most paths match a rule in only a few sections, so their positions
+	// usually fit here without allocating
+	var buf [8]int
+	sections := buf[:0]
+	rules := c.candidates(&paths)
+	for i := rules.next(); i >= 0 && len(matches) < c.numSections; i = rules.next() {
+		if containsInt(sections, c.sections[i]) {
+			continue
+		}
+		p := c.rules[i].pattern
+		if p.matchConverted(paths[p.form()]) {
+			matches = append(matches, &c.rules[i])
+			sections = append(sections, c.sections[i])
+		}
+	}
+
+	// Sort the matches by the position of their sections, which are few
+	for i := 1; i < len(matches); i++ {
+		for j := i; j > 0 && sections[j] < sections[j-1]; j-- {
+			sections[j], sections[j-1] = sections[j-1], sections[j]
+			matches[j], matches[j-1] = matches[j-1], matches[j]
+		}
+	}
+	return matches, nil
+}
+
+// convert converts a path into each of the forms the rules' patterns match
+// paths in.
+func (c *CompiledRuleset) convert(path string) ([numPathForms]string, error) {
+	var paths [numPathForms]string
+	path, err := slashPath(path, isWindows)
+	if err != nil {
+		return paths, err
+	}
+	for form, ok := range c.forms {
+		if ok {
+			paths[form] = pathForm(form).convert(path)
+		}
+	}
+	return paths, nil
+}
+
+// candidates returns the rules that might match a path, given in each of the
+// forms the rules need.
+func (c *CompiledRuleset) candidates(paths *[numPathForms]string) candidates {
+	var rules candidates
+	rules.add(c.unindexed)
+	for form, index := range c.index {
+		if index == nil {
+			continue
+		}
+		// Keys with more segments have more slashes, so the keys of
+		// different depths never clash
+		path := paths[form]
+		for end, depth := 0, 0; end < len(path) && depth < indexDepth; depth++ {
+			if i := strings.IndexByte(path[end+1:], '/'); i >= 0 {
+				end += i + 1
+			} else {
+				end = len(path)
+			}
+			rules.add(index[path[:end]])
+		}
+	}
+	return rules
+}
+
+// candidates iterates over the indexes of the rules that might match a path,
+// merging the lists they're in, from the last rule to the first.
+type candidates struct {
+	lists [numPathForms*indexDepth + 1][]int
+	n     int
+}
+
+func (c *candidates) add(list []int) {
+	if len(list) > 0 {
+		c.lists[c.n] = list
+		c.n++
+	}
+}
+
+// next returns the index of the next rule, or -1 once there aren't any more.
+func (c *candidates) next() int {
+	best := -1
+	for i, list := range c.lists[:c.n] {
+		if len(list) > 0 && (best < 0 || list[0] > c.lists[best][0]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return -1
+	}
+	next := c.lists[best][0]
+	c.lists[best] = c.lists[best][1:]
+	return next
+}
+
+// indexKey returns the leading segments of every path the pattern matches, up
+// to indexDepth of them, in the pattern's form, and false if they can begin
+// with any segment. The leading segments are fixed for patterns that are
+// anchored to the root, which begin with a slash or have one in the middle, up
+// to the first segment that isn't literal. A last segment that's literal
+// matches itself or the paths beneath it, so it's fixed too.
+func (p pattern) indexKey() (string, bool) {
+	if p.gitea {
+		return "", false
+	}
+	s := p.pattern
+	if !strings.HasPrefix(s, "/") {
+		if i := strings.IndexByte(s, '/'); i < 0 || i == len(s)-1 {
+			return "", false
+		}
+	}
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimPrefix(s, "/"), "/") {
+		if segment == "" || strings.ContainsAny(segment, `*?[\`) || len(segments) == indexDepth {
+			break
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return "", false
+	}
+	key := strings.Join(segments, "/")
+	if p.foldCase {
+		// As with the prefixes of patterns, only ASCII can be compared byte
+		// for byte with a folded path
+		for i := 0; i < len(key); i++ {
+			if key[i] >= utf8.RuneSelf {
+				return "", false
+			}
+		}
+		key = strings.ToLower(key)
+	}
+	return key, true
+}
+
+func containsInt(list []int, n int) bool {
+	for _, m := range list {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package codeowners
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compilePaths returns paths to match against the rulesets largeFile
+// generates, some matching its rules and some not.
+func compilePaths(n int) []string {
+	var paths []string
+	for i := 0; len(paths) < n; i++ {
+		paths = append(paths,
+			fmt.Sprintf("services/svc-%d/pkg/file%d.go", i*7, i),
+			fmt.Sprintf("services/svc-%d/config/app.yaml", i*4+3),
+			fmt.Sprintf("docs/svc-%d/README.md", i),
+			fmt.Sprintf("src/pkg%d/main.go", i),
+		)
+	}
+	return paths[:n]
+}
+
+func TestCompiledRulesetConsistentWithRuleset(t *testing.T) {
+	examples := []struct {
+		name    string
+		file    string
+		options []ParseOption
+	}{
+		{
+			name: "anchored and unanchored patterns",
+			file: "* @org/everyone\n*.go @org/go\n/src/ @org/src\nsrc/**/*_test.go @org/qa\n/src/vendor/** @org/deps\ndocs/*.md @org/docs\n/docs @alice\n",
+		},
+		{
+			name: "nested directories",
+			file: "/services/ @org/platform\n/services/api/ @org/api\nservices/web/*.go @org/web\n/services/*/docs/ @org/docs\n/services/api/v1/ @org/v1\n/services/api @alice\n",
+		},
+		{
+			name: "escapes and character classes",
+			file: "my\\ docs/ @alice\n\\#notes.md @bob\n*.[ch] @carol\nv[[:digit:]]*/ @dave\n/src/[abc]/ @erin\n",
+		},
+		{
+			name:    "negation",
+			file:    "* @org/everyone\n!/docs/internal/\n/docs/internal/public/ @org/docs\n",
+			options: []ParseOption{WithNegation()},
+		},
+		{
+			name: "sections",
+			file: "* @org/everyone\n[Docs] @org/docs\n/docs/\n*.md\n[Backend][2]\n/src/ @org/go @org/platform\n[docs]\n/docs/internal/ @org/internal\n",
+		},
+		{
+			name:    "gitea expressions",
+			file:    ".* @org/everyone\n.*\\.go @org/go\n!docs/.* @org/code\nsrc/.* @org/src\n",
+			options: []ParseOption{WithDialect(DialectGitea)},
+		},
+		{
+			name:    "ignoring case",
+			file:    "* @org/everyone\n/Docs/ @org/docs\nSRC/*.go @org/go\n/\u00dcber/ @alice\n",
+			options: []ParseOption{WithCaseInsensitive(), WithUnicodeNormalization()},
+		},
+	}
+	paths := []string{
+		"README.md", "main.go", "src/main.go", "src/lib/util_test.go", "src/vendor/x/y.go", "src/a/b.c",
+		"my docs/index.md", "#notes.md", "lib/a.h", "v1/a.txt", "docs", "docs/guide.md", "docs/a/b.md",
+		"docs/internal/a.md", "docs/internal/public/b.md", "DOCS/guide.md", "Src/main.go", "\u017frc/main.go",
+		"u\u0308ber/a.txt", "", "/src/main.go", "src", "services", "services/api", "services/api/main.go",
+		"services/web/main.go", "services/web/docs/a.md", "services/api/v1/x.go", "services/apix/a", "services//api/a",
+	}
+	for _, e := range examples {
+		t.Run(e.name, func(t *testing.T) {
+			ruleset, err := ParseFile(strings.NewReader(e.file), e.options...)
+			require.NoError(t, err)
+			// Rules parsed with different options have patterns that need
+			// paths converted differently
+			rule, err := NewRule("/docs/guide.md", []Owner{{Value: "bob", Type: UsernameOwner}})
+			require.NoError(t, err)
+			for _, r := range []Ruleset{ruleset, append(ruleset, rule)} {
+				compiled, err := r.Compile()
+				require.NoError(t, err)
+				for _, path := range paths {
+					want, err := r.MatchIndex(path)
+					require.NoError(t, err)
+					got, err := compiled.MatchIndex(path)
+					require.NoError(t, err)
+					assert.Equal(t, want, got, path)
+
+					wantSections, err := r.MatchSections(path)
+					require.NoError(t, err)
+					gotSections, err := compiled.MatchSections(path)
+					require.NoError(t, err)
+					assert.Equal(t, wantSections, gotSections, path)
+					for i := range wantSections {
+						assert.Same(t, wantSections[i], gotSections[i], path)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCompiledRulesetLargeFile(t *testing.T) {
+	ruleset, err := ParseFile(bytes.NewReader(largeFile(16 << 10)))
+	require.NoError(t, err)
+	compiled, err := ruleset.Compile()
+	require.NoError(t, err)
+	for _, path := range compilePaths(500) {
+		want, err := ruleset.Match(path)
+		require.NoError(t, err)
+		got, err := compiled.Match(path)
+		require.NoError(t, err)
+		assert.Same(t, want, got, path)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = compiled.Match("services/svc-140/pkg/file20.go")
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestCompiledRulesetConcurrentMatch(t *testing.T) {
+	// Run with -race to check that matching doesn't share any state
+	ruleset, err := ParseFile(bytes.NewReader(largeFile(16<<10)), WithUnicodeNormalization())
+	require.NoError(t, err)
+	compiled, err := ruleset.Compile()
+	require.NoError(t, err)
+	paths := compilePaths(200)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := range paths {
+				path := paths[(i+g*25)%len(paths)]
+				want, err := ruleset.Match(path)
+				assert.NoError(t, err)
+				got, err := compiled.Match(path)
+				assert.NoError(t, err)
+				assert.Same(t, want, got, path)
+				_, err = compiled.MatchSections(path)
+				assert.NoError(t, err)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestCompileRuleWithoutPattern(t *testing.T) {
+	rule, err := NewRule("*.go", nil)
+	require.NoError(t, err)
+	_, err = Ruleset{rule, {}}.Compile()
+	assert.EqualError(t, err, "rule 2 has no pattern")
+}
+
+func benchmarkMatch(b *testing.B, match func(string) (*Rule, error)) {
+	paths := compilePaths(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if _, err := match(paths[i%len(paths)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "files/s")
+}
+
+// The rulesets for the matching benchmarks have around 2,500 rules, most of
+// them anchored to a directory, as in large monorepos.
+
+func BenchmarkRulesetMatch(b *testing.B) {
+	ruleset, err := ParseFile(bytes.NewReader(largeFile(100 << 10)))
+	require.NoError(b, err)
+	benchmarkMatch(b, ruleset.Match)
+}
+
+func BenchmarkCompiledRulesetMatch(b *testing.B) {
+	ruleset, err := ParseFile(bytes.NewReader(largeFile(100 << 10)))
+	require.NoError(b, err)
+	compiled, err := ruleset.Compile()
+	require.NoError(b, err)
+	benchmarkMatch(b, compiled.Match)
+}
+
+func BenchmarkRulesetMatchSections(b *testing.B) {
+	ruleset, err := ParseFile(bytes.NewReader(largeFile(100<<10)), WithUnicodeNormalization())
+	require.NoError(b, err)
+	benchmarkMatch(b, func(path string) (*Rule, error) {
+		_, err := ruleset.MatchSections(path)
+		return nil, err
+	})
+}
+
+func BenchmarkCompiledRulesetMatchSections(b *testing.B) {
+	ruleset, err := ParseFile(bytes.NewReader(largeFile(100<<10)), WithUnicodeNormalization())
+	require.NoError(b, err)
+	compiled, err := ruleset.Compile()
+	require.NoError(b, err)
+	benchmarkMatch(b, func(path string) (*Rule, error) {
+		_, err := compiled.MatchSections(path)
+		return nil, err
+	})
+}
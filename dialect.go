@@ -0,0 +1,203 @@
+package codeowners
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Dialect is a variant of the CODEOWNERS format used by a particular code
+// host. It's chosen with WithDialect.
+type Dialect string
+
+const (
+	// DialectGitHub is GitHub's CODEOWNERS format, along with GitLab's
+	// sections. It's the default.
+	DialectGitHub Dialect = "github"
+	// DialectBitbucket is the format of Code Owners for Bitbucket, which
+	// adds @@@group owners, and directive lines that configure how reviewers
+	// are assigned, such as CODEOWNERS.destination_branch_pattern and
+	// Check(...) lines.
+	DialectBitbucket Dialect = "bitbucket"
+	// DialectGitea is the format used by Gitea and Forgejo, where each
+	// rule's pattern is a regular expression that must match the whole
+	// path, and is inverted by a leading !. Files are owned by the owners of
+	// every rule that matches them, rather than just the last.
+	DialectGitea Dialect = "gitea"
+)
+
+// Dialects lists the dialects WithDialect accepts.
+var Dialects = []Dialect{DialectGitHub, DialectBitbucket, DialectGitea}
+
+// ParseDialect returns the dialect with the given name, such as "bitbucket".
+func ParseDialect(name string) (Dialect, error) {
+	for _, d := range Dialects {
+		if string(d) == name {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("unknown dialect %q", name)
+}
+
+// WithDialect makes ParseFile accept the syntax of a dialect other than
+// GitHub's. Unless WithOwnerMatchers is also passed, the dialect decides
+// which owners are allowed.
+func WithDialect(d Dialect) ParseOption {
+	return func(opts *parseOptions) {
+		opts.dialect = d
+	}
+}
+
+// StandardLocations returns the paths, relative to the repository root, where
+// the dialect's code host looks for a CODEOWNERS file, in the order it looks.
+func (d Dialect) StandardLocations() []string {
+	if d == DialectGitea {
+		return []string{"CODEOWNERS", "docs/CODEOWNERS", ".gitea/CODEOWNERS"}
+	}
+	return []string{"CODEOWNERS", ".github/CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"}
+}
+
+// FindFile loops through the dialect's standard locations for CODEOWNERS
+// files, and returns the first place a CODEOWNERS file is found, or "" if
+// there isn't one. If run from a git repository, all paths are relative to
+// the repository root.
+func (d Dialect) FindFile() string {
+	root, _ := findRepositoryRoot()
+	return findFileAt(root, d.StandardLocations())
+}
+
+var (
+	groupRegexp = regexp.MustCompile(`\A@@@([a-zA-Z0-9\-_\.]+)\z`)
+	// Gitea allows dots in user, organization and team names
+	giteaOwnerRegexp = regexp.MustCompile(`\A@([a-zA-Z0-9\-_\.]+(?:/[a-zA-Z0-9\-_\.]+)?)\z`)
+)
+
+// BitbucketOwnerMatchers is the set of owner matchers for the Bitbucket
+// dialect, which matches groups as well as the default owners.
+var BitbucketOwnerMatchers = append([]OwnerMatcher{OwnerMatchFunc(MatchGroupOwner)}, DefaultOwnerMatchers...)
+
+// MatchGroupOwner matches a Bitbucket group owner, such as @@@reviewers. May be
+// provided to WithOwnerMatchers.
+func MatchGroupOwner(s string) (Owner, error) {
+	match := groupRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return Owner{}, ErrNoMatch
+	}
+
+	return Owner{Value: match[1], Type: GroupOwner}, nil
+}
+
+// GiteaOwnerMatchers is the set of owner matchers for the Gitea dialect. Gitea
+// doesn't support email owners.
+var GiteaOwnerMatchers = []OwnerMatcher{OwnerMatchFunc(MatchGiteaOwner)}
+
+// MatchGiteaOwner matches a Gitea user, such as @alice, or team, such as
+// @org/team, whose names may include dots. May be provided to
+// WithOwnerMatchers.
+func MatchGiteaOwner(s string) (Owner, error) {
+	match := giteaOwnerRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return Owner{}, ErrNoMatch
+	}
+
+	if strings.Contains(match[1], "/") {
+		return Owner{Value: match[1], Type: TeamOwner}, nil
+	}
+	return Owner{Value: match[1], Type: UsernameOwner}, nil
+}
+
+// ownerMatchers returns the owner matchers of a dialect.
+func (d Dialect) ownerMatchers() []OwnerMatcher {
+	switch d {
+	case DialectBitbucket:
+		return BitbucketOwnerMatchers
+	case DialectGitea:
+		return GiteaOwnerMatchers
+	}
+	return DefaultOwnerMatchers
+}
+
+// hasSections reports whether the dialect has GitLab-style sections. In
+// Gitea's, a line starting with [ is a regular expression.
+func (d Dialect) hasSections() bool {
+	return d != DialectGitea
+}
+
+// isDirective reports whether a line, trimmed of whitespace, is one of the
+// dialect's directives, which configure the code host rather than assigning
+// owners. They're treated like comments.
+func (d Dialect) isDirective(line string) bool {
+	if d != DialectBitbucket {
+		return false
+	}
+	return strings.HasPrefix(line, "CODEOWNERS.") || strings.HasPrefix(line, "Check(")
+}
+
+// parseGiteaRule parses a line of a Gitea CODEOWNERS file. As in Gitea, fields
+// are separated by whitespace, a backslash escapes the character after it, and
+// a # starts a comment wherever it appears. Errors are ParseErrors with a
+// column, but no line.
+func parseGiteaRule(ruleStr string, opts parseOptions) (Rule, error) {
+	r := Rule{}
+
+	// Each field is kept as it's written, for the rule's pattern, and with its
+	// escapes removed, along with the column it starts at
+	var raw, text []string
+	var columns []int
+	start := -1
+	escaped := false
+	var buf strings.Builder
+	end := func(i int) {
+		if start >= 0 {
+			raw = append(raw, ruleStr[start:i])
+			text = append(text, buf.String())
+			columns = append(columns, start+1)
+		}
+		start = -1
+		buf.Reset()
+	}
+loop:
+	for i, ch := range ruleStr {
+		switch {
+		case escaped:
+			escaped = false
+			buf.WriteRune(ch)
+		case ch == '\\':
+			escaped = true
+		case ch == '#':
+			end(i)
+			r.Comment = strings.TrimSpace(ruleStr[i+1:])
+			break loop
+		case isWhitespace(ch):
+			end(i)
+			continue
+		default:
+			buf.WriteRune(ch)
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	end(len(ruleStr))
+	if len(raw) == 0 {
+		return r, &ParseError{Message: "unexpected end of rule"}
+	}
+
+	if opts.normalizeUnicode {
+		raw[0], text[0] = norm.NFC.String(raw[0]), norm.NFC.String(text[0])
+	}
+	pattern, err := newGiteaPattern(raw[0], text[0], opts.caseInsensitive)
+	if err != nil {
+		return r, newParseError(err, columns[0])
+	}
+	pattern.nfc = opts.normalizeUnicode
+	r.pattern = pattern
+	for i := 1; i < len(raw); i++ {
+		if err := r.addOwner(text[i], opts); err != nil {
+			return r, newParseError(err, columns[i])
+		}
+	}
+	return r, nil
+}
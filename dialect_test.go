@@ -0,0 +1,122 @@
+package codeowners
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitbucketDialect(t *testing.T) {
+	contents := strings.Join([]string{
+		"CODEOWNERS.destination_branch_pattern main",
+		"CODEOWNERS.toplevel.assignment_routing random 2",
+		"Check(@@@backend >= 2)",
+		"*.go @@@backend @alice",
+		"docs/ docs@example.com",
+		"",
+	}, "\n")
+
+	rules, err := ParseFile(strings.NewReader(contents), WithDialect(DialectBitbucket))
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, []Owner{{Value: "backend", Type: GroupOwner}, {Value: "alice", Type: UsernameOwner}}, rules[0].Owners)
+		assert.Equal(t, 4, rules[0].LineNumber)
+		assert.Equal(t, "@@@backend", rules[0].Owners[0].String())
+	}
+
+	// Directives are kept with the comments
+	rules, err = ParseFile(strings.NewReader(contents), WithDialect(DialectBitbucket), WithComments())
+	assert.NoError(t, err)
+	assert.Equal(t, contents, rules.String())
+
+	// GitHub doesn't have groups or directives
+	_, err = ParseFile(strings.NewReader(contents))
+	assert.EqualError(t, err, "line 1: invalid owner format 'main' at position 39")
+	_, err = ParseFile(strings.NewReader("*.go @@@backend\n"))
+	assert.EqualError(t, err, "line 1: invalid owner format '@@@backend' at position 6")
+}
+
+func TestParseDialect(t *testing.T) {
+	d, err := ParseDialect("bitbucket")
+	assert.NoError(t, err)
+	assert.Equal(t, DialectBitbucket, d)
+
+	_, err = ParseDialect("sourcehut")
+	assert.EqualError(t, err, `unknown dialect "sourcehut"`)
+}
+
+func TestGiteaDialect(t *testing.T) {
+	contents, err := os.ReadFile(filepath.Join("testdata", "dialects", "gitea"))
+	assert.NoError(t, err)
+	rules, err := ParseFile(bytes.NewReader(contents), WithDialect(DialectGitea))
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 4) {
+		assert.Equal(t, `.*\\.go`, rules[0].RawPattern())
+		assert.Equal(t, "This is comment", rules[0].Comment)
+		assert.Equal(t, []Owner{{Value: "org1/team4", Type: TeamOwner}, {Value: "user.name", Type: UsernameOwner}}, rules[3].Owners)
+	}
+
+	// Every matching rule applies, and inverted rules match the paths their
+	// expression doesn't
+	examples := []struct {
+		path   string
+		owners string
+	}{
+		{"main.go", "@user1 @user2 @org1/team3 @user5"},
+		{"frontend/src/app.js", "@org1/team1 @org1/team2 @user3"},
+		{"frontend/src/app.go", "@user1 @user2"},
+		{"frontend/src/app.css", ""},
+		{"docs/aws/setup.md", "@org1/team3 @user5 @org1/team4 @user.name"},
+		{"docs/aws/setup/index.md", "@org1/team3 @user5"},
+		{"main.gox", "@org1/team3 @user5"},
+	}
+	for _, e := range examples {
+		matches, err := rules.MatchSections(e.path)
+		assert.NoError(t, err)
+		var owners []string
+		for _, rule := range matches {
+			for _, o := range rule.Owners {
+				owners = append(owners, o.String())
+			}
+		}
+		assert.Equal(t, e.owners, strings.Join(owners, " "), e.path)
+	}
+
+	// Rules are written back out as they were
+	rules, err = ParseFile(bytes.NewReader(contents), WithDialect(DialectGitea), WithComments())
+	assert.NoError(t, err)
+	assert.Equal(t, string(contents), rules.String())
+
+	_, err = ParseFile(strings.NewReader("[docs/ @alice\n"), WithDialect(DialectGitea))
+	assert.EqualError(t, err, "line 1: error parsing regexp: missing closing ]: `[docs/` at position 1")
+	_, err = ParseFile(strings.NewReader(".* docs@example.com\n"), WithDialect(DialectGitea))
+	assert.EqualError(t, err, "line 1: invalid owner format 'docs@example.com' at position 4")
+}
+
+func TestStandardLocations(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path string) {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		assert.NoError(t, os.WriteFile(full, []byte("* @alice\n"), 0o644))
+	}
+
+	// Gitea looks in .gitea/ last, and not in .github/ at all
+	write(".github/CODEOWNERS")
+	write(".gitea/CODEOWNERS")
+	assert.Equal(t, filepath.Join(dir, ".github", "CODEOWNERS"), findFileAt(dir, DialectGitHub.StandardLocations()))
+	assert.Equal(t, filepath.Join(dir, ".gitea", "CODEOWNERS"), findFileAt(dir, DialectGitea.StandardLocations()))
+
+	write("docs/CODEOWNERS")
+	assert.Equal(t, filepath.Join(dir, ".github", "CODEOWNERS"), findFileAt(dir, DialectGitHub.StandardLocations()))
+	assert.Equal(t, filepath.Join(dir, "docs", "CODEOWNERS"), findFileAt(dir, DialectGitea.StandardLocations()))
+
+	write("CODEOWNERS")
+	for _, d := range Dialects {
+		assert.Equal(t, filepath.Join(dir, "CODEOWNERS"), findFileAt(dir, d.StandardLocations()), d)
+	}
+}
@@ -0,0 +1,82 @@
+package codeowners
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Limits restricts the resources ParseFile will use on a file, for parsing
+// files that can't be trusted. A limit of 0 means there isn't one.
+type Limits struct {
+	// MaxLineLength is the most bytes a line may have, not counting its line
+	// ending.
+	MaxLineLength int
+	// MaxRules is the most rules a file may have, counting each rule a
+	// pattern expands into with WithBraceExpansion.
+	MaxRules int
+}
+
+// WithLimits makes ParseFile return a LimitError, rather than carrying on,
+// once a file goes beyond one of the limits. Without it, lines can be any
+// length and files can have any number of rules.
+func WithLimits(limits Limits) ParseOption {
+	return func(opts *parseOptions) {
+		opts.limits = limits
+	}
+}
+
+// LimitError is returned by ParseFile when a file goes beyond the limits
+// passed with WithLimits. Unlike a ParseError, it stops the file being parsed
+// even with WithAllErrors.
+type LimitError struct {
+	// Line is the number of the line that went beyond the limit, starting at
+	// 1.
+	Line int
+	// Exactly one of MaxLineLength and MaxRules is set, to the limit that
+	// was exceeded.
+	MaxLineLength int
+	MaxRules      int
+}
+
+func (e *LimitError) Error() string {
+	if e.MaxRules > 0 {
+		return fmt.Sprintf("line %d: file has more than %d rules", e.Line, e.MaxRules)
+	}
+	return fmt.Sprintf("line %d: line is longer than %d bytes", e.Line, e.MaxLineLength)
+}
+
+// readLine reads the next line, with its ending, returning io.EOF once there
+// aren't any more. Lines can be any length, but if max is more than 0, it
+// stops reading a line once it's certain to be longer than max bytes without
+// its ending, returning what it's read along with errLineTooLong.
+func readLine(r *bufio.Reader, max int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if line == nil && err != bufio.ErrBufferFull {
+			// Most lines fit in the reader's buffer, so they don't need
+			// copying into line first
+			if err == io.EOF && len(chunk) > 0 {
+				err = nil
+			}
+			return string(chunk), err
+		}
+		line = append(line, chunk...)
+		// The line ending is at most two bytes
+		if max > 0 && len(line) > max+2 {
+			return string(line), errLineTooLong
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		return string(line), err
+	}
+}
+
+// errLineTooLong is returned by readLine for lines longer than its limit.
+var errLineTooLong = errors.New("line too long")
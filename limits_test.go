@@ -0,0 +1,158 @@
+package codeowners
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// manyOwners returns a rule listing n owners, which is a long line for large
+// n.
+func manyOwners(n int) string {
+	var b strings.Builder
+	b.WriteString("*.go")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, " @org/generated-team-%d", i)
+	}
+	return b.String()
+}
+
+// largeFile returns a CODEOWNERS file of at least size bytes, with a section
+// and a mix of patterns every thousand rules.
+func largeFile(size int) []byte {
+	var b bytes.Buffer
+	for i := 0; b.Len() < size; i++ {
+		if i%1000 == 0 {
+			fmt.Fprintf(&b, "\n[Services %d] @org/platform\n", i/1000)
+		}
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&b, "/services/svc-%d/ @org/team-%d @user%d\n", i, i%97, i%13)
+		case 1:
+			fmt.Fprintf(&b, "/services/svc-%d/**/*.go @org/go-team-%d # Go code\n", i, i%31)
+		case 2:
+			fmt.Fprintf(&b, "docs/svc-%d/*.md docs-%d@example.com\n", i, i%7)
+		default:
+			fmt.Fprintf(&b, "/services/svc-%d/config/\n", i)
+		}
+	}
+	return b.Bytes()
+}
+
+func TestParseFileLongLines(t *testing.T) {
+	// Lines can be longer than any buffer, and end any way
+	line := manyOwners(15000)
+	require.Greater(t, len(line), 300000)
+	for _, newline := range []string{"\n", "\r\n"} {
+		for _, ending := range []string{newline, ""} {
+			file := "* @org/team" + newline + line + ending
+			rules, err := ParseFile(strings.NewReader(file))
+			assert.NoError(t, err)
+			if assert.Len(t, rules, 2) {
+				assert.Len(t, rules[1].Owners, 15000)
+				assert.Equal(t, "org/generated-team-14999", rules[1].Owners[14999].Value)
+			}
+
+			rules, err = ParseFile(strings.NewReader(file), WithComments())
+			assert.NoError(t, err)
+			// Comparing the lengths keeps the failure message readable
+			assert.Equal(t, len(file), len(rules.String()))
+			assert.True(t, file == rules.String())
+		}
+	}
+}
+
+func TestParseFileLimits(t *testing.T) {
+	file := "* @org/team\n" + manyOwners(100) + "\n*.md @org/docs\n"
+
+	_, err := ParseFile(strings.NewReader(file), WithLimits(Limits{MaxLineLength: 1000}))
+	var limitErr *LimitError
+	if assert.True(t, errors.As(err, &limitErr)) {
+		assert.Equal(t, &LimitError{Line: 2, MaxLineLength: 1000}, limitErr)
+	}
+	assert.EqualError(t, err, "line 2: line is longer than 1000 bytes")
+
+	// Lines as long as the limit are fine, however they end
+	limit := len(manyOwners(100))
+	for _, f := range []string{file, strings.ReplaceAll(file, "\n", "\r\n")} {
+		rules, err := ParseFile(strings.NewReader(f), WithLimits(Limits{MaxLineLength: limit}))
+		assert.NoError(t, err)
+		assert.Len(t, rules, 3)
+	}
+
+	// The rules limit stops parsing even when reporting every error, and
+	// counts the rules patterns expand into
+	_, err = ParseFile(strings.NewReader(file), WithLimits(Limits{MaxRules: 2}), WithAllErrors())
+	assert.EqualError(t, err, "line 3: file has more than 2 rules")
+	_, err = ParseFile(strings.NewReader("*.{go,md} @org/team\n"), WithLimits(Limits{MaxRules: 1}), WithBraceExpansion())
+	assert.Equal(t, &LimitError{Line: 1, MaxRules: 1}, err)
+	rules, err := ParseFile(strings.NewReader(file), WithLimits(Limits{MaxRules: 3}))
+	assert.NoError(t, err)
+	assert.Len(t, rules, 3)
+}
+
+func TestReadLine(t *testing.T) {
+	examples := []struct {
+		name  string
+		input string
+		max   int
+		lines []string
+		err   error
+	}{
+		{"short lines", "a\nb\r\nc", 0, []string{"a\n", "b\r\n", "c"}, nil},
+		{"lines longer than the buffer", strings.Repeat("a", 40) + "\nb\n", 0, []string{strings.Repeat("a", 40) + "\n", "b\n"}, nil},
+		{"lines at the limit", strings.Repeat("a", 40) + "\r\n", 40, []string{strings.Repeat("a", 40) + "\r\n"}, nil},
+		{"lines over the limit", strings.Repeat("a", 40) + "\n", 30, nil, errLineTooLong},
+		{"empty", "", 0, nil, nil},
+	}
+	for _, e := range examples {
+		t.Run(e.name, func(t *testing.T) {
+			r := bufio.NewReaderSize(strings.NewReader(e.input), 16)
+			var lines []string
+			for {
+				line, err := readLine(r, e.max)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					assert.Equal(t, e.err, err)
+					return
+				}
+				lines = append(lines, line)
+			}
+			assert.Nil(t, e.err)
+			assert.Equal(t, e.lines, lines)
+		})
+	}
+}
+
+func BenchmarkParseFileLarge(b *testing.B) {
+	contents := largeFile(40 << 20)
+	b.SetBytes(int64(len(contents)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseFile(bytes.NewReader(contents)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseFileLongLine(b *testing.B) {
+	contents := []byte(manyOwners(15000) + "\n")
+	b.SetBytes(int64(len(contents)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseFile(bytes.NewReader(contents)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package codeowners
 
 import (
 	"fmt"
-	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type pattern struct {
@@ -12,16 +15,29 @@ type pattern struct {
 	regex               *regexp.Regexp
 	regexPrefix         string
 	leftAnchoredLiteral bool
+	// gitea is set for Gitea-style regular expressions, and inverted for
+	// ones with a leading !, which match the paths the expression doesn't.
+	gitea    bool
+	inverted bool
+	// nfc converts paths to NFC before they're matched, as the pattern was
+	// with WithUnicodeNormalization.
+	nfc bool
+	// foldCase lowercases paths before they're matched, and matches the
+	// regex ignoring case, for WithCaseInsensitive.
+	foldCase bool
 }
 
-// newPattern creates a new pattern struct from a gitignore-style pattern string
-func newPattern(patternStr string) (pattern, error) {
-	pat := pattern{pattern: patternStr}
+// newPattern creates a new pattern struct from a gitignore-style pattern
+// string, which ignores case if foldCase is set.
+func newPattern(patternStr string, foldCase bool) (pattern, error) {
+	pat := pattern{pattern: patternStr, foldCase: foldCase}
 
-	if !strings.ContainsAny(patternStr, "*?\\") && patternStr[0] == '/' {
+	// Literal patterns are compared as they're written, so patterns ignoring
+	// case always use the regex
+	if !foldCase && !strings.ContainsAny(patternStr, "*?[\\") && patternStr[0] == '/' {
 		pat.leftAnchoredLiteral = true
 	} else {
-		patternRegex, err := buildPatternRegex(patternStr)
+		patternRegex, err := buildPatternRegex(patternStr, foldCase)
 		if err != nil {
 			return pattern{}, err
 		}
@@ -30,8 +46,224 @@ func newPattern(patternStr string) (pattern, error) {
 		// reject non-matching paths with a string comparison before paying for
 		// a full (backtracking) regex evaluation.
 		pat.regexPrefix = literalPrefix(patternStr)
+		if foldCase {
+			// Only the ASCII part of the prefix can be compared with a folded
+			// path, as other letters can match letters that lowercase
+			// differently, such as σ and ς
+			prefix := pat.regexPrefix
+			for i := 0; i < len(prefix); i++ {
+				if prefix[i] >= utf8.RuneSelf {
+					prefix = prefix[:i]
+					break
+				}
+			}
+			pat.regexPrefix = strings.ToLower(prefix)
+		}
+	}
+
+	return pat, nil
+}
+
+// patternSyntaxError is a problem with the syntax of a pattern, at an offset
+// in bytes from its start.
+type patternSyntaxError struct {
+	offset int
+	msg    string
+}
+
+func (e *patternSyntaxError) Error() string {
+	return e.msg
+}
+
+// checkPatternSyntax checks that a gitignore-style pattern is valid syntax.
+// Character classes, such as [abc], must be closed within the path segment
+// they start in, and only name the POSIX classes git knows about.
+func checkPatternSyntax(patternStr string) *patternSyntaxError {
+	for i := 0; i < len(patternStr); i++ {
+		switch patternStr[i] {
+		case '\\':
+			i++
+		case '[':
+			_, end, err := parseBracketExpr(patternStr, i)
+			if err != nil {
+				return err
+			}
+			if end < 0 {
+				return &patternSyntaxError{offset: i, msg: "unterminated character class"}
+			}
+			i = end
+		}
+	}
+	return nil
+}
+
+// bracketExpr is a character class in a pattern, such as [a-z] or [!0-9].
+type bracketExpr struct {
+	negated bool
+	// ranges holds the characters in the class, with single characters as
+	// ranges of one.
+	ranges [][2]rune
+}
+
+// posixClasses holds the characters in each of the POSIX classes that can be
+// named in a bracket expression, such as [[:digit:]].
+var posixClasses = map[string][][2]rune{
+	"alnum":  {{'0', '9'}, {'A', 'Z'}, {'a', 'z'}},
+	"alpha":  {{'A', 'Z'}, {'a', 'z'}},
+	"blank":  {{' ', ' '}, {'\t', '\t'}},
+	"cntrl":  {{0x00, 0x1f}, {0x7f, 0x7f}},
+	"digit":  {{'0', '9'}},
+	"graph":  {{0x21, 0x7e}},
+	"lower":  {{'a', 'z'}},
+	"print":  {{0x20, 0x7e}},
+	"punct":  {{0x21, 0x2f}, {0x3a, 0x40}, {0x5b, 0x60}, {0x7b, 0x7e}},
+	"space":  {{'\t', '\r'}, {' ', ' '}},
+	"upper":  {{'A', 'Z'}},
+	"xdigit": {{'0', '9'}, {'A', 'F'}, {'a', 'f'}},
+}
+
+// parseBracketExpr parses the character class starting with the [ at start,
+// as git's wildmatch does. A leading ! or ^ negates it, a ] straight after
+// that is part of the class rather than closing it, a - between two
+// characters makes a range, backslashes escape, and [:name:] includes a POSIX
+// class. It returns the index of the ] closing the class, or -1 if it isn't
+// closed before the end of its path segment.
+func parseBracketExpr(patternStr string, start int) (bracketExpr, int, *patternSyntaxError) {
+	var expr bracketExpr
+	i := start + 1
+	if i < len(patternStr) && (patternStr[i] == '!' || patternStr[i] == '^') {
+		expr.negated = true
+		i++
+	}
+	// next decodes the character at i, or reports that the segment has
+	// ended
+	next := func() (rune, int, bool) {
+		if i >= len(patternStr) || patternStr[i] == '/' {
+			return 0, 0, false
+		}
+		ch, size := utf8.DecodeRuneInString(patternStr[i:])
+		return ch, size, true
 	}
 
+	// prev is the last single character, which may start a range, or -1
+	prev := rune(-1)
+	for first := true; ; first = false {
+		ch, size, ok := next()
+		switch {
+		case !ok:
+			return expr, -1, nil
+		case ch == ']' && !first:
+			return expr, i, nil
+		case ch == '\\':
+			i += size
+			if ch, size, ok = next(); !ok {
+				return expr, -1, nil
+			}
+			expr.ranges = append(expr.ranges, [2]rune{ch, ch})
+			prev = ch
+		case ch == '-' && prev >= 0 && i+1 < len(patternStr) && patternStr[i+1] != ']':
+			i += size
+			hi, size, ok := next()
+			if ok && hi == '\\' {
+				i += size
+				hi, size, ok = next()
+			}
+			if !ok {
+				return expr, -1, nil
+			}
+			// As in git, a backwards range matches nothing
+			if prev <= hi {
+				expr.ranges = append(expr.ranges, [2]rune{prev, hi})
+			}
+			prev = -1
+			i += size
+			continue
+		case ch == '[' && strings.HasPrefix(patternStr[i:], "[:"):
+			rest := patternStr[i+2:]
+			if slash := strings.IndexByte(rest, '/'); slash >= 0 {
+				rest = rest[:slash]
+			}
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return expr, -1, nil
+			}
+			if end == 0 || rest[end-1] != ':' {
+				// Without a closing :], the [ is just a character
+				expr.ranges = append(expr.ranges, [2]rune{'[', '['})
+				prev = '['
+				break
+			}
+			name := rest[:end-1]
+			ranges, ok := posixClasses[name]
+			if !ok {
+				return expr, -1, &patternSyntaxError{offset: i, msg: fmt.Sprintf("unknown character class [:%s:]", name)}
+			}
+			expr.ranges = append(expr.ranges, ranges...)
+			prev = -1
+			i += len("[:") + end + len("]")
+			continue
+		default:
+			expr.ranges = append(expr.ranges, [2]rune{ch, ch})
+			prev = ch
+		}
+		i += size
+	}
+}
+
+// regex returns a regular expression matching a single character in the
+// class. As with git, a class never matches a slash, even if it's negated or
+// in one of its ranges.
+func (e bracketExpr) regex() string {
+	var re strings.Builder
+	re.WriteString("[")
+	if e.negated {
+		re.WriteString("^/")
+	}
+	writeRange := func(lo, hi rune) {
+		if lo > hi {
+			return
+		}
+		fmt.Fprintf(&re, `\x{%x}`, lo)
+		if hi > lo {
+			fmt.Fprintf(&re, `-\x{%x}`, hi)
+		}
+	}
+	n := re.Len()
+	for _, r := range e.ranges {
+		if e.negated || r[0] > '/' || r[1] < '/' {
+			writeRange(r[0], r[1])
+			continue
+		}
+		writeRange(r[0], '/'-1)
+		writeRange('/'+1, r[1])
+	}
+	if re.Len() == n && !e.negated {
+		// An empty class matches nothing
+		return `[^\x00-\x{10ffff}]`
+	}
+	re.WriteString("]")
+	return re.String()
+}
+
+// newGiteaPattern creates a pattern from a Gitea-style regular expression,
+// given as it's written and with its escapes removed. As in Gitea, the
+// expression must match the whole path, and a leading ! inverts it.
+func newGiteaPattern(raw, expr string, foldCase bool) (pattern, error) {
+	pat := pattern{pattern: raw, gitea: true, foldCase: foldCase}
+	if strings.HasPrefix(expr, "!") {
+		pat.inverted = true
+		expr = expr[1:]
+	}
+	// Compiling the expression on its own first keeps it as written in any
+	// error
+	if _, err := regexp.Compile(expr); err != nil {
+		return pattern{}, err
+	}
+	flags := ""
+	if foldCase {
+		flags = "(?i)"
+	}
+	pat.regex = regexp.MustCompile(flags + "^" + expr + "$")
 	return pat, nil
 }
 
@@ -48,21 +280,135 @@ func literalPrefix(patternStr string) string {
 	if patternStr[0] != '/' {
 		return ""
 	}
-	// Everything up to the first wildcard or escape is literal path text that a
-	// matching path must contain as a prefix. Stopping at '\' keeps us safe
-	// around escaped wildcards without having to interpret the escape.
+	// Everything up to the first wildcard, character class or escape is
+	// literal path text that a matching path must contain as a prefix.
+	// Stopping at '\' keeps us safe around escaped wildcards without having to
+	// interpret the escape.
 	s := patternStr[1:]
-	if i := strings.IndexAny(s, "*?\\"); i >= 0 {
+	if i := strings.IndexAny(s, "*?[\\"); i >= 0 {
 		s = s[:i]
 	}
 	return s
 }
 
+// matchDir tests if the directory provided matches the pattern, as
+// Rule.MatchDir describes.
+func (p pattern) matchDir(dir string) (bool, error) {
+	dir, err := slashPath(dir, isWindows)
+	if err != nil {
+		return false, err
+	}
+	dir = strings.TrimSuffix(dir, "/")
+	if dir != "" {
+		if match, err := p.match(dir); match || err != nil {
+			return match, err
+		}
+	}
+
+	// Patterns that match a path with a trailing slash match every path
+	// beneath it too, as nothing in them can match a slash except the .* of
+	// a trailing ** or the descendant paths any other last segment matches
+	if match, err := p.match(dir + "/"); match || err != nil {
+		return match, err
+	}
+
+	// The exception is a trailing **/*, as in docs/**/* or a lone *, which
+	// matches every path beneath a directory matching what comes before it,
+	// but not the directory with a trailing slash. As * matches any name,
+	// checking a single path beneath the directory is enough.
+	if p.gitea {
+		return false, nil
+	}
+	pattern := p.pattern
+	if pattern == "*" || pattern == "**/*" || strings.HasSuffix(pattern, "/**/*") {
+		child := "x"
+		if dir != "" {
+			child = dir + "/x"
+		}
+		return p.match(child)
+	}
+	return false, nil
+}
+
+// isWindows is set when paths use backslashes as separators, and can start
+// with a drive letter or be UNC paths.
+const isWindows = runtime.GOOS == "windows"
+
+// slashPath converts a path to be matched to use forward slashes, as patterns
+// do, if windows is set. Paths with a drive letter, such as C:\src, and UNC
+// paths, such as \\server\share\src, can't be relative to the root of the
+// repository, so they're an error rather than never matching.
+func slashPath(path string, windows bool) (string, error) {
+	if !windows {
+		return path, nil
+	}
+	slashed := strings.ReplaceAll(path, `\`, "/")
+	hasDrive := len(slashed) >= 2 && slashed[1] == ':' && (slashed[0] >= 'A' && slashed[0] <= 'Z' || slashed[0] >= 'a' && slashed[0] <= 'z')
+	if hasDrive || strings.HasPrefix(slashed, "//") {
+		return "", fmt.Errorf("path %s isn't relative to the repository root", path)
+	}
+	return slashed, nil
+}
+
+// foldPath lowercases a path for patterns ignoring case. The long s, ſ, is
+// the only lowercase letter that matches an ASCII letter ignoring case, so
+// it's replaced by s, letting the path be compared with the ASCII part of a
+// pattern byte for byte.
+func foldPath(path string) string {
+	path = strings.ToLower(path)
+	if strings.Contains(path, "ſ") {
+		path = strings.ReplaceAll(path, "ſ", "s")
+	}
+	return path
+}
+
+// pathForm is a way of converting paths before they're matched, as a set of
+// flags, so that the forms can index an array.
+type pathForm int
+
+const (
+	formNFC pathForm = 1 << iota
+	formFolded
+	numPathForms = 1 << iota
+)
+
+// form returns the form paths must be converted to before they're matched
+// against the pattern.
+func (p pattern) form() pathForm {
+	var form pathForm
+	if p.nfc {
+		form |= formNFC
+	}
+	if p.foldCase {
+		form |= formFolded
+	}
+	return form
+}
+
+// convert converts a path with forward slashes into the form.
+func (form pathForm) convert(path string) string {
+	if form&formNFC != 0 {
+		path = norm.NFC.String(path)
+	}
+	if form&formFolded != 0 {
+		path = foldPath(path)
+	}
+	return path
+}
+
 // match tests if the path provided matches the pattern
 func (p pattern) match(testPath string) (bool, error) {
 	// Normalize Windows-style path separators to forward slashes
-	testPath = filepath.ToSlash(testPath)
+	testPath, err := slashPath(testPath, isWindows)
+	if err != nil {
+		return false, err
+	}
+	return p.matchConverted(p.form().convert(testPath)), nil
+}
 
+// matchConverted tests if a path that's already been converted to the
+// pattern's form matches the pattern.
+func (p pattern) matchConverted(testPath string) bool {
 	if p.leftAnchoredLiteral {
 		prefix := p.pattern
 
@@ -73,34 +419,38 @@ func (p pattern) match(testPath string) (bool, error) {
 
 		// If the pattern ends with a slash we can do a simple prefix match
 		if prefix[len(prefix)-1] == '/' {
-			return strings.HasPrefix(testPath, prefix), nil
+			return strings.HasPrefix(testPath, prefix)
 		}
 
 		// If the strings are the same length, check for an exact match
 		if len(testPath) == len(prefix) {
-			return testPath == prefix, nil
+			return testPath == prefix
 		}
 
 		// Otherwise check if the test path is a subdirectory of the pattern
 		if len(testPath) > len(prefix) && testPath[len(prefix)] == '/' {
-			return testPath[:len(prefix)] == prefix, nil
+			return testPath[:len(prefix)] == prefix
 		}
 
 		// Otherwise the test path must be shorter than the pattern, so it can't match
-		return false, nil
+		return false
 	}
 
 	// Cheap rejection: if the regex requires a literal prefix the path doesn't
 	// have, it cannot match, so skip the expensive regex evaluation entirely.
 	if p.regexPrefix != "" && !strings.HasPrefix(testPath, p.regexPrefix) {
-		return false, nil
+		return false
 	}
 
-	return p.regex.MatchString(testPath), nil
+	return p.regex.MatchString(testPath) != p.inverted
 }
 
-// buildPatternRegex compiles a new regexp object from a gitignore-style pattern string
-func buildPatternRegex(pattern string) (*regexp.Regexp, error) {
+// buildPatternRegex compiles a new regexp object from a gitignore-style pattern
+// string, which ignores case if foldCase is set. Matching the regex ignoring
+// case, rather than lowercasing the pattern, folds character classes and
+// escaped characters along with everything else, so [A-Z] matches a and \A
+// matches a.
+func buildPatternRegex(pattern string, foldCase bool) (*regexp.Regexp, error) {
 	// Handle specific edge cases first
 	switch {
 	case strings.Contains(pattern, "***"):
@@ -137,6 +487,9 @@ func buildPatternRegex(pattern string) (*regexp.Regexp, error) {
 	lastSegIndex := len(segs) - 1
 	needSlash := false
 	var re strings.Builder
+	if foldCase {
+		re.WriteString(`(?i)`)
+	}
 	re.WriteString(`\A`)
 	for i, seg := range segs {
 		switch seg {
@@ -172,30 +525,7 @@ func buildPatternRegex(pattern string) (*regexp.Regexp, error) {
 				re.WriteString(sep)
 			}
 
-			escape := false
-			for _, ch := range seg {
-				if escape {
-					escape = false
-					re.WriteString(regexp.QuoteMeta(string(ch)))
-					continue
-				}
-
-				// Other pathspec implementations handle character classes here (e.g.
-				// [AaBb]), but CODEOWNERS doesn't support that so we don't need to
-				switch ch {
-				case '\\':
-					escape = true
-				case '*':
-					// Multi-character wildcard
-					re.WriteString(`[^` + sep + `]*`)
-				case '?':
-					// Single-character wildcard
-					re.WriteString(`[^` + sep + `]`)
-				default:
-					// Regular character
-					re.WriteString(regexp.QuoteMeta(string(ch)))
-				}
-			}
+			re.WriteString(segmentRegex(seg))
 
 			if i == lastSegIndex {
 				// As there's no trailing slash (that'd hit the '**' case), we
@@ -209,3 +539,44 @@ func buildPatternRegex(pattern string) (*regexp.Regexp, error) {
 	re.WriteString(`\z`)
 	return regexp.Compile(re.String())
 }
+
+// segmentRegex converts a single pattern segment, which isn't * or **, into a
+// regular expression.
+func segmentRegex(seg string) string {
+	var re strings.Builder
+	escape := false
+	for i := 0; i < len(seg); {
+		ch, size := utf8.DecodeRuneInString(seg[i:])
+		if escape {
+			escape = false
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+			i += size
+			continue
+		}
+
+		switch ch {
+		case '\\':
+			escape = true
+		case '*':
+			// Multi-character wildcard
+			re.WriteString(`[^/]*`)
+		case '?':
+			// Single-character wildcard
+			re.WriteString(`[^/]`)
+		case '[':
+			// Character class. With WithLenientPatterns, one that isn't
+			// valid is matched literally.
+			if expr, end, err := parseBracketExpr(seg, i); end >= 0 && err == nil {
+				re.WriteString(expr.regex())
+				i = end + 1
+				continue
+			}
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+		default:
+			// Regular character
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+		i += size
+	}
+	return re.String()
+}
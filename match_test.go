@@ -18,6 +18,29 @@ type patternTest struct {
 	Focus   bool            `json:"focus"`
 }
 
+// TestMatchBracketExpressions checks character classes against the results
+// git check-ignore gives for the same patterns in a .gitignore file, which are
+// recorded in testdata/brackets.json.
+func TestMatchBracketExpressions(t *testing.T) {
+	data, err := os.ReadFile("testdata/brackets.json")
+	require.NoError(t, err)
+	var tests []patternTest
+	require.NoError(t, json.Unmarshal(data, &tests))
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			require.Nil(t, checkPatternSyntax(test.Pattern))
+			pattern, err := newPattern(test.Pattern, false)
+			require.NoError(t, err)
+			for path, shouldMatch := range test.Paths {
+				actual, err := pattern.match(path)
+				require.NoError(t, err)
+				assert.Equal(t, shouldMatch, actual, "pattern %s, path %s", test.Pattern, path)
+			}
+		})
+	}
+}
+
 func TestMatch(t *testing.T) {
 	data, err := os.ReadFile("testdata/patterns.json")
 	require.NoError(t, err)
@@ -40,7 +63,7 @@ func TestMatch(t *testing.T) {
 
 		t.Run(test.Name, func(t *testing.T) {
 			for path, shouldMatch := range test.Paths {
-				pattern, err := newPattern(test.Pattern)
+				pattern, err := newPattern(test.Pattern, false)
 				require.NoError(t, err)
 
 				// Debugging tips:
@@ -60,6 +83,40 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func TestSlashPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		windows bool
+		want    string
+		err     string
+	}{
+		{`src\main.go`, true, "src/main.go", ""},
+		{`src/lib\util.go`, true, "src/lib/util.go", ""},
+		{`src\main.go`, false, `src\main.go`, ""},
+		{"src/main.go", true, "src/main.go", ""},
+		// Paths that can't be relative to the repository
+		{`C:\src\main.go`, true, "", `path C:\src\main.go isn't relative to the repository root`},
+		{`c:src\main.go`, true, "", `path c:src\main.go isn't relative to the repository root`},
+		{"D:/src/main.go", true, "", "path D:/src/main.go isn't relative to the repository root"},
+		{`\\server\share\src\main.go`, true, "", `path \\server\share\src\main.go isn't relative to the repository root`},
+		{"//server/share/main.go", true, "", "path //server/share/main.go isn't relative to the repository root"},
+		// Elsewhere, they're ordinary relative paths
+		{`C:\src\main.go`, false, `C:\src\main.go`, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := slashPath(test.path, test.windows)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
 func TestLiteralPrefix(t *testing.T) {
 	tests := []struct {
 		pattern string
@@ -7,21 +7,57 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
-type parseOption func(*parseOptions)
+// ParseOption is an option for ParseFile, LoadFile and
+// LoadFileFromStandardLocation, made by one of the With functions. Without any,
+// a file is parsed as GitHub parses it, stopping at the first error.
+type ParseOption func(*parseOptions)
 
+// parseOptions holds the settings ParseOptions change.
 type parseOptions struct {
 	ownerMatchers []OwnerMatcher
+	allErrors     bool
+	comments      bool
+	negation      bool
+	lenientOwners bool
+	// lenientPatterns skips checking the syntax of patterns.
+	lenientPatterns bool
+	braceExpansion  bool
+	// normalizeUnicode converts patterns, and the paths they're matched
+	// against, to NFC.
+	normalizeUnicode bool
+	caseInsensitive  bool
+	limits           Limits
+	dialect          Dialect
+	// ownerValidators are called with each owner that's parsed.
+	ownerValidators []func(Owner) error
 }
 
-func WithOwnerMatchers(mm []OwnerMatcher) parseOption {
+// WithOwnerMatchers replaces the owner matchers that decide which owners are
+// valid, which are otherwise DefaultOwnerMatchers, or those of the dialect
+// passed with WithDialect.
+func WithOwnerMatchers(mm []OwnerMatcher) ParseOption {
 	return func(opts *parseOptions) {
 		opts.ownerMatchers = mm
 	}
 }
 
+// WithAllErrors makes ParseFile carry on past lines it can't parse, rather
+// than stopping at the first. The rules on the other lines are returned along
+// with a ParseErrors listing every problem.
+func WithAllErrors() ParseOption {
+	return func(opts *parseOptions) {
+		opts.allErrors = true
+	}
+}
+
 type OwnerMatcher interface {
 	// Matches give string agains a pattern e.g. a regexp.
 	// Should return ErrNoMatch if the pattern doesn't match.
@@ -38,10 +74,202 @@ func (err ErrInvalidOwnerFormat) Error() string {
 
 var ErrNoMatch = errors.New("no match")
 
+// ParseError describes a problem with a line of a CODEOWNERS file. ParseFile
+// and LoadFile return it, possibly wrapped, when a file can't be parsed, so it
+// can be found with errors.As.
+type ParseError struct {
+	// Line is the number of the line with the problem, starting at 1.
+	Line int
+	// Column is the position of the problem in the line, in bytes starting at
+	// 1, or 0 if it doesn't have one, such as for a rule missing a pattern.
+	Column int
+	// Source is the line, as it appears in the file.
+	Source string
+	// Message describes the problem, without its position.
+	Message string
+	// Err is the underlying error, such as an ErrInvalidOwnerFormat, if
+	// there is one.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	msg := e.Message
+	if e.Column > 0 {
+		msg = fmt.Sprintf("%s at position %d", msg, e.Column)
+	}
+	if e.Line > 0 {
+		msg = fmt.Sprintf("line %d: %s", e.Line, msg)
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// WithComments makes ParseFile keep the lines that aren't rules, such as
+// comments, blank lines and section headers, in the Leading field of the rule
+// that follows them, or the Trailing field of the last rule. Rules also
+// remember how they were written, so that an unmodified ruleset is written
+// back out exactly as it was parsed.
+func WithComments() ParseOption {
+	return func(opts *parseOptions) {
+		opts.comments = true
+	}
+}
+
+// WithNegation makes ParseFile accept rules whose pattern starts with !, such
+// as !docs/internal/, which exclude the paths they match from the rules before
+// them. They can't have owners, and a path whose last matching rule is negated
+// is unowned, unless a later rule matches it again.
+func WithNegation() ParseOption {
+	return func(opts *parseOptions) {
+		opts.negation = true
+	}
+}
+
+// WithStrictOwners makes ParseFile return a ParseError for any owner that none
+// of the owner matchers accept, such as backend-team without an @. This is the
+// default, so it's only needed to undo WithLenientOwners.
+func WithStrictOwners() ParseOption {
+	return func(opts *parseOptions) {
+		opts.lenientOwners = false
+	}
+}
+
+// WithLenientOwners makes ParseFile accept owners that none of the owner
+// matchers accept, as GitHub does, which ignores them. They're left out of the
+// rule's Owners, so a rule with no valid owners leaves the paths it matches
+// unowned, and listed in its InvalidOwners instead, so that they can be
+// reported. Invalid default owners of sections are skipped.
+func WithLenientOwners() ParseOption {
+	return func(opts *parseOptions) {
+		opts.lenientOwners = true
+	}
+}
+
+// WithLenientPatterns makes ParseFile accept patterns that aren't valid
+// syntax, such as src/[abc with a character class that's never closed, rather
+// than returning a ParseError. As on GitHub, the parts that aren't valid are
+// matched literally, so src/[abc only matches a path named src/[abc, and the
+// paths beneath it. Patterns that can't be matched at all, such as those with
+// three consecutive asterisks or ending in an escape, are still errors.
+func WithLenientPatterns() ParseOption {
+	return func(opts *parseOptions) {
+		opts.lenientPatterns = true
+	}
+}
+
+// WithUnicodeNormalization makes the rules ParseFile returns convert their
+// patterns, and the paths they're matched against, to Unicode's NFC form
+// before comparing them. Accented letters can be written composed, as a single
+// character, or decomposed, as a letter followed by a combining mark, and
+// macOS returns file names decomposed while most editors write them composed,
+// so without it a rule for docs/útil/ wouldn't match the path of that
+// directory as it's read from a Mac's filesystem. RawPattern returns the
+// pattern in NFC.
+func WithUnicodeNormalization() ParseOption {
+	return func(opts *parseOptions) {
+		opts.normalizeUnicode = true
+	}
+}
+
+// WithCaseInsensitive makes the rules ParseFile returns match paths ignoring
+// case, so that *.md matches README.MD, as on filesystems that don't tell
+// README.md and readme.md apart. Both the pattern and the path are lowercased,
+// with character classes and escaped characters folded too, so [A-Z]* matches
+// readme and \R matches r. Without it, patterns are case-sensitive, as they
+// are on GitHub. RawPattern returns the pattern as it was written.
+func WithCaseInsensitive() ParseOption {
+	return func(opts *parseOptions) {
+		opts.caseInsensitive = true
+	}
+}
+
+// WithOwnerValidator makes ParseFile call validate with each owner it parses,
+// including the default owners of sections, so that policies such as only
+// allowing teams can be enforced. An error returned by validate is returned as
+// a ParseError for the owner's line and column, or with WithAllErrors, in the
+// ParseErrors. It may be passed more than once, to call several validators.
+func WithOwnerValidator(validate func(o Owner) error) ParseOption {
+	return func(opts *parseOptions) {
+		opts.ownerValidators = append(opts.ownerValidators, validate)
+	}
+}
+
+// validateOwner calls the validators with an owner, returning the first error.
+func (opts parseOptions) validateOwner(o Owner) error {
+	for _, validate := range opts.ownerValidators {
+		if err := validate(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseErrors is returned by ParseFile with the WithAllErrors option when any
+// lines can't be parsed. It lists the problems in the order of their lines.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns each of the errors, for errors.Is and errors.As.
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// patternColumn returns the column a rule's pattern starts at, after any !.
+func patternColumn(r Rule) int {
+	if r.Negated {
+		return 2
+	}
+	return 1
+}
+
+// parsePattern builds the pattern of a rule, which starts at a column of the
+// line. Its syntax is checked first, unless WithLenientPatterns was passed.
+func parsePattern(s string, column int, opts parseOptions) (pattern, error) {
+	if !opts.lenientPatterns {
+		if err := checkPatternSyntax(s); err != nil {
+			return pattern{}, newParseError(err, column+err.offset)
+		}
+	}
+	if opts.normalizeUnicode {
+		s = norm.NFC.String(s)
+	}
+	p, err := newPattern(s, opts.caseInsensitive)
+	if err != nil {
+		return pattern{}, newParseError(err, 0)
+	}
+	p.nfc = opts.normalizeUnicode
+	return p, nil
+}
+
+// newParseError returns a ParseError for an underlying error at a column of a
+// rule.
+func newParseError(err error, column int) *ParseError {
+	return &ParseError{Column: column, Message: err.Error(), Err: err}
+}
+
 var (
 	emailRegexp    = regexp.MustCompile(`\A[A-Z0-9a-z\._%\+\-]+@[A-Za-z0-9\.\-]+\.[A-Za-z]{2,6}\z`)
 	teamRegexp     = regexp.MustCompile(`\A@([a-zA-Z0-9\-]+\/[a-zA-Z0-9_\-]+)\z`)
 	usernameRegexp = regexp.MustCompile(`\A@([a-zA-Z0-9\-_]+)\z`)
+
+	// sectionRegexp matches GitLab-style section headers, such as [Docs] or
+	// ^[Docs][2] @docs-team, capturing the optional marker, the section name
+	// and the number of approvals required.
+	sectionRegexp = regexp.MustCompile(`\A(\^?)\[([^\]]+)\](?:\[(\d+)\])?(?:\s|\z)`)
 )
 
 // DefaultOwnerMatchers is the default set of owner matchers, which includes the
@@ -95,51 +323,219 @@ func MatchUsernameOwner(s string) (Owner, error) {
 	return Owner{Value: match[1], Type: UsernameOwner}, nil
 }
 
-// ParseFile parses a CODEOWNERS file, returning a set of rules.
-// To override the default owner matchers, pass WithOwnerMatchers() as an option.
-func ParseFile(f io.Reader, options ...parseOption) (Ruleset, error) {
-	opts := parseOptions{ownerMatchers: DefaultOwnerMatchers}
+// newParseOptions applies options to the defaults, which parse GitHub's
+// dialect.
+func newParseOptions(options []ParseOption) parseOptions {
+	opts := parseOptions{dialect: DialectGitHub}
 	for _, opt := range options {
 		opt(&opts)
 	}
+	if opts.ownerMatchers == nil {
+		opts.ownerMatchers = opts.dialect.ownerMatchers()
+	}
+	return opts
+}
+
+// ParseFile parses a CODEOWNERS file, returning a set of rules.
+// To override the default owner matchers, pass WithOwnerMatchers() as an option,
+// and to parse another code host's dialect, WithDialect().
+// It stops at the first line it can't parse, returning a ParseError, unless
+// WithAllErrors() is passed.
+func ParseFile(f io.Reader, options ...ParseOption) (Ruleset, error) {
+	opts := newParseOptions(options)
 
 	rules := Ruleset{}
-	scanner := bufio.NewScanner(f)
+	var errs ParseErrors
+	reader := bufio.NewReader(f)
+	maxLine := opts.limits.MaxLineLength
 	lineNo := 0
-	for scanner.Scan() {
+	var header sectionHeader
+	// leading collects the lines that aren't rules for WithComments, newline
+	// is the line ending the file uses, and bom records whether it starts
+	// with a byte order mark
+	var leading []string
+	newline, lastEnding := "", ""
+	bom := false
+	for {
+		source, readErr := readLine(reader, maxLine)
+		if readErr == io.EOF {
+			break
+		}
 		lineNo++
-		line := strings.TrimSpace(scanner.Text())
+		if readErr == errLineTooLong {
+			return nil, &LimitError{Line: lineNo, MaxLineLength: maxLine}
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+		if lineNo == 1 && strings.HasPrefix(source, byteOrderMark) {
+			source = source[len(byteOrderMark):]
+			bom = true
+		}
+		source, lastEnding = splitLineEnding(source)
+		if maxLine > 0 && len(source) > maxLine {
+			return nil, &LimitError{Line: lineNo, MaxLineLength: maxLine}
+		}
+		if newline == "" {
+			newline = lastEnding
+		}
+		line := trimLine(source)
 
-		// Ignore blank lines and comments
-		if len(line) == 0 || line[0] == '#' {
+		// Ignore blank lines and comments, and directives, which don't
+		// affect ownership
+		if len(line) == 0 || line[0] == '#' || opts.dialect.isDirective(line) {
+			if opts.comments {
+				leading = append(leading, source)
+			}
 			continue
 		}
 
-		rule, err := parseRule(line, opts)
+		// Section headers apply to the rules that follow them, which inherit
+		// the section's default owners if they don't list any
+		var rule Rule
+		var err error
+		if match := sectionRegexp.FindStringSubmatch(line); match != nil && opts.dialect.hasSections() {
+			var h sectionHeader
+			if h, err = parseSectionHeader(line, match, opts); err == nil {
+				header = h
+				if opts.comments {
+					leading = append(leading, source)
+				}
+				continue
+			}
+		} else if opts.dialect == DialectGitea {
+			rule, err = parseGiteaRule(line, opts)
+		} else {
+			rule, err = parseRule(line, opts)
+		}
+		expanded := []Rule{rule}
+		if err == nil && opts.braceExpansion && opts.dialect != DialectGitea {
+			expanded, err = expandRule(rule, opts)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				parseErr = newParseError(err, 0)
+			}
+			parseErr.Line = lineNo
+			parseErr.Source = source
+			// Columns are relative to the line once it's been trimmed
+			if parseErr.Column > 0 {
+				parseErr.Column += strings.Index(source, line)
+			}
+			if !opts.allErrors {
+				return nil, parseErr
+			}
+			errs = append(errs, parseErr)
+			// The line is kept, so that it isn't lost when the file is
+			// written back out
+			if opts.comments {
+				leading = append(leading, source)
+			}
+			continue
+		}
+		for i := range expanded {
+			rule := &expanded[i]
+			rule.LineNumber = lineNo
+			rule.Section = header.name
+			rule.Optional = header.optional
+			rule.MinApprovals = header.approvals
+			rule.SectionOwners = header.owners
+			// A rule that only lists invalid owners still lists owners, so it
+			// doesn't inherit the section's
+			if len(rule.Owners) == 0 && len(rule.InvalidOwners) == 0 && len(header.owners) > 0 && !rule.Negated {
+				rule.Owners = append([]Owner(nil), header.owners...)
+				rule.InheritsOwners = true
+			}
+			if opts.comments {
+				rule.Leading = leading
+				rule.source = source
+				rule.parsedText = rule.text()
+				leading = nil
+			}
+		}
+		rules = append(rules, expanded...)
+		if max := opts.limits.MaxRules; max > 0 && len(rules) > max {
+			return nil, &LimitError{Line: lineNo, MaxRules: max}
 		}
-		rule.LineNumber = lineNo
-		rules = append(rules, rule)
+	}
+	if opts.comments && len(rules) > 0 {
+		if newline == "" {
+			newline = "\n"
+		}
+		for i := range rules {
+			rules[i].newline = newline
+		}
+		last := &rules[len(rules)-1]
+		last.Trailing = leading
+		last.noFinalNewline = lastEnding == ""
+		rules[0].bom = bom
+	}
+	if len(errs) > 0 {
+		return rules, errs
 	}
 	return rules, nil
 }
 
+// byteOrderMark is the UTF-8 encoding of U+FEFF, which editors on Windows
+// sometimes put at the start of a file. It's ignored.
+const byteOrderMark = "\ufeff"
+
+// splitLineEnding splits a line from readLine into its contents
+// and its ending: "\n", "\r\n", or "" for a final line without one.
+func splitLineEnding(line string) (string, string) {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2], "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
+
+// trimLine trims the whitespace around a line, other than whitespace at the
+// end that's escaped by a backslash, which is part of the rule's pattern.
+func trimLine(line string) string {
+	line = strings.TrimLeftFunc(line, unicode.IsSpace)
+	trimmed := strings.TrimRightFunc(line, unicode.IsSpace)
+	if len(trimmed) < len(line) && strings.HasSuffix(trimmed, "\\") {
+		// Only an odd number of backslashes escapes what follows
+		n := len(trimmed) - len(strings.TrimRight(trimmed, "\\"))
+		if n%2 == 1 {
+			_, size := utf8.DecodeRuneInString(line[len(trimmed):])
+			trimmed = line[:len(trimmed)+size]
+		}
+	}
+	return trimmed
+}
+
+// isEscaped reports whether the byte at index i of a line is escaped, by an
+// odd number of backslashes before it.
+func isEscaped(line string, i int) bool {
+	n := i - len(strings.TrimRight(line[:i], "\\"))
+	return n%2 == 1
+}
+
 const (
 	statePattern = iota + 1
 	stateOwners
 )
 
-// parseRule parses a single line of a CODEOWNERS file, returning a Rule struct
+// parseRule parses a single line of a CODEOWNERS file, returning a Rule struct.
+// Errors are ParseErrors with a column, but no line.
 func parseRule(ruleStr string, opts parseOptions) (Rule, error) {
 	r := Rule{}
 
 	state := statePattern
 	escaped := false
 	buf := bytes.Buffer{}
-	for i, ch := range strings.TrimSpace(ruleStr) {
-		// Comments consume the rest of the line and stop further parsing
-		if ch == '#' {
+	ruleStr = trimLine(ruleStr)
+	for i, ch := range ruleStr {
+		// Comments consume the rest of the line and stop further parsing. A #
+		// only starts one at the start of a field, after whitespace that
+		// isn't escaped, so it can appear later in a pattern, or at the start
+		// of one if it's escaped
+		if ch == '#' && !escaped && (i == 0 || isWhitespace(rune(ruleStr[i-1])) && !isEscaped(ruleStr, i-1)) {
 			r.Comment = strings.TrimSpace(ruleStr[i+1:])
 			break
 		}
@@ -147,7 +543,11 @@ func parseRule(ruleStr string, opts parseOptions) (Rule, error) {
 		switch state {
 		case statePattern:
 			switch {
-			case ch == '\\':
+			case ch == '!' && i == 0 && opts.negation:
+				r.Negated = true
+				continue
+
+			case ch == '\\' && !escaped:
 				// Escape the next character (important for whitespace while parsing), but
 				// don't lose the backslash as it's part of the pattern
 				escaped = true
@@ -156,7 +556,7 @@ func parseRule(ruleStr string, opts parseOptions) (Rule, error) {
 
 			case isWhitespace(ch) && !escaped:
 				// Unescaped whitespace means this is the end of the pattern
-				pattern, err := newPattern(buf.String())
+				pattern, err := parsePattern(buf.String(), patternColumn(r), opts)
 				if err != nil {
 					return r, err
 				}
@@ -164,12 +564,22 @@ func parseRule(ruleStr string, opts parseOptions) (Rule, error) {
 				buf.Reset()
 				state = stateOwners
 
-			case isPatternChar(ch) || (isWhitespace(ch) && escaped):
-				// Keep any valid pattern characters and escaped whitespace
+			case ch == '!' && i == 0 && !escaped:
+				// Without WithNegation, a leading ! is an error rather than
+				// part of the pattern, so that it isn't mistaken for one
+				return r, &ParseError{Column: i + 1, Message: fmt.Sprintf("unexpected character '%c'", ch)}
+
+			case isPatternChar(ch) || ch == '#' || (isWhitespace(ch) && escaped):
+				// Keep any valid pattern characters, #s that don't start a
+				// comment, and escaped whitespace
+				buf.WriteRune(ch)
+
+			case ch == ',' && opts.braceExpansion:
+				// Commas separate the alternatives in braces
 				buf.WriteRune(ch)
 
 			default:
-				return r, fmt.Errorf("unexpected character '%c' at position %d", ch, i+1)
+				return r, &ParseError{Column: i + 1, Message: fmt.Sprintf("unexpected character '%c'", ch)}
 			}
 			// Escaping only applies to one character
 			escaped = false
@@ -181,11 +591,9 @@ func parseRule(ruleStr string, opts parseOptions) (Rule, error) {
 				// through whitespace before or after owner declarations
 				if buf.Len() > 0 {
 					ownerStr := buf.String()
-					owner, err := newOwner(ownerStr, opts.ownerMatchers)
-					if err != nil {
-						return r, fmt.Errorf("%w at position %d", err, i+1-len(ownerStr))
+					if err := r.addOwner(ownerStr, opts); err != nil {
+						return r, newParseError(err, i+1-len(ownerStr))
 					}
-					r.Owners = append(r.Owners, owner)
 					buf.Reset()
 				}
 
@@ -194,7 +602,7 @@ func parseRule(ruleStr string, opts parseOptions) (Rule, error) {
 				buf.WriteRune(ch)
 
 			default:
-				return r, fmt.Errorf("unexpected character '%c' at position %d", ch, i+1)
+				return r, &ParseError{Column: i + 1, Message: fmt.Sprintf("unexpected character '%c'", ch)}
 			}
 		}
 	}
@@ -204,10 +612,13 @@ func parseRule(ruleStr string, opts parseOptions) (Rule, error) {
 	switch state {
 	case statePattern:
 		if buf.Len() == 0 { // We should have non-empty pattern
-			return r, fmt.Errorf("unexpected end of rule")
+			return r, &ParseError{Message: "unexpected end of rule"}
+		}
+		if escaped {
+			return r, &ParseError{Column: len(ruleStr), Message: "unterminated escape at end of pattern"}
 		}
 
-		pattern, err := newPattern(buf.String())
+		pattern, err := parsePattern(buf.String(), patternColumn(r), opts)
 		if err != nil {
 			return r, err
 		}
@@ -217,17 +628,94 @@ func parseRule(ruleStr string, opts parseOptions) (Rule, error) {
 		// If there's an owner left in the buffer, don't leave it behind
 		if buf.Len() > 0 {
 			ownerStr := buf.String()
-			owner, err := newOwner(ownerStr, opts.ownerMatchers)
-			if err != nil {
-				return r, fmt.Errorf("%s at position %d", err.Error(), len(ruleStr)+1-len(ownerStr))
+			if err := r.addOwner(ownerStr, opts); err != nil {
+				return r, newParseError(err, len(ruleStr)+1-len(ownerStr))
 			}
-			r.Owners = append(r.Owners, owner)
 		}
 	}
 
+	if r.Negated && len(r.Owners) > 0 {
+		return r, &ParseError{Column: 1, Message: "negated pattern can't have owners"}
+	}
 	return r, nil
 }
 
+// sectionHeader is a GitLab-style section header, which applies to the rules
+// that follow it.
+type sectionHeader struct {
+	name      string
+	optional  bool
+	approvals int
+	owners    []Owner
+}
+
+// parseSectionHeader parses a section header, given the line and its match of
+// sectionRegexp. Anything after the name and the number of approvals, other
+// than a comment, is taken to be the section's default owners. Errors are
+// ParseErrors with a column, but no line.
+func parseSectionHeader(line string, match []string, opts parseOptions) (sectionHeader, error) {
+	h := sectionHeader{name: strings.TrimSpace(match[2]), optional: match[1] != ""}
+	if match[3] != "" {
+		approvals, err := strconv.Atoi(match[3])
+		if err != nil {
+			// The count is in brackets after the name's
+			return h, &ParseError{Column: len(match[1]) + len(match[2]) + 4, Message: fmt.Sprintf("invalid number of approvals %s", match[3]), Err: err}
+		}
+		h.approvals = approvals
+	}
+
+	offset := len(match[0])
+	rest := line[offset:]
+	if i := strings.IndexByte(rest, '#'); i >= 0 {
+		rest = rest[:i]
+	}
+	for i := 0; i < len(rest); {
+		if isWhitespace(rune(rest[i])) {
+			i++
+			continue
+		}
+		end := strings.IndexFunc(rest[i:], isWhitespace)
+		if end < 0 {
+			end = len(rest) - i
+		}
+		owner, err := newOwner(rest[i:i+end], opts.ownerMatchers)
+		if err == nil {
+			err = opts.validateOwner(owner)
+		}
+		var formatErr ErrInvalidOwnerFormat
+		switch {
+		case opts.lenientOwners && errors.As(err, &formatErr):
+			// As with GitHub, invalid owners are ignored
+		case err != nil:
+			return h, newParseError(err, offset+i+1)
+		default:
+			h.owners = append(h.owners, owner)
+		}
+		i += end
+	}
+	return h, nil
+}
+
+// addOwner parses an owner of the rule and adds it to its owners. With
+// WithLenientOwners, owners that none of the owner matchers accept are added to
+// its InvalidOwners instead of being an error.
+func (r *Rule) addOwner(s string, opts parseOptions) error {
+	owner, err := newOwner(s, opts.ownerMatchers)
+	var formatErr ErrInvalidOwnerFormat
+	if opts.lenientOwners && errors.As(err, &formatErr) {
+		r.InvalidOwners = append(r.InvalidOwners, s)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := opts.validateOwner(owner); err != nil {
+		return err
+	}
+	r.Owners = append(r.Owners, owner)
+	return nil
+}
+
 // newOwner figures out which kind of owner this is and returns an Owner struct
 func newOwner(s string, mm []OwnerMatcher) (Owner, error) {
 	for _, m := range mm {
@@ -254,13 +742,15 @@ func isAlphanumeric(ch rune) bool {
 	return (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9')
 }
 
-// isPatternChar matches characters that are allowed in patterns
+// isPatternChar matches characters that are allowed in patterns. Any
+// printable non-ASCII character is allowed, so that paths can be written in
+// any language.
 func isPatternChar(ch rune) bool {
 	switch ch {
-	case '*', '?', '.', '/', '@', '_', '+', '-', '\\', '(', ')', '|', '{', '}':
+	case '*', '?', '.', '/', '@', '_', '+', '-', '\\', '(', ')', '|', '{', '}', '[', ']', '!', '^', ':':
 		return true
 	}
-	return isAlphanumeric(ch)
+	return isAlphanumeric(ch) || ch > unicode.MaxASCII && unicode.IsGraphic(ch) && !unicode.IsSpace(ch)
 }
 
 // isOwnersChar matches characters that are allowed in owner definitions
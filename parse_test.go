@@ -1,6 +1,11 @@
 package codeowners
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -63,6 +68,53 @@ func TestParseFile(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:     "sections",
+			contents: "file.txt @user\n[Docs]\n*.md @org/docs\n^[Backend][2] @org/team\n*.go @org/team\n",
+			expected: Ruleset{
+				{
+					pattern:    mustBuildPattern(t, "file.txt"),
+					Owners:     []Owner{{Value: "user", Type: "username"}},
+					LineNumber: 1,
+				},
+				{
+					pattern:    mustBuildPattern(t, "*.md"),
+					Owners:     []Owner{{Value: "org/docs", Type: "team"}},
+					LineNumber: 3,
+					Section:    "Docs",
+				},
+				{
+					pattern:       mustBuildPattern(t, "*.go"),
+					Owners:        []Owner{{Value: "org/team", Type: "team"}},
+					LineNumber:    5,
+					Section:       "Backend",
+					Optional:      true,
+					MinApprovals:  2,
+					SectionOwners: []Owner{{Value: "org/team", Type: "team"}},
+				},
+			},
+		},
+		{
+			name:     "section default owners",
+			contents: "[Docs] @org/docs @alice # docs\n*.md\nREADME.md @bob\n",
+			expected: Ruleset{
+				{
+					pattern:        mustBuildPattern(t, "*.md"),
+					Owners:         []Owner{{Value: "org/docs", Type: "team"}, {Value: "alice", Type: "username"}},
+					LineNumber:     2,
+					Section:        "Docs",
+					SectionOwners:  []Owner{{Value: "org/docs", Type: "team"}, {Value: "alice", Type: "username"}},
+					InheritsOwners: true,
+				},
+				{
+					pattern:       mustBuildPattern(t, "README.md"),
+					Owners:        []Owner{{Value: "bob", Type: "username"}},
+					LineNumber:    3,
+					Section:       "Docs",
+					SectionOwners: []Owner{{Value: "org/docs", Type: "team"}, {Value: "alice", Type: "username"}},
+				},
+			},
+		},
 
 		// Error cases
 		{
@@ -70,6 +122,16 @@ func TestParseFile(t *testing.T) {
 			contents: "malformed rule\n",
 			err:      "line 1: invalid owner format 'rule' at position 11",
 		},
+		{
+			name:     "invalid section approvals",
+			contents: "[Docs][99999999999999999999] @org/docs\n",
+			err:      "line 1: invalid number of approvals 99999999999999999999 at position 8",
+		},
+		{
+			name:     "invalid section owner",
+			contents: "[Docs] @org/docs bad\n",
+			err:      "line 1: invalid owner format 'bad' at position 18",
+		},
 	}
 
 	for _, e := range examples {
@@ -111,6 +173,22 @@ func TestParseRule(t *testing.T) {
 				Owners:  []Owner{{Value: "org/team", Type: "team"}},
 			},
 		},
+		{
+			name: "patterns with brackets",
+			rule: "file.[cC] @user",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "file.[cC]"),
+				Owners:  []Owner{{Value: "user", Type: "username"}},
+			},
+		},
+		{
+			name: "patterns with POSIX character classes",
+			rule: "v[[:digit:]]*/ @user",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "v[[:digit:]]*/"),
+				Owners:  []Owner{{Value: "user", Type: "username"}},
+			},
+		},
 		{
 			name: "team owners file with parentheses",
 			rule: "file(1).txt @org/team",
@@ -163,6 +241,29 @@ func TestParseRule(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "tab-separated owners",
+			rule: "file.txt\t\t@user\t@org/team \t# owners\t",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "file.txt"),
+				Owners: []Owner{
+					{Value: "user", Type: "username"},
+					{Value: "org/team", Type: "team"},
+				},
+				Comment: "owners",
+			},
+		},
+		{
+			name: "trailing whitespace after owners",
+			rule: "file.txt @user @org/team \t  ",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "file.txt"),
+				Owners: []Owner{
+					{Value: "user", Type: "username"},
+					{Value: "org/team", Type: "team"},
+				},
+			},
+		},
 		{
 			name: "complex patterns",
 			rule: "d?r/* @user",
@@ -179,6 +280,37 @@ func TestParseRule(t *testing.T) {
 				Owners:  []Owner{{Value: "user", Type: "username"}},
 			},
 		},
+		{
+			name: "pattern with multiple escaped spaces",
+			rule: "docs/getting\\ started\\ guide.md @org/docs",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "docs/getting\\ started\\ guide.md"),
+				Owners:  []Owner{{Value: "org/docs", Type: "team"}},
+			},
+		},
+		{
+			name: "pattern with escaped spaces next to wildcards",
+			rule: "*\\ notes/\\ *.md @user",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "*\\ notes/\\ *.md"),
+				Owners:  []Owner{{Value: "user", Type: "username"}},
+			},
+		},
+		{
+			name: "pattern with escaped trailing space",
+			rule: "notes\\  ",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "notes\\ "),
+			},
+		},
+		{
+			name: "pattern with escaped backslash before a space",
+			rule: "notes\\\\ @user",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "notes\\\\"),
+				Owners:  []Owner{{Value: "user", Type: "username"}},
+			},
+		},
 		{
 			name: "comments",
 			rule: "file.txt @user # some comment",
@@ -188,6 +320,58 @@ func TestParseRule(t *testing.T) {
 				Comment: "some comment",
 			},
 		},
+		{
+			name: "comment after owners without a space",
+			rule: "file.txt @user #comment",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "file.txt"),
+				Owners:  []Owner{{Value: "user", Type: "username"}},
+				Comment: "comment",
+			},
+		},
+		{
+			name: "comment straight after the pattern",
+			rule: "docs/ # no owners yet",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "docs/"),
+				Comment: "no owners yet",
+			},
+		},
+		{
+			name: "pattern containing #",
+			rule: "issue#12.md @user # see #12",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "issue#12.md"),
+				Owners:  []Owner{{Value: "user", Type: "username"}},
+				Comment: "see #12",
+			},
+		},
+		{
+			name: "pattern starting with an escaped #",
+			rule: "\\#notes.md @user #notes",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "\\#notes.md"),
+				Owners:  []Owner{{Value: "user", Type: "username"}},
+				Comment: "notes",
+			},
+		},
+		{
+			name: "pattern with # after an escaped space",
+			rule: "docs/a\\ #b.md @user #notes",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "docs/a\\ #b.md"),
+				Owners:  []Owner{{Value: "user", Type: "username"}},
+				Comment: "notes",
+			},
+		},
+		{
+			name: "pattern with # after an escaped backslash",
+			rule: "docs/a\\\\ #b.md",
+			expected: Rule{
+				pattern: mustBuildPattern(t, "docs/a\\\\"),
+				Comment: "b.md",
+			},
+		},
 		{
 			name: "pattern with no owners",
 			rule: "pattern",
@@ -281,9 +465,39 @@ func TestParseRule(t *testing.T) {
 			err:  "unexpected end of rule",
 		},
 		{
-			name: "patterns with brackets",
-			rule: "file.[cC] @user",
-			err:  "unexpected character '[' at position 6",
+			name: "pattern with trailing backslash",
+			rule: "docs\\",
+			err:  "unterminated escape at end of pattern at position 5",
+		},
+		{
+			name: "unknown POSIX character class",
+			rule: "[[:word:]] @user",
+			err:  "unknown character class [:word:] at position 2",
+		},
+		{
+			name: "character class left open at a slash",
+			rule: "/src/[a/b] @user",
+			err:  "unterminated character class at position 6",
+		},
+		{
+			name: "character class with only a closing bracket",
+			rule: "[]",
+			err:  "unterminated character class at position 1",
+		},
+		{
+			name: "negated character class left open",
+			rule: "*.[!ch @user",
+			err:  "unterminated character class at position 3",
+		},
+		{
+			name: "owner containing #",
+			rule: "file.txt @user#comment",
+			err:  "unexpected character '#' at position 15",
+		},
+		{
+			name: "patterns with unterminated brackets",
+			rule: "file.[cC @user",
+			err:  "unterminated character class at position 6",
 		},
 		{
 			name: "malformed owners",
@@ -337,9 +551,405 @@ func TestParseRule(t *testing.T) {
 }
 
 func mustBuildPattern(t *testing.T, pat string) pattern {
-	p, err := newPattern(pat)
+	p, err := newPattern(pat, false)
 	if err != nil {
 		t.Fatal(err)
 	}
 	return p
 }
+
+func TestParseError(t *testing.T) {
+	_, err := ParseFile(strings.NewReader("*.go @org/team\n\n  docs/ @user bad-owner\n"))
+	var parseErr *ParseError
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, 3, parseErr.Line)
+		// Columns count the indentation that's trimmed before parsing
+		assert.Equal(t, 15, parseErr.Column)
+		assert.Equal(t, "  docs/ @user bad-owner", parseErr.Source)
+		assert.Equal(t, "invalid owner format 'bad-owner'", parseErr.Message)
+	}
+	var ownerErr ErrInvalidOwnerFormat
+	if assert.ErrorAs(t, err, &ownerErr) {
+		assert.Equal(t, "bad-owner", ownerErr.Owner)
+	}
+	assert.EqualError(t, err, "line 3: invalid owner format 'bad-owner' at position 15")
+
+	_, err = ParseFile(strings.NewReader("a***b @user\n"))
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, 1, parseErr.Line)
+		assert.Equal(t, 0, parseErr.Column)
+		assert.Equal(t, "pattern cannot contain three consecutive asterisks", parseErr.Message)
+	}
+	assert.EqualError(t, err, "line 1: pattern cannot contain three consecutive asterisks")
+}
+
+func TestParseFileWithAllErrors(t *testing.T) {
+	contents := "*.go @org/team\nbad owner\n*.md @org/docs\nfile.[ch @user\n"
+	_, err := ParseFile(strings.NewReader(contents))
+	assert.EqualError(t, err, "line 2: invalid owner format 'owner' at position 5")
+
+	rules, err := ParseFile(strings.NewReader(contents), WithAllErrors())
+	var errs ParseErrors
+	if assert.ErrorAs(t, err, &errs) {
+		assert.Len(t, errs, 2)
+		assert.Equal(t, 2, errs[0].Line)
+		assert.Equal(t, 4, errs[1].Line)
+		assert.Len(t, errs.Unwrap(), 2)
+	}
+	assert.EqualError(t, err, "line 2: invalid owner format 'owner' at position 5\nline 4: unterminated character class at position 6")
+	// The rules on the other lines are still returned
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, 1, rules[0].LineNumber)
+		assert.Equal(t, 3, rules[1].LineNumber)
+	}
+
+	rules, err = ParseFile(strings.NewReader("*.go @org/team\n"), WithAllErrors())
+	assert.NoError(t, err)
+	assert.Len(t, rules, 1)
+}
+
+func TestParseFileWithComments(t *testing.T) {
+	contents := "# Owners\n\n*.go @org/team # Go\n[Docs]\n# Docs team\n*.md @org/docs\n\n# The end\n"
+	rules, err := ParseFile(strings.NewReader(contents), WithComments())
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, []string{"# Owners", ""}, rules[0].Leading)
+		assert.Equal(t, "Go", rules[0].Comment)
+		assert.Equal(t, []string{"[Docs]", "# Docs team"}, rules[1].Leading)
+		assert.Equal(t, []string{"", "# The end"}, rules[1].Trailing)
+	}
+
+	// Lines that can't be parsed are kept too, with WithAllErrors
+	withError := "*.go @org/team\nbad owner\n*.md @org/docs\n"
+	rules, err = ParseFile(strings.NewReader(withError), WithComments(), WithAllErrors())
+	assert.Error(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, []string{"bad owner"}, rules[1].Leading)
+	}
+	var buf bytes.Buffer
+	_, err = rules.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, withError, buf.String())
+
+	// Without the option, nothing but the rules is kept
+	rules, err = ParseFile(strings.NewReader(contents))
+	assert.NoError(t, err)
+	assert.Nil(t, rules[0].Leading)
+	assert.Nil(t, rules[1].Trailing)
+}
+
+func TestParseFileWithCommentsIsLossless(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "codeowners", "*"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, paths)
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			contents, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			rules, err := ParseFile(bytes.NewReader(contents), WithComments())
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			_, err = rules.WriteTo(&buf)
+			assert.NoError(t, err)
+			assert.Equal(t, string(contents), buf.String())
+		})
+	}
+}
+
+func TestParseFileDefaultOptions(t *testing.T) {
+	// Parsing without options is the same as asking for the defaults
+	// explicitly, and isn't changed by options that only undo others
+	defaults := []ParseOption{
+		WithDialect(DialectGitHub),
+		WithOwnerMatchers(DefaultOwnerMatchers),
+		WithLenientOwners(),
+		WithStrictOwners(),
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "codeowners", "*"))
+	assert.NoError(t, err)
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			contents, err := os.ReadFile(file)
+			assert.NoError(t, err)
+			want, err := ParseFile(bytes.NewReader(contents))
+			assert.NoError(t, err)
+			got, err := ParseFile(bytes.NewReader(contents), defaults...)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+			assert.Equal(t, want.String(), got.String())
+		})
+	}
+
+	// Each option only changes what it's meant to
+	input := "* @alice\n!docs/\n"
+	_, err = ParseFile(strings.NewReader(input))
+	assert.EqualError(t, err, "line 2: unexpected character '!' at position 1")
+	_, err = ParseFile(strings.NewReader(input), WithLenientOwners(), WithComments())
+	assert.EqualError(t, err, "line 2: unexpected character '!' at position 1")
+	rules, err := ParseFile(strings.NewReader(input), WithNegation())
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+}
+
+func TestParseFileNegation(t *testing.T) {
+	rules, err := ParseFile(strings.NewReader("[Docs] @org/docs\n*.md\n  !internal/*.md # not yet\n"), WithNegation())
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		// Negated rules don't inherit their section's owners
+		assert.True(t, rules[0].InheritsOwners)
+		assert.False(t, rules[1].InheritsOwners)
+		assert.True(t, rules[1].Negated)
+		assert.Empty(t, rules[1].Owners)
+		assert.Equal(t, "!internal/*.md", rules[1].RawPattern())
+		assert.Equal(t, "not yet", rules[1].Comment)
+	}
+	assert.Equal(t, "[Docs] @org/docs\n*.md\n!internal/*.md # not yet\n", rules.String())
+
+	_, err = ParseFile(strings.NewReader("!docs/ @alice\n"), WithNegation())
+	assert.EqualError(t, err, "line 1: negated pattern can't have owners at position 1")
+	_, err = ParseFile(strings.NewReader("docs/ @alice\n!\n"), WithNegation())
+	assert.EqualError(t, err, "line 2: unexpected end of rule")
+}
+
+func TestParseFileLenientOwners(t *testing.T) {
+	input := "*.go backend-team @org/go\n[Docs] docs-team @org/docs\n*.md\n/api/ api-team # TODO\n"
+	_, err := ParseFile(strings.NewReader(input))
+	assert.EqualError(t, err, "line 1: invalid owner format 'backend-team' at position 6")
+	_, err = ParseFile(strings.NewReader(input), WithLenientOwners(), WithStrictOwners())
+	assert.EqualError(t, err, "line 1: invalid owner format 'backend-team' at position 6")
+
+	rules, err := ParseFile(strings.NewReader(input), WithLenientOwners())
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 3) {
+		assert.Equal(t, []Owner{{Value: "org/go", Type: TeamOwner}}, rules[0].Owners)
+		assert.Equal(t, []string{"backend-team"}, rules[0].InvalidOwners)
+		// Invalid default owners of sections are skipped
+		assert.Equal(t, []Owner{{Value: "org/docs", Type: TeamOwner}}, rules[1].Owners)
+		assert.True(t, rules[1].InheritsOwners)
+		// A rule that only lists invalid owners leaves its paths unowned
+		assert.Empty(t, rules[2].Owners)
+		assert.False(t, rules[2].InheritsOwners)
+		assert.Equal(t, []string{"api-team"}, rules[2].InvalidOwners)
+	}
+	assert.Equal(t, "*.go @org/go backend-team\n[Docs] @org/docs\n*.md\n/api/ api-team # TODO\n", rules.String())
+
+	// Owners that are invalid for other reasons are still errors
+	_, err = ParseFile(strings.NewReader("*.go @alice#x\n"), WithLenientOwners())
+	assert.Error(t, err)
+}
+
+func TestParseFileOwnerValidator(t *testing.T) {
+	teamsOnly := WithOwnerValidator(func(o Owner) error {
+		if o.Type != TeamOwner {
+			return fmt.Errorf("owner %s isn't a team", o)
+		}
+		return nil
+	})
+	_, err := ParseFile(strings.NewReader("*.go @org/go\n*.md @org/docs @alice\n"), teamsOnly)
+	assert.EqualError(t, err, "line 2: owner @alice isn't a team at position 16")
+
+	// Section default owners are validated too, and every problem is reported
+	// with WithAllErrors
+	_, err = ParseFile(strings.NewReader("[Docs] @bob\n*.md\n*.go @org/go @carol\n"), teamsOnly, WithAllErrors())
+	assert.EqualError(t, err, "line 1: owner @bob isn't a team at position 8\nline 3: owner @carol isn't a team at position 14")
+
+	// Validators are called in turn, until one returns an error
+	var seen []string
+	record := WithOwnerValidator(func(o Owner) error {
+		seen = append(seen, o.String())
+		return nil
+	})
+	_, err = ParseFile(strings.NewReader("* @org/team @alice\n"), teamsOnly, record)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"@org/team"}, seen)
+}
+
+func TestParseFileLenientPatterns(t *testing.T) {
+	_, err := ParseFile(strings.NewReader("* @org/team\nsrc/[abc @alice\n"))
+	assert.EqualError(t, err, "line 2: unterminated character class at position 5")
+
+	// With the literal fallback, the bracket is just another character
+	rules, err := ParseFile(strings.NewReader("* @org/team\nsrc/[abc @alice\n"), WithLenientPatterns())
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 2) {
+		assert.Equal(t, "src/[abc", rules[1].RawPattern())
+		for path, want := range map[string]bool{
+			"src/[abc":         true,
+			"src/[abc/main.go": true,
+			"src/a":            false,
+			"src/abc":          false,
+		} {
+			match, err := rules[1].Match(path)
+			assert.NoError(t, err)
+			assert.Equal(t, want, match, path)
+		}
+	}
+
+	// Patterns that can't be matched at all are still errors
+	_, err = ParseFile(strings.NewReader("a/***/b @alice\n"), WithLenientPatterns())
+	assert.EqualError(t, err, "line 1: pattern cannot contain three consecutive asterisks")
+}
+
+func TestParseFileUnicodeNormalization(t *testing.T) {
+	composed, decomposed := "docs/\u00fatil/", "docs/u\u0301til/"
+	examples := []struct {
+		name, rule, path string
+	}{
+		{"composed pattern", composed + " @org/docs", decomposed + "index.md"},
+		{"decomposed pattern", decomposed + " @org/docs", composed + "index.md"},
+		{"unanchored pattern", "\u00fatil/ @org/docs", decomposed + "index.md"},
+		{"character class", "docs/[\u00fa\u00f9]til/ @org/docs", decomposed + "index.md"},
+	}
+	for _, e := range examples {
+		t.Run(e.name, func(t *testing.T) {
+			rules, err := ParseFile(strings.NewReader(e.rule + "\n"))
+			assert.NoError(t, err)
+			match, err := rules[0].Match(e.path)
+			assert.NoError(t, err)
+			assert.False(t, match)
+
+			rules, err = ParseFile(strings.NewReader(e.rule+"\n"), WithUnicodeNormalization())
+			assert.NoError(t, err)
+			match, err = rules[0].Match(e.path)
+			assert.NoError(t, err)
+			assert.True(t, match)
+		})
+	}
+
+	rules, err := ParseFile(strings.NewReader(decomposed+" @org/docs\n"), WithUnicodeNormalization())
+	assert.NoError(t, err)
+	assert.Equal(t, composed, rules[0].RawPattern())
+
+	rules, err = ParseFile(strings.NewReader("docs/\u00fatil/.* @org/docs\n"), WithDialect(DialectGitea), WithUnicodeNormalization())
+	assert.NoError(t, err)
+	match, err := rules[0].Match(decomposed + "index.md")
+	assert.NoError(t, err)
+	assert.True(t, match)
+}
+
+func TestParseFileCaseInsensitive(t *testing.T) {
+	examples := []struct {
+		name, rule, path string
+		// sensitive and insensitive are whether the path matches without
+		// and with WithCaseInsensitive
+		sensitive, insensitive bool
+	}{
+		{"extension", "*.md", "docs/README.MD", false, true},
+		{"literal", "/docs/Guide.md", "DOCS/guide.md", false, true},
+		{"anchored directory", "/Docs/", "docs/index.md", false, true},
+		{"same case", "/docs/", "docs/index.md", true, true},
+		{"character class", "[A-Z]*.go", "main.go", false, true},
+		{"posix class", "[[:upper:]]*.go", "main.go", false, true},
+		{"negated class", "[!a-z]*.txt", "A.txt", true, false},
+		{"escaped character", `\R*`, "readme", false, true},
+		{"non-ASCII", "\u00daTIL/", "docs/\u00fatil/index.md", false, true},
+		{"long s", "/Src/*.go", "\u017frc/main.go", false, true},
+		{"final sigma", "/docs/\u03c2/", "DOCS/\u03a3/index.md", false, true},
+		{"different name", "*.md", "README.txt", false, false},
+	}
+	for _, e := range examples {
+		t.Run(e.name, func(t *testing.T) {
+			rules, err := ParseFile(strings.NewReader(e.rule + " @org/docs\n"))
+			assert.NoError(t, err)
+			match, err := rules[0].Match(e.path)
+			assert.NoError(t, err)
+			assert.Equal(t, e.sensitive, match)
+
+			rules, err = ParseFile(strings.NewReader(e.rule+" @org/docs\n"), WithCaseInsensitive())
+			assert.NoError(t, err)
+			match, err = rules[0].Match(e.path)
+			assert.NoError(t, err)
+			assert.Equal(t, e.insensitive, match)
+			assert.Equal(t, e.rule, rules[0].RawPattern())
+		})
+	}
+
+	rules, err := ParseFile(strings.NewReader("docs/.*\\.MD @org/docs\n"), WithDialect(DialectGitea), WithCaseInsensitive())
+	assert.NoError(t, err)
+	match, err := rules[0].Match("Docs/index.md")
+	assert.NoError(t, err)
+	assert.True(t, match)
+}
+
+func TestParseFileTabAligned(t *testing.T) {
+	contents, err := os.ReadFile(filepath.Join("testdata", "codeowners", "tab-aligned"))
+	assert.NoError(t, err)
+	rules, err := ParseFile(bytes.NewReader(contents))
+	assert.NoError(t, err)
+
+	// Runs of tabs and spaces are single separators, and trailing whitespace
+	// is ignored
+	spaced := regexp.MustCompile(`[ \t]+`).ReplaceAllString(string(contents), " ")
+	expected, err := ParseFile(strings.NewReader(strings.ReplaceAll(spaced, " \n", "\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, rules)
+	if assert.Len(t, rules, 7) {
+		assert.Equal(t, "/scripts/\\ tools/", rules[4].RawPattern())
+		assert.Equal(t, []Owner{{Value: "bob", Type: UsernameOwner}}, rules[4].Owners)
+		assert.Equal(t, "handbook", rules[2].Comment)
+		assert.Equal(t, []Owner{{Value: "org/web", Type: TeamOwner}}, rules[5].Owners)
+	}
+}
+
+func TestParseFileBOMAndCRLF(t *testing.T) {
+	contents, err := os.ReadFile(filepath.Join("testdata", "codeowners", "bom-crlf"))
+	assert.NoError(t, err)
+	rules, err := ParseFile(bytes.NewReader(contents))
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 3) {
+		assert.Equal(t, "*.cs", rules[0].RawPattern())
+		assert.Equal(t, []Owner{{Value: "dotnet/team", Type: TeamOwner}}, rules[0].Owners)
+		assert.Equal(t, "Docs", rules[1].Section)
+		assert.Equal(t, []Owner{{Value: "org/docs", Type: TeamOwner}}, rules[1].SectionOwners)
+		assert.Equal(t, "CI", rules[2].Comment)
+	}
+
+	// Without WithComments, the file is written out with \n line endings and
+	// no byte order mark
+	assert.Equal(t, "*.cs @dotnet/team\n[Docs] @org/docs\n*.md\n/build/ @dotnet/build # CI\n", rules.String())
+
+	// A byte order mark before a comment doesn't stop it being one
+	rules, err = ParseFile(strings.NewReader("\ufeff# Owners\n* @alice\n"))
+	assert.NoError(t, err)
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, 2, rules[0].LineNumber)
+	}
+}
+
+func TestParseFileSectionDefaultOwners(t *testing.T) {
+	contents, err := os.ReadFile(filepath.Join("testdata", "codeowners", "gitlab-default-owners"))
+	assert.NoError(t, err)
+	rules, err := ParseFile(bytes.NewReader(contents))
+	assert.NoError(t, err)
+
+	owners := make(map[string]string)
+	inherited := make(map[string]bool)
+	for _, rule := range rules {
+		var names []string
+		for _, o := range rule.Owners {
+			names = append(names, o.String())
+		}
+		owners[rule.RawPattern()] = strings.Join(names, " ")
+		inherited[rule.RawPattern()] = rule.InheritsOwners
+	}
+	assert.Equal(t, map[string]string{
+		"docs/":                       "@docs-team",
+		"README.md":                   "@docs-team",
+		"model/db/":                   "@database-team",
+		"config/db/database-setup.md": "@docs-team",
+		"config/":                     "@dev-team @john-smith",
+		"app/":                        "@backend-team",
+		"/deploy/":                    "@ops-team",
+	}, owners)
+	assert.Equal(t, map[string]bool{
+		"docs/":                       true,
+		"README.md":                   true,
+		"model/db/":                   true,
+		"config/db/database-setup.md": false,
+		"config/":                     true,
+		"app/":                        false,
+		"/deploy/":                    true,
+	}, inherited)
+}
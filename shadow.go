@@ -0,0 +1,190 @@
+package codeowners
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ShadowedRule is a rule that can never be the matching rule for a path, as a
+// later rule matches every path that it does.
+type ShadowedRule struct {
+	Rule *Rule
+	// ShadowedBy is the later rule that takes precedence.
+	ShadowedBy *Rule
+}
+
+// ShadowedRules returns the rules in the ruleset that are shadowed by later
+// rules in the same GitLab-style section, in the order they appear. Rules in
+// different sections don't shadow each other, as each section's matching rule
+// applies. The analysis is conservative: a rule is
+// only reported if it can be proven that a later rule's pattern matches every
+// path its pattern does, so some shadowed rules may not be found.
+func (r Ruleset) ShadowedRules() []ShadowedRule {
+	shapes := make([]patternShape, len(r))
+	for i := range r {
+		// Gitea's regular expressions aren't understood, and as every
+		// matching rule applies, they can't shadow each other anyway
+		if r[i].pattern.gitea {
+			continue
+		}
+		shapes[i] = newPatternShape(r[i].pattern.pattern)
+	}
+
+	var shadowed []ShadowedRule
+	for i := range r {
+		if r[i].pattern.gitea {
+			continue
+		}
+		key := r.sectionKey(i)
+		for j := i + 1; j < len(r); j++ {
+			if r.sectionKey(j) != key {
+				continue
+			}
+			if r[i].pattern.pattern == r[j].pattern.pattern || shapes[j].covers(shapes[i]) {
+				shadowed = append(shadowed, ShadowedRule{Rule: &r[i], ShadowedBy: &r[j]})
+				break
+			}
+		}
+	}
+	return shadowed
+}
+
+// patternShape describes the paths a pattern matches in terms of its path
+// segments, which makes it possible to compare patterns with each other.
+type patternShape struct {
+	// valid is false for patterns that aren't understood, which are never
+	// considered to cover or be covered by another.
+	valid bool
+	// all is set for patterns that match every path.
+	all bool
+	// anchored is set for patterns that match relative to the root, rather
+	// than at any depth.
+	anchored bool
+	// segs holds the pattern's segments, with any leading ** for unanchored
+	// patterns and trailing slash or ** removed.
+	segs []string
+	// contentsOnly is set for patterns that only match paths beneath the
+	// paths matched by segs, as they end with a slash or /**.
+	contentsOnly bool
+	// descendants is set for patterns that also match paths beneath the
+	// paths matched by segs, which is the case unless the last segment is *.
+	descendants bool
+}
+
+func newPatternShape(pattern string) patternShape {
+	if pattern == "*" || pattern == "**" {
+		return patternShape{valid: true, all: true}
+	}
+	if pattern == "" || pattern == "/" || strings.Contains(pattern, "***") {
+		return patternShape{}
+	}
+
+	s := patternShape{valid: true}
+	segs := strings.Split(pattern, "/")
+	switch {
+	case segs[0] == "":
+		s.anchored = true
+		segs = segs[1:]
+	case segs[0] == "**":
+		segs = segs[1:]
+	default:
+		// Patterns with a slash anywhere other than at the end are relative
+		// to the root
+		s.anchored = !(len(segs) == 1 || (len(segs) == 2 && segs[1] == ""))
+	}
+	if last := len(segs) - 1; last >= 0 && (segs[last] == "" || segs[last] == "**") {
+		s.contentsOnly = true
+		segs = segs[:last]
+	}
+	if len(segs) == 0 {
+		return patternShape{}
+	}
+	for _, seg := range segs {
+		if seg == "" {
+			return patternShape{}
+		}
+	}
+
+	s.segs = segs
+	s.descendants = s.contentsOnly || segs[len(segs)-1] != "*"
+	return s
+}
+
+// covers reports whether every path matched by other is also matched by s.
+func (s patternShape) covers(other patternShape) bool {
+	switch {
+	case !s.valid || !other.valid:
+		return false
+	case s.all:
+		return true
+	case other.all:
+		return false
+	case s.anchored:
+		return s.coversAnchored(other)
+	default:
+		return s.coversUnanchored(other)
+	}
+}
+
+// coversAnchored handles covers for anchored patterns, which cover paths that
+// start with segments they match, at the right depth.
+func (s patternShape) coversAnchored(other patternShape) bool {
+	n := len(s.segs)
+	if !other.anchored || len(other.segs) < n {
+		return false
+	}
+	for j := 0; j < n; j++ {
+		if s.segs[j] == "**" || other.segs[j] == "**" || !segmentCovers(s.segs[j], other.segs[j]) {
+			return false
+		}
+	}
+
+	if len(other.segs) > n {
+		// Everything other matches is beneath the paths s matches
+		return s.descendants
+	}
+	switch {
+	case s.contentsOnly:
+		return other.contentsOnly
+	case s.descendants:
+		return true
+	default:
+		return !other.descendants
+	}
+}
+
+// coversUnanchored handles covers for unanchored patterns, which cover paths
+// with a segment they match at any depth. Only single segment patterns are
+// handled.
+func (s patternShape) coversUnanchored(other patternShape) bool {
+	if len(s.segs) != 1 {
+		return false
+	}
+	last := len(other.segs) - 1
+	for j, seg := range other.segs {
+		if seg == "**" || !segmentCovers(s.segs[0], seg) {
+			continue
+		}
+		// Patterns ending with a slash only match paths beneath the segment
+		if !s.contentsOnly || j < last || other.contentsOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentCovers reports whether every path segment matched by other is also
+// matched by seg. Neither may be **.
+func segmentCovers(seg, other string) bool {
+	switch {
+	case seg == "**" || other == "**":
+		return false
+	case seg == other || seg == "*":
+		return true
+	case strings.ContainsAny(other, "*?[\\"):
+		// Comparing two wildcard segments isn't worth the trouble
+		return false
+	}
+	re, err := regexp.Compile(`\A` + segmentRegex(seg) + `\z`)
+	return err == nil && re.MatchString(other)
+}
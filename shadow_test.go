@@ -0,0 +1,105 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowedRules(t *testing.T) {
+	examples := []struct {
+		earlier  string
+		later    string
+		shadowed bool
+	}{
+		{"/src/api/*", "/src/**", true},
+		{"/src/api/*", "/src/", true},
+		{"/src/api/", "/src", true},
+		{"/src", "/src/", false},
+		{"/src/", "/src", true},
+		{"/src/main.go", "/src/*", false},
+		{"/src/*", "/src/*", true},
+		{"/src/*/api", "/src/", true},
+		{"/src/", "/src/*/api", false},
+		{"/src/main.go", "/*/main.go", true},
+		{"/src/*.go", "/*/main.go", false},
+		{"/docs/README.md", "*.md", true},
+		{"/docs/*.md", "*.md", true},
+		{"/docs/*.md", "*.txt", false},
+		{"*.md", "/docs/", false},
+		{"/a/node_modules/x", "node_modules", true},
+		{"/a/node_modules", "node_modules/", false},
+		{"/a/node_modules/", "node_modules/", true},
+		{"/a/**/node_modules/x", "node_modules/", true},
+		{"/src/**/main.go", "/src/", true},
+		{"/src/**/main.go", "/lib/", false},
+		{"/src/file\\*.go", "/src/", true},
+		{"/src/a?c", "/src/abc", false},
+		{"/src/abc", "/src/a?c", true},
+		{"/src/abc", "/src/a*", true},
+		{"docs/api", "docs", true},
+		{"docs", "docs/api", false},
+		{"/anything/at/all", "*", true},
+		{"*.go", "**", true},
+		{"*", "*.go", false},
+		{"*.go", "*.go", true},
+	}
+
+	for _, e := range examples {
+		t.Run(e.earlier+" before "+e.later, func(t *testing.T) {
+			ruleset, err := ParseFile(strings.NewReader(e.earlier + " @earlier\n" + e.later + " @later\n"))
+			require.NoError(t, err)
+
+			shadowed := ruleset.ShadowedRules()
+			if !e.shadowed {
+				assert.Empty(t, shadowed)
+				return
+			}
+			assert.Equal(t, []ShadowedRule{{Rule: &ruleset[0], ShadowedBy: &ruleset[1]}}, shadowed)
+		})
+	}
+}
+
+func TestShadowedRulesInSections(t *testing.T) {
+	ruleset, err := ParseFile(strings.NewReader("[Docs]\n/docs/ @org/docs\n[Backend]\n* @org/backend\n[docs]\n/docs/ @org/writers\n"))
+	require.NoError(t, err)
+	// Only the rule in a section with the same name, compared
+	// case-insensitively, shadows /docs/
+	assert.Equal(t, []ShadowedRule{{Rule: &ruleset[0], ShadowedBy: &ruleset[2]}}, ruleset.ShadowedRules())
+}
+
+// TestShadowedRulesSoundness checks that whenever a rule is reported as being
+// shadowed, the later rule really does match every path that it does.
+func TestShadowedRulesSoundness(t *testing.T) {
+	patterns := []string{
+		"*", "*.go", "*.md", "docs", "docs/", "/docs", "/docs/", "/docs/*",
+		"/docs/**", "/docs/*.md", "/docs/api", "/docs/api/", "docs/api",
+		"/**/api", "api/", "/src/**/api", "/src/*/api", "/*/api", "/src/a?i",
+	}
+	paths := []string{
+		"docs", "docs/api", "docs/api/x.go", "docs/README.md", "docs/a/b.md",
+		"src/api", "src/api/main.go", "src/v1/api", "src/v1/api/x", "src/aui",
+		"api/x", "x/api", "main.go", "README.md", "a/docs", "a/docs/x.md",
+	}
+
+	for _, earlier := range patterns {
+		for _, later := range patterns {
+			ruleset, err := ParseFile(strings.NewReader(earlier + " @earlier\n" + later + " @later\n"))
+			require.NoError(t, err)
+			if len(ruleset.ShadowedRules()) == 0 {
+				continue
+			}
+			for _, path := range paths {
+				earlierMatch, err := ruleset[0].Match(path)
+				require.NoError(t, err)
+				laterMatch, err := ruleset[1].Match(path)
+				require.NoError(t, err)
+				if earlierMatch && !laterMatch {
+					t.Errorf("%s reported as shadowed by %s, but only it matches %s", earlier, later, path)
+				}
+			}
+		}
+	}
+}
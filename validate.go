@@ -0,0 +1,142 @@
+package codeowners
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IssueCode identifies the kind of problem a ValidationIssue describes.
+type IssueCode string
+
+const (
+	// IssueNoOwners is reported for rules without any owners, or without any
+	// valid owners when parsed with WithLenientOwners. Negated rules aren't
+	// meant to have owners, so they're not reported.
+	IssueNoOwners IssueCode = "no-owners"
+	// IssueTooFewOwners is reported for rules in sections requiring more
+	// approvals than the rule has owners, as the approvals could never be
+	// given.
+	IssueTooFewOwners IssueCode = "too-few-owners"
+	// IssueDuplicateOwner is reported for owners listed twice on one rule.
+	IssueDuplicateOwner IssueCode = "duplicate-owner"
+	// IssueOwnerCase is reported for owners written with different casing
+	// from an earlier mention of the same owner. Owners are compared
+	// case-insensitively, so it's most likely a typo.
+	IssueOwnerCase IssueCode = "owner-case"
+	// IssueDuplicatePattern is reported for rules whose pattern is used again
+	// later in the same section, which overrides them entirely.
+	IssueDuplicatePattern IssueCode = "duplicate-pattern"
+	// IssueRedundantDoubleStar is reported for patterns ending in /**, which
+	// match the same paths as the pattern ending in / instead, anchored to
+	// the root, as patterns with a slash before their end are.
+	IssueRedundantDoubleStar IssueCode = "redundant-double-star"
+)
+
+// Severity is how serious a ValidationIssue is.
+type Severity string
+
+const (
+	// SeverityError is for issues that stop the file working as intended.
+	SeverityError Severity = "error"
+	// SeverityWarning is for issues that are probably mistakes, but that don't
+	// affect who owns what.
+	SeverityWarning Severity = "warning"
+)
+
+// ValidationIssue is a problem with a rule that parses, but is probably a
+// mistake.
+type ValidationIssue struct {
+	Code     IssueCode
+	Severity Severity
+	// Line is the line number of the rule with the problem.
+	Line    int
+	Message string
+	Rule    *Rule
+}
+
+// Validate checks the ruleset for problems that aren't parse errors, such as
+// rules without owners or patterns used twice, returning them in the order of
+// their lines.
+func (r Ruleset) Validate() []ValidationIssue {
+	var issues []ValidationIssue
+	add := func(rule *Rule, code IssueCode, severity Severity, format string, args ...interface{}) {
+		issues = append(issues, ValidationIssue{
+			Code:     code,
+			Severity: severity,
+			Line:     rule.LineNumber,
+			Message:  fmt.Sprintf(format, args...),
+			Rule:     rule,
+		})
+	}
+
+	// spellings holds the first way each owner was written, by its lowercased
+	// name
+	spellings := make(map[string]string)
+	// lastPattern holds the index of the last rule using each pattern in
+	// each section, by the section's key, as section names ignore case
+	type sectionPattern struct{ section, pattern string }
+	lastPattern := make(map[sectionPattern]int)
+	for i := range r {
+		rule := &r[i]
+		switch {
+		case len(rule.Owners) == 0 && len(rule.InvalidOwners) > 0:
+			add(rule, IssueNoOwners, SeverityWarning, "rule has no valid owners")
+		case len(rule.Owners) == 0 && !rule.Negated:
+			add(rule, IssueNoOwners, SeverityWarning, "rule has no owners")
+		case len(rule.Owners) < rule.MinApprovals:
+			owners := "owners"
+			if len(rule.Owners) == 1 {
+				owners = "owner"
+			}
+			add(rule, IssueTooFewOwners, SeverityError, "section %q requires %d approvals, but the rule only has %d %s", rule.Section, rule.MinApprovals, len(rule.Owners), owners)
+		}
+
+		// Owners inherited from the section aren't written on the rule's line
+		if !rule.InheritsOwners {
+			seen := make(map[Owner]bool)
+			for _, o := range rule.Owners {
+				name := o.String()
+				key := strings.ToLower(name)
+				switch {
+				case seen[o]:
+					add(rule, IssueDuplicateOwner, SeverityWarning, "owner %s is listed more than once", name)
+				case spellings[key] != "" && spellings[key] != name:
+					add(rule, IssueOwnerCase, SeverityWarning, "owner %s is also written as %s", name, spellings[key])
+				case spellings[key] == "":
+					spellings[key] = name
+				}
+				seen[o] = true
+			}
+		}
+
+		// Every matching rule applies with Gitea's dialect, so patterns can't
+		// override each other
+		if rule.pattern.gitea {
+			continue
+		}
+		if pattern := rule.pattern.pattern; strings.HasSuffix(pattern, "/**") && pattern != "/**" {
+			// Without the **, a pattern with no other slash would match at
+			// any depth, so the suggestion is anchored
+			same := strings.TrimSuffix(pattern, "**")
+			if !strings.HasPrefix(same, "/") {
+				same = "/" + same
+			}
+			add(rule, IssueRedundantDoubleStar, SeverityWarning, "pattern %s matches the same paths as %s", pattern, same)
+		}
+		lastPattern[sectionPattern{r.sectionKey(i), rule.RawPattern()}] = i
+	}
+
+	for i := range r {
+		rule := &r[i]
+		if rule.pattern.gitea {
+			continue
+		}
+		if last := lastPattern[sectionPattern{r.sectionKey(i), rule.RawPattern()}]; last != i {
+			add(rule, IssueDuplicatePattern, SeverityWarning, "rule is overridden by the rule with the same pattern on line %d", r[last].LineNumber)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues
+}
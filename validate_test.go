@@ -0,0 +1,69 @@
+package codeowners
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	contents := strings.Join([]string{
+		"*.go @org/go",
+		"docs/ @alice @bob @alice",
+		"*.go @org/go @Org/Go",
+		"/build/** @org/ci",
+		"/**",
+		"[Docs][2] @org/docs @org/web",
+		"docs/**",
+		"*.go @org/docs",
+		"docs/ @carol @dave",
+		"[docs]",
+		"*.go @org/web",
+	}, "\n")
+	ruleset, err := ParseFile(strings.NewReader(contents))
+	require.NoError(t, err)
+
+	type issue struct {
+		code     IssueCode
+		severity Severity
+		line     int
+		message  string
+	}
+	var got []issue
+	for _, i := range ruleset.Validate() {
+		assert.Equal(t, i.Line, i.Rule.LineNumber)
+		got = append(got, issue{i.Code, i.Severity, i.Line, i.Message})
+	}
+	assert.Equal(t, []issue{
+		{IssueDuplicatePattern, SeverityWarning, 1, "rule is overridden by the rule with the same pattern on line 3"},
+		// Lines 2 and 9 have the same pattern, but are in different sections
+		{IssueDuplicateOwner, SeverityWarning, 2, "owner @alice is listed more than once"},
+		{IssueOwnerCase, SeverityWarning, 3, "owner @Org/Go is also written as @org/go"},
+		{IssueRedundantDoubleStar, SeverityWarning, 4, "pattern /build/** matches the same paths as /build/"},
+		{IssueNoOwners, SeverityWarning, 5, "rule has no owners"},
+		{IssueRedundantDoubleStar, SeverityWarning, 7, "pattern docs/** matches the same paths as /docs/"},
+		{IssueTooFewOwners, SeverityError, 8, `section "Docs" requires 2 approvals, but the rule only has 1 owner`},
+		// Section names ignore case, so [docs] is the same section as [Docs]
+		{IssueDuplicatePattern, SeverityWarning, 8, "rule is overridden by the rule with the same pattern on line 11"},
+	}, got)
+}
+
+func TestValidateClean(t *testing.T) {
+	ruleset, err := ParseFile(strings.NewReader("* @org/team\n[Docs] @org/docs\n*.md\n!docs/internal/\n"), WithNegation())
+	require.NoError(t, err)
+	assert.Empty(t, ruleset.Validate())
+
+	// Gitea applies every matching rule, so patterns can't override each other
+	ruleset, err = ParseFile(strings.NewReader(".*\\.go @org/go\n.*\\.go @org/ci\n"), WithDialect(DialectGitea))
+	require.NoError(t, err)
+	assert.Empty(t, ruleset.Validate())
+
+	ruleset, err = ParseFile(strings.NewReader("*.go backend-team\n"), WithLenientOwners())
+	require.NoError(t, err)
+	if issues := ruleset.Validate(); assert.Len(t, issues, 1) {
+		assert.Equal(t, IssueNoOwners, issues[0].Code)
+		assert.Equal(t, "rule has no valid owners", issues[0].Message)
+	}
+}
@@ -0,0 +1,252 @@
+package codeowners
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WriteTo writes the ruleset out as a CODEOWNERS file, with each rule on a
+// line of its own: its pattern, followed by its owners and its comment.
+// Section headers are written before the first rule of each section.
+//
+// Rules parsed with WithComments are written along with the comments and blank
+// lines around them, and as long as they haven't been modified, exactly as
+// they were written in the file, with the same line endings and byte order
+// mark, so an unmodified ruleset is written back out unchanged. Otherwise,
+// lines end with \n.
+func (r Ruleset) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	if len(r) > 0 && r[0].bom {
+		b.WriteString(byteOrderMark)
+	}
+	section, optional, approvals := "", false, 0
+	for i := 0; i < len(r); i++ {
+		rule := r[i]
+		// Rules expanded from braces are written as the pattern they were
+		// expanded from, if they haven't been changed
+		line := rule.line()
+		last := i
+		if n := r[i:].collapsedBraces(); n > 1 {
+			last = i + n - 1
+			line = rule.collapsedLine()
+			rule.Trailing = r[last].Trailing
+			rule.noFinalNewline = r[last].noFinalNewline
+		}
+
+		// Rules parsed with WithComments have their section headers among
+		// their leading lines already
+		lines := rule.Leading
+		if (rule.Section != section || rule.Optional != optional || rule.MinApprovals != approvals) && rule.newline == "" {
+			header := "[" + rule.Section + "]"
+			if rule.Optional {
+				header = "^" + header
+			}
+			if rule.MinApprovals > 0 {
+				header += "[" + strconv.Itoa(rule.MinApprovals) + "]"
+			}
+			for _, o := range rule.SectionOwners {
+				header += " " + o.String()
+			}
+			lines = append(lines[:len(lines):len(lines)], header)
+		}
+		section, optional, approvals = rule.Section, rule.Optional, rule.MinApprovals
+		lines = append(lines[:len(lines):len(lines)], line)
+		if last == len(r)-1 {
+			lines = append(lines, rule.Trailing...)
+		}
+
+		newline := rule.newline
+		if newline == "" {
+			newline = "\n"
+		}
+		for j, line := range lines {
+			b.WriteString(line)
+			if last < len(r)-1 || j < len(lines)-1 || !rule.noFinalNewline {
+				b.WriteString(newline)
+			}
+		}
+		i = last
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// String returns the ruleset as a CODEOWNERS file, as written by WriteTo.
+func (r Ruleset) String() string {
+	var b strings.Builder
+	r.WriteTo(&b)
+	return b.String()
+}
+
+// line returns the rule as a line of a CODEOWNERS file. If it was parsed with
+// WithComments and hasn't been modified since, that's the line it was parsed
+// from.
+//
+// Modified rules parsed with WithComments are written with the whitespace
+// their line used, so that files with tab-aligned columns stay aligned, but
+// without any trailing whitespace.
+func (r Rule) line() string {
+	text := r.text()
+	if r.source == "" {
+		return text
+	}
+	// A rule expanded from braces was parsed from a line giving all of the
+	// rules it was expanded with, so it's only written as that line when
+	// they're collapsed
+	if text == r.parsedText && r.brace == nil {
+		return r.source
+	}
+	return r.format(r.pattern.pattern, lineSeparators(r.source))
+}
+
+// collapsedLine returns the line the rules expanded from a pattern with braces
+// are written as, given the first of them: the line they were parsed from, if
+// it's known.
+func (r Rule) collapsedLine() string {
+	if r.source == "" {
+		return r.textWithPattern(r.brace.pattern)
+	}
+	if r.text() == r.parsedText {
+		return r.source
+	}
+	return r.format(r.brace.pattern, lineSeparators(r.source))
+}
+
+// text returns the rule as a line of a CODEOWNERS file, with its pattern,
+// owners and comment separated by spaces. Owners inherited from the rule's
+// section are left out, as they're listed in the section header, and invalid
+// owners come after the valid ones.
+func (r Rule) text() string {
+	return r.textWithPattern(r.pattern.pattern)
+}
+
+// textWithPattern returns the rule as text does, but with another pattern, as
+// it's written in the file.
+func (r Rule) textWithPattern(pattern string) string {
+	return r.format(pattern, separators{pattern: " ", owner: " ", comment: " "})
+}
+
+// format returns the rule as a line of a CODEOWNERS file with the given
+// pattern, as it's written in the file, and separators.
+func (r Rule) format(pattern string, sep separators) string {
+	if r.Negated {
+		pattern = "!" + pattern
+	}
+	var owners []string
+	if !r.InheritsOwners || !ownersEqual(r.Owners, r.SectionOwners) {
+		for _, o := range r.Owners {
+			owners = append(owners, o.String())
+		}
+	}
+	owners = append(owners, r.InvalidOwners...)
+
+	var b strings.Builder
+	b.WriteString(sep.indent)
+	b.WriteString(escapePattern(pattern))
+	for i, o := range owners {
+		if i == 0 {
+			b.WriteString(sep.pattern)
+		} else {
+			b.WriteString(sep.owner)
+		}
+		b.WriteString(o)
+	}
+	if r.Comment != "" {
+		b.WriteString(sep.comment)
+		b.WriteString("# " + r.Comment)
+	}
+	return b.String()
+}
+
+// separators holds the whitespace the fields of a rule's line are written
+// with: indent before the pattern, pattern after it, owner between owners,
+// and comment before the comment.
+type separators struct {
+	indent, pattern, owner, comment string
+}
+
+// lineSeparators returns the separators a rule's line was written with, using
+// a single space for any it didn't need, such as between owners on a line
+// with only one.
+func lineSeparators(line string) separators {
+	sep := separators{pattern: " ", owner: " ", comment: " "}
+	line = strings.TrimRightFunc(line, unicode.IsSpace)
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	sep.indent, line = line[:indent], line[indent:]
+
+	// Split the line into its fields, and the whitespace after each of them,
+	// keeping escaped whitespace in the pattern
+	var fields, spaces []string
+	for len(line) > 0 {
+		end := 0
+		for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end > len(line) {
+			end = len(line)
+		}
+		rest := strings.TrimLeft(line[end:], " \t")
+		fields = append(fields, line[:end])
+		spaces = append(spaces, line[end:len(line)-len(rest)])
+		line = rest
+	}
+
+	owners := 0
+	for i := 1; i < len(fields); i++ {
+		space := spaces[i-1]
+		if strings.HasPrefix(fields[i], "#") {
+			sep.comment = space
+			break
+		}
+		if i == 1 {
+			sep.pattern = space
+		} else if owners == 1 {
+			sep.owner = space
+		}
+		owners++
+	}
+	// A line with only a comment after its pattern separates any owners the
+	// same way
+	if owners == 0 {
+		sep.pattern = sep.comment
+	}
+	return sep
+}
+
+// ownersEqual reports whether two lists of owners are the same.
+func ownersEqual(a, b []Owner) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// escapePattern escapes the characters in a pattern that would otherwise end
+// it, such as spaces, or start a comment, as a leading # does. Characters that
+// are escaped already are left alone.
+func escapePattern(pattern string) string {
+	var b strings.Builder
+	escaped := false
+	for i, ch := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case isWhitespace(ch) || ch == '#' && i == 0:
+			b.WriteRune('\\')
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
@@ -0,0 +1,161 @@
+package codeowners
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRulesetWriteTo(t *testing.T) {
+	rules, err := ParseFile(strings.NewReader("# Owners\n\n*.go   @org/team  #  Go code\n[Docs]\n*.md docs@example.com\n"))
+	assert.NoError(t, err)
+
+	// Without WithComments, only the rules and section headers are written,
+	// in a standard layout
+	var buf bytes.Buffer
+	n, err := rules.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "*.go @org/team # Go code\n[Docs]\n*.md docs@example.com\n", buf.String())
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Equal(t, buf.String(), rules.String())
+
+	assert.Equal(t, "", Ruleset{}.String())
+}
+
+func TestRulesetWriteToModified(t *testing.T) {
+	contents := "# Go\n*.go   @org/team\n\n# Docs\n*.md    @org/docs # docs\n"
+	rules, err := ParseFile(strings.NewReader(contents), WithComments())
+	assert.NoError(t, err)
+	assert.Equal(t, contents, rules.String())
+
+	// Modified rules are rewritten, but keep their comments, and the
+	// whitespace between their fields
+	rules[1].Owners = append(rules[1].Owners, Owner{Value: "alice", Type: UsernameOwner})
+	rule, err := NewRule("src/my file.txt", []Owner{{Value: "bob", Type: UsernameOwner}})
+	assert.NoError(t, err)
+	rules = append(rules, rule)
+	assert.Equal(t, "# Go\n*.go   @org/team\n\n# Docs\n*.md    @org/docs @alice # docs\nsrc/my\\ file.txt @bob\n", rules.String())
+}
+
+func TestRulesetWriteToModifiedKeepsSeparators(t *testing.T) {
+	examples := []struct {
+		name, line, want string
+		modify           func(r *Rule)
+	}{
+		{
+			name:   "tab-aligned columns",
+			line:   "*.go\t\t@org/team\t@alice\t# Go",
+			want:   "*.go\t\t@org/team\t@alice\t@bob\t# Go",
+			modify: func(r *Rule) { r.Owners = append(r.Owners, Owner{Value: "bob", Type: UsernameOwner}) },
+		},
+		{
+			name:   "indented",
+			line:   "\t/docs/  @org/docs",
+			want:   "\t/docs/  @org/docs # handbook",
+			modify: func(r *Rule) { r.Comment = "handbook" },
+		},
+		{
+			name:   "trailing whitespace",
+			line:   "*.go\t@org/team \t",
+			want:   "*.go\t@org/team @bob",
+			modify: func(r *Rule) { r.Owners = append(r.Owners, Owner{Value: "bob", Type: UsernameOwner}) },
+		},
+		{
+			name:   "escaped whitespace in the pattern",
+			line:   "my\\ docs/\t@org/docs",
+			want:   "my\\ docs/\t@alice",
+			modify: func(r *Rule) { r.Owners = []Owner{{Value: "alice", Type: UsernameOwner}} },
+		},
+		{
+			name:   "comment without owners",
+			line:   "*.go\t\t# Go",
+			want:   "*.go\t\t@alice\t\t# Go",
+			modify: func(r *Rule) { r.Owners = []Owner{{Value: "alice", Type: UsernameOwner}} },
+		},
+	}
+	for _, e := range examples {
+		t.Run(e.name, func(t *testing.T) {
+			rules, err := ParseFile(strings.NewReader(e.line+"\n"), WithComments())
+			assert.NoError(t, err)
+			assert.Equal(t, e.line+"\n", rules.String())
+			e.modify(&rules[0])
+			assert.Equal(t, e.want+"\n", rules.String())
+		})
+	}
+}
+
+func TestRulesetWriteToRoundTrip(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "codeowners", "*"))
+	assert.NoError(t, err)
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			contents, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			rules, err := ParseFile(bytes.NewReader(contents))
+			assert.NoError(t, err)
+
+			reparsed, err := ParseFile(strings.NewReader(rules.String()))
+			assert.NoError(t, err)
+			if assert.Len(t, reparsed, len(rules)) {
+				for i := range rules {
+					assert.Equal(t, rules[i].RawPattern(), reparsed[i].RawPattern())
+					assert.Equal(t, rules[i].Owners, reparsed[i].Owners)
+					assert.Equal(t, rules[i].Comment, reparsed[i].Comment)
+					assert.Equal(t, rules[i].Section, reparsed[i].Section)
+					assert.Equal(t, rules[i].Optional, reparsed[i].Optional)
+					assert.Equal(t, rules[i].MinApprovals, reparsed[i].MinApprovals)
+					assert.Equal(t, rules[i].SectionOwners, reparsed[i].SectionOwners)
+					assert.Equal(t, rules[i].InheritsOwners, reparsed[i].InheritsOwners)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRule(t *testing.T) {
+	examples := []struct {
+		pattern string
+		raw     string
+		path    string
+	}{
+		{"docs/", "docs/", "docs/index.md"},
+		{"my file.txt", `my\ file.txt`, "dir/my file.txt"},
+		{`my\ file.txt`, `my\ file.txt`, "my file.txt"},
+		{"#notes.md", `\#notes.md`, "#notes.md"},
+		{"issue#12.md", "issue#12.md", "issue#12.md"},
+		{"getting  started.md", `getting\ \ started.md`, "getting  started.md"},
+		{"notes ", `notes\ `, "notes "},
+		{"docs/a #b.md", `docs/a\ #b.md`, "docs/a #b.md"},
+	}
+	for _, e := range examples {
+		t.Run(e.pattern, func(t *testing.T) {
+			rule, err := NewRule(e.pattern, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, e.raw, rule.RawPattern())
+			match, err := rule.Match(e.path)
+			assert.NoError(t, err)
+			assert.True(t, match)
+
+			// The rule survives being written out and parsed again, along
+			// with its owners
+			rule.Owners = []Owner{{Value: "user", Type: UsernameOwner}}
+			rules, err := ParseFile(strings.NewReader(Ruleset{rule}.String()))
+			assert.NoError(t, err)
+			if assert.Len(t, rules, 1) {
+				assert.Equal(t, e.raw, rules[0].RawPattern())
+				assert.Equal(t, rule.Owners, rules[0].Owners)
+			}
+		})
+	}
+
+	_, err := NewRule("", nil)
+	assert.EqualError(t, err, "empty pattern")
+	_, err = NewRule("a***b", nil)
+	assert.EqualError(t, err, "pattern cannot contain three consecutive asterisks")
+	_, err = NewRule("src/[abc", nil)
+	assert.EqualError(t, err, "unterminated character class")
+}